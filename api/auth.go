@@ -0,0 +1,641 @@
+package main
+
+import (
+	"blog/logging"
+	repo "blog/repo"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultTokenTTL is how long a JWT issued on login is valid when no TTL is configured.
+const DefaultTokenTTL = 24 * time.Hour
+
+// MinPasswordLength is the minimum length of an author's password.
+const MinPasswordLength = 8
+
+// apiTokenPrefix distinguishes API tokens from JWTs in the Authorization header.
+const apiTokenPrefix = "blog_"
+
+// PasswordResetTTL is how long a password reset token issued by an admin is valid.
+const PasswordResetTTL = 72 * time.Hour
+
+// passwordResetAudience is the audience of the password reset tokens, which do not authenticate requests.
+const passwordResetAudience = "password_reset"
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Principal is the author a request is authenticated as, with its role.
+type Principal struct {
+	AuthorId string
+	Role     string
+}
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	requestIDKey
+	accessEntryKey
+)
+
+// withPrincipal returns a copy of the context carrying the given principal.
+func withPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFrom returns the principal of an authenticated request context.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey).(Principal)
+	return p, ok
+}
+
+// requireAuth returns the principal of the request, or answers 401 if the request is anonymous.
+func requireAuth(w http.ResponseWriter, r *http.Request) (Principal, bool) {
+	p, ok := PrincipalFrom(r.Context())
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="blog"`)
+		writeError(w, r, errUnauthenticated)
+	}
+	return p, ok
+}
+
+// Authenticator authenticates requests with the JWTs it issues on login or with API tokens,
+// and answers to the authentication requests. The author registering with AdminEmail is an admin.
+type Authenticator struct {
+	Service    repo.BlogService
+	Secret     []byte
+	TokenTTL   time.Duration
+	AdminEmail string
+}
+
+// Middleware authenticates the requests carrying a bearer token and rejects invalid tokens with 401.
+// Requests without Authorization header go through anonymously.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="blog"`)
+			writeError(w, r, problem(http.StatusUnauthorized, "invalid_authorization", "expected a bearer token"))
+			return
+		}
+
+		p, err := a.authenticate(r.Context(), token)
+		if err != nil {
+			if errors.Is(err, ErrInvalidToken) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="blog", error="invalid_token"`)
+				writeError(w, r, errInvalidToken)
+				return
+			}
+			writeError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+	})
+}
+
+// authenticate returns the principal of an API token or of a JWT, with the current role of its author.
+func (a *Authenticator) authenticate(ctx context.Context, token string) (Principal, error) {
+
+	var p Principal
+	var err error
+
+	if strings.HasPrefix(token, apiTokenPrefix) {
+		p, err = a.lookupAPIToken(ctx, token)
+	} else {
+		p, err = a.parseJWT(token)
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+
+	// tokens of deleted authors are no longer valid
+	author, err := a.Service.GetAuthorById(ctx, p.AuthorId)
+	if err != nil {
+		if errors.Is(err, repo.ErrAuthorNotFound) {
+			return Principal{}, ErrInvalidToken
+		}
+		return Principal{}, err
+	}
+	p.Role = author.Role
+
+	return p, nil
+}
+
+// lookupAPIToken returns the principal of an API token which has not been revoked.
+func (a *Authenticator) lookupAPIToken(ctx context.Context, token string) (Principal, error) {
+
+	t, err := a.Service.GetTokenByHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, repo.ErrTokenNotFound) {
+			return Principal{}, ErrInvalidToken
+		}
+		return Principal{}, err
+	}
+	if t.RevokedAt != nil {
+		return Principal{}, ErrInvalidToken
+	}
+
+	return Principal{AuthorId: t.AuthorId}, nil
+}
+
+// issueJWT returns a signed JWT for the given author, and its expiration time.
+func (a *Authenticator) issueJWT(authorId string, now time.Time) (string, time.Time, error) {
+
+	ttl := a.TokenTTL
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+	expiresAt := now.Add(ttl)
+
+	claims := jwt.RegisteredClaims{
+		Subject:   authorId,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.Secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// parseJWT verifies the signature and expiration of a JWT and returns its principal.
+func (a *Authenticator) parseJWT(token string) (Principal, error) {
+
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, ErrInvalidToken
+		}
+		return a.Secret, nil
+	})
+	if err != nil || claims.Subject == "" || len(claims.Audience) > 0 {
+		return Principal{}, ErrInvalidToken
+	}
+
+	return Principal{AuthorId: claims.Subject}, nil
+}
+
+// issuePasswordReset returns a signed password reset token for the given author, and its expiration time.
+// The token is bound to the current password hash of the author, so that it can only be used once.
+func (a *Authenticator) issuePasswordReset(author repo.Author, now time.Time) (string, time.Time, error) {
+
+	expiresAt := now.Add(PasswordResetTTL)
+
+	claims := jwt.RegisteredClaims{
+		Subject:   author.Id,
+		Audience:  jwt.ClaimStrings{passwordResetAudience},
+		ID:        hashToken(author.PasswordHash),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.Secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// parsePasswordReset verifies a password reset token and returns the claims of its author and password.
+func (a *Authenticator) parsePasswordReset(token string) (jwt.RegisteredClaims, error) {
+
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, ErrInvalidToken
+		}
+		return a.Secret, nil
+	})
+	if err != nil || claims.Subject == "" || !claims.VerifyAudience(passwordResetAudience, true) {
+		return jwt.RegisteredClaims{}, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// dummyHash is the bcrypt hash, at the default cost, of a password nobody has. The logins of
+// unknown authors are compared with it, so that they take as long to answer as the known ones.
+var dummyHash = []byte("$2a$10$F0KZfKEYniO/W97uUjuoSuLp6.VT/cEbKBWg5mIFRJc8kDdaYZKKC")
+
+// credentials is the body of the login and register requests.
+type credentials struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (a *Authenticator) Login(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	var c credentials
+
+	err := decodeBody(w, r, &c)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var v validator
+	v.required("email", c.Email)
+	v.required("password", c.Password)
+	err = v.err()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	author, err := a.Service.GetAuthorByEmail(ctx, c.Email)
+	if err != nil && !errors.Is(err, repo.ErrAuthorNotFound) {
+		writeError(w, r, err)
+		return
+	}
+
+	// unknown authors, authors without credentials and wrong passwords are not told apart,
+	// neither by the answer nor by its time as bcrypt runs for all of them
+	known := err == nil && author.PasswordHash != ""
+	hash := dummyHash
+	if known {
+		hash = []byte(author.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(c.Password)) != nil || !known {
+		writeError(w, r, problem(http.StatusUnauthorized, "invalid_credentials", "invalid email or password"))
+		return
+	}
+
+	token, expiresAt, err := a.issueJWT(author.Id, time.Now())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"token": token, "expires_at": expiresAt})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (a *Authenticator) Register(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	var c credentials
+
+	err := decodeBody(w, r, &c)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var v validator
+	v.author("", c.Name, c.Email)
+	v.password("password", c.Password)
+	err = v.err()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// existing authors keep their credentials
+	_, err = a.Service.GetAuthorByEmail(ctx, c.Email)
+	if err == nil {
+		writeError(w, r, repo.ErrAuthorExists)
+		return
+	}
+	if !errors.Is(err, repo.ErrAuthorNotFound) {
+		writeError(w, r, err)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(c.Password), bcrypt.DefaultCost)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// registered authors are readers until an admin grants them another role, but for the configured admin
+	role := repo.RoleReader
+	if a.AdminEmail != "" && c.Email == a.AdminEmail {
+		role = repo.RoleAdmin
+	}
+	author := repo.Author{Name: c.Name, Email: c.Email, PasswordHash: string(hash), Role: role}
+	id, err := a.Service.AddAuthor(ctx, author)
+	if err != nil {
+		// the email may have been taken since it was checked
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// IssuePasswordReset lets an admin issue a token with which an author sets its password, the authors
+// added before the authentication have none and cannot register again with their email.
+func (a *Authenticator) IssuePasswordReset(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	err := authorize(p, ActionManageAuthors, Resource{})
+	if err != nil {
+		forbid(w, r, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	// the password hash is only read by email
+	author, err := a.Service.GetAuthorById(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	author, err = a.Service.GetAuthorByEmail(ctx, author.Email)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	token, expiresAt, err := a.issuePasswordReset(author, time.Now())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"token": token, "expires_at": expiresAt})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// ResetPassword sets the password of the author of a password reset token.
+func (a *Authenticator) ResetPassword(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	err := decodeBody(w, r, &body)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var v validator
+	v.required("token", body.Token)
+	v.password("password", body.Password)
+	err = v.err()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	claims, err := a.parsePasswordReset(body.Token)
+	if err != nil {
+		writeError(w, r, errInvalidToken)
+		return
+	}
+
+	// the token is spent once the password it was issued for has changed
+	author, err := a.Service.GetAuthorById(ctx, claims.Subject)
+	if errors.Is(err, repo.ErrAuthorNotFound) {
+		writeError(w, r, errInvalidToken)
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	author, err = a.Service.GetAuthorByEmail(ctx, author.Email)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if hashToken(author.PasswordHash) != claims.ID {
+		writeError(w, r, errInvalidToken)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = a.Service.SetAuthorPassword(ctx, author.Id, string(hash))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (a *Authenticator) ListTokens(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := a.Service.ListTokens(ctx, p.AuthorId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// CreateToken mints a new API token for the authenticated author, the token is only shown once.
+func (a *Authenticator) CreateToken(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+
+	err := decodeBody(w, r, &body)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var v validator
+	v.text("name", body.Name, MaxNameLength)
+	err = v.err()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	token, err := newAPIToken()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	t := repo.Token{AuthorId: p.AuthorId, Name: body.Name, Hash: hashToken(token)}
+	t.Id, err = a.Service.AddToken(ctx, t)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(map[string]string{"id": t.Id, "name": t.Name, "token": token})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (a *Authenticator) RevokeToken(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	err = a.Service.RevokeToken(ctx, p.AuthorId, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// grantAdmin makes the author of the given email an admin, if it exists and is not one yet,
+// so that a new blog has an admin to grant the roles of the other authors.
+func grantAdmin(ctx context.Context, service repo.BlogService, email string, logger *logging.Logger) error {
+
+	if email == "" {
+		return nil
+	}
+
+	author, err := service.GetAuthorByEmail(ctx, email)
+	if errors.Is(err, repo.ErrAuthorNotFound) {
+		logger.Info("admin has no account yet, registering with its email makes it admin", "email", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if author.Role == repo.RoleAdmin {
+		return nil
+	}
+
+	err = service.SetAuthorRole(ctx, author.Id, repo.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	logger.Info("granted the admin role", "author_id", author.Id, "email", email)
+	return nil
+}
+
+// newAPIToken returns a random API token.
+func newAPIToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + hex.EncodeToString(b), nil
+}
+
+// hashToken returns the hash under which an API token is stored.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"blog/logging"
+	repo "blog/repo"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var secret = []byte("test secret")
+var expectedTokenId = "b4a4de9e-2f52-4cf1-8907-3d828d403129"
+
+// principalHandler answers with the author id and role of the authenticated principal, if any.
+var principalHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	p, ok := PrincipalFrom(r.Context())
+	if ok {
+		w.Write([]byte(p.AuthorId + " " + p.Role)) // nolint: errcheck
+	}
+})
+
+func TestMiddleware(t *testing.T) {
+
+	apiToken := apiTokenPrefix + "0123"
+	revokedAt := time.Now()
+	r := &MockService{
+		GetTokenByHashFunc: func(hash string) (repo.Token, error) {
+			switch hash {
+			case hashToken(apiToken):
+				return repo.Token{Id: expectedTokenId, AuthorId: author.Id}, nil
+			case hashToken(apiTokenPrefix + "revoked"):
+				return repo.Token{Id: expectedTokenId, AuthorId: author.Id, RevokedAt: &revokedAt}, nil
+			}
+			return repo.Token{}, repo.ErrTokenNotFound
+		},
+		GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+			if id != author.Id {
+				return repo.Author{}, repo.ErrAuthorNotFound
+			}
+			return repo.Author{Id: author.Id, Role: repo.RoleEditor}, nil
+		},
+	}
+	a := &Authenticator{Service: r, Secret: secret}
+
+	serve := func(header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/articles", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		res := httptest.NewRecorder()
+		a.Middleware(principalHandler).ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("anonymous request", func(t *testing.T) {
+		res := serve("")
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Empty(t, res.Body.String())
+	})
+
+	t.Run("valid JWT", func(t *testing.T) {
+		token, _, err := a.issueJWT(author.Id, time.Now())
+		require.NoError(t, err)
+		res := serve("Bearer " + token)
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, res.Body.String(), author.Id+" "+repo.RoleEditor)
+	})
+
+	t.Run("valid API token", func(t *testing.T) {
+		res := serve("Bearer " + apiToken)
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, res.Body.String(), author.Id+" "+repo.RoleEditor)
+	})
+
+	t.Run("return 401 for invalid credentials", func(t *testing.T) {
+		expired, _, err := a.issueJWT(author.Id, time.Now().Add(-2*DefaultTokenTTL))
+		require.NoError(t, err)
+		other, _, err := (&Authenticator{Secret: []byte("other secret")}).issueJWT(author.Id, time.Now())
+		require.NoError(t, err)
+		deleted, _, err := a.issueJWT(expectedTokenId, time.Now())
+		require.NoError(t, err)
+
+		for _, header := range []string{
+			"Basic dGVzdDp0ZXN0",
+			"Bearer " + expired,
+			"Bearer " + other,
+			"Bearer " + deleted,
+			"Bearer not.a.jwt",
+			"Bearer " + apiTokenPrefix + "unknown",
+			"Bearer " + apiTokenPrefix + "revoked",
+		} {
+			res := serve(header)
+			require.Equal(t, res.Code, http.StatusUnauthorized, header)
+		}
+	})
+}
+
+func TestLogin(t *testing.T) {
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	r := &MockService{
+		GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
+			switch email {
+			case author.Email:
+				return repo.Author{Id: author.Id, Name: author.Name, Email: author.Email, PasswordHash: string(hash)}, nil
+			case "nopassword@email.com":
+				return repo.Author{Id: author.Id, Email: email}, nil
+			}
+			return repo.Author{}, repo.ErrAuthorNotFound
+		},
+	}
+	a := &Authenticator{Service: r, Secret: secret}
+
+	login := func(email, password string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"email": %q, "password": %q}`, email, password)
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+		res := httptest.NewRecorder()
+		a.Login(res, req)
+		return res
+	}
+
+	t.Run("can login with valid credentials", func(t *testing.T) {
+		res := login(author.Email, "password")
+		require.Equal(t, res.Code, http.StatusOK)
+
+		var body struct {
+			Token string `json:"token"`
+		}
+		json.Unmarshal(res.Body.Bytes(), &body) // nolint: errcheck
+
+		p, err := a.parseJWT(body.Token)
+		require.NoError(t, err)
+		require.Equal(t, p.AuthorId, author.Id)
+	})
+
+	t.Run("return 401 for invalid credentials", func(t *testing.T) {
+		require.Equal(t, login(author.Email, "wrong").Code, http.StatusUnauthorized)
+		require.Equal(t, login("unknown@email.com", "password").Code, http.StatusUnauthorized)
+		require.Equal(t, login("nopassword@email.com", "password").Code, http.StatusUnauthorized)
+	})
+
+	t.Run("return 422 if password is missing", func(t *testing.T) {
+		require.Equal(t, login(author.Email, "").Code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("unknown authors are compared with a hash as costly as the others", func(t *testing.T) {
+		cost, err := bcrypt.Cost(dummyHash)
+		require.NoError(t, err)
+		require.Equal(t, cost, bcrypt.DefaultCost)
+	})
+}
+
+func TestRegister(t *testing.T) {
+
+	register := func(r *MockService, body string) *httptest.ResponseRecorder {
+		a := &Authenticator{Service: r, Secret: secret}
+		req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body))
+		res := httptest.NewRecorder()
+		a.Register(res, req)
+		return res
+	}
+
+	t.Run("can register new author", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
+				return repo.Author{}, repo.ErrAuthorNotFound
+			},
+			AddAuthorFunc: func(a repo.Author) (string, error) {
+				return expectedAuthorId, nil
+			},
+		}
+
+		res := register(r, `{"name": "test", "email": "test@email.com", "password": "password"}`)
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Len(t, r.Authors, 1)
+		require.NoError(t, bcrypt.CompareHashAndPassword([]byte(r.Authors[0].PasswordHash), []byte("password")))
+	})
+
+	t.Run("registered authors are readers but for the admin", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
+				return repo.Author{}, repo.ErrAuthorNotFound
+			},
+			AddAuthorFunc: func(a repo.Author) (string, error) {
+				return expectedAuthorId, nil
+			},
+		}
+		a := &Authenticator{Service: r, Secret: secret, AdminEmail: "admin@email.com"}
+
+		for _, email := range []string{"test@email.com", "admin@email.com"} {
+			body := fmt.Sprintf(`{"name": "test", "email": %q, "password": "password"}`, email)
+			req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body))
+			res := httptest.NewRecorder()
+			a.Register(res, req)
+			require.Equal(t, res.Code, http.StatusOK)
+		}
+		require.Equal(t, r.Authors[0].Role, repo.RoleReader)
+		require.Equal(t, r.Authors[1].Role, repo.RoleAdmin)
+	})
+
+	t.Run("return 409 if author exists", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
+				return author, nil
+			},
+		}
+
+		res := register(r, `{"name": "test", "email": "test@email.com", "password": "password"}`)
+		require.Equal(t, res.Code, http.StatusConflict)
+	})
+
+	t.Run("return 409 if author is added meanwhile", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
+				return repo.Author{}, repo.ErrAuthorNotFound
+			},
+			AddAuthorFunc: func(a repo.Author) (string, error) {
+				return "", repo.ErrAuthorExists
+			},
+		}
+
+		res := register(r, `{"name": "test", "email": "test@email.com", "password": "password"}`)
+		require.Equal(t, res.Code, http.StatusConflict)
+	})
+
+	t.Run("return 422 if password is too short", func(t *testing.T) {
+		res := register(&MockService{}, `{"name": "test", "email": "test@email.com", "password": "pass"}`)
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+
+	// the author was added before the authentication, it has no password
+	var passwordHash string
+	r := &MockService{
+		GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+			if id != author.Id {
+				return repo.Author{}, repo.ErrAuthorNotFound
+			}
+			return author, nil
+		},
+		GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
+			a := author
+			a.PasswordHash = passwordHash
+			return a, nil
+		},
+		SetAuthorPasswordFunc: func(id string, hash string) error {
+			passwordHash = hash
+			return nil
+		},
+	}
+	a := &Authenticator{Service: r, Secret: secret}
+
+	issue := func(req *http.Request) *httptest.ResponseRecorder {
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+		a.IssuePasswordReset(res, req)
+		return res
+	}
+
+	reset := func(token string, password string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"token": %q, "password": %q}`, token, password)
+		req := httptest.NewRequest(http.MethodPost, "/auth/password", strings.NewReader(body))
+		res := httptest.NewRecorder()
+		a.ResetPassword(res, req)
+		return res
+	}
+
+	var token string
+
+	t.Run("admins can issue a reset token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/authors/"+author.Id+"/password-reset", nil)
+		require.Equal(t, issue(req).Code, http.StatusUnauthorized)
+		require.Equal(t, issue(asAuthor(req)).Code, http.StatusForbidden)
+
+		res := issue(asRole(req, otherAuthorId, repo.RoleAdmin))
+		require.Equal(t, res.Code, http.StatusOK)
+
+		var body struct {
+			Token string `json:"token"`
+		}
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body))
+		token = body.Token
+	})
+
+	t.Run("reset tokens do not authenticate", func(t *testing.T) {
+		_, err := a.parseJWT(token)
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("the author sets its password with the token", func(t *testing.T) {
+		require.Equal(t, reset(token, "short").Code, http.StatusUnprocessableEntity)
+
+		res := reset(token, "password")
+		require.Equal(t, res.Code, http.StatusOK)
+		require.NoError(t, bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte("password")))
+	})
+
+	t.Run("reset tokens can only be used once", func(t *testing.T) {
+		require.Equal(t, reset(token, "other password").Code, http.StatusUnauthorized)
+	})
+
+	t.Run("return 401 for invalid tokens", func(t *testing.T) {
+		login, _, err := a.issueJWT(author.Id, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, reset(login, "password").Code, http.StatusUnauthorized)
+		require.Equal(t, reset("not.a.jwt", "password").Code, http.StatusUnauthorized)
+	})
+}
+
+func TestGrantAdmin(t *testing.T) {
+
+	logger := logging.New(&bytes.Buffer{})
+
+	grant := func(a repo.Author, err error) []string {
+		var granted []string
+		r := &MockService{
+			GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
+				return a, err
+			},
+			SetAuthorRoleFunc: func(id string, role string) error {
+				granted = append(granted, id+" "+role)
+				return nil
+			},
+		}
+		require.NoError(t, grantAdmin(context.Background(), r, "admin@email.com", logger))
+		return granted
+	}
+
+	t.Run("grant the admin role to the author of the email", func(t *testing.T) {
+		granted := grant(repo.Author{Id: expectedAuthorId, Role: repo.RoleReader}, nil)
+		require.Equal(t, granted, []string{expectedAuthorId + " " + repo.RoleAdmin})
+	})
+
+	t.Run("leave admins and unknown emails alone", func(t *testing.T) {
+		require.Empty(t, grant(repo.Author{Id: expectedAuthorId, Role: repo.RoleAdmin}, nil))
+		require.Empty(t, grant(repo.Author{}, repo.ErrAuthorNotFound))
+	})
+
+	t.Run("nothing to grant without email", func(t *testing.T) {
+		require.NoError(t, grantAdmin(context.Background(), &MockService{}, "", logger))
+	})
+}
+
+func TestTokens(t *testing.T) {
+
+	t.Run("can create token", func(t *testing.T) {
+		var stored repo.Token
+		r := &MockService{
+			AddTokenFunc: func(tok repo.Token) (string, error) {
+				stored = tok
+				return expectedTokenId, nil
+			},
+		}
+
+		a := &Authenticator{Service: r, Secret: secret}
+		req := httptest.NewRequest(http.MethodPost, "/auth/tokens", strings.NewReader(`{"name": "ci"}`))
+		req = asAuthor(req)
+		res := httptest.NewRecorder()
+		a.CreateToken(res, req)
+
+		var body map[string]string
+		json.Unmarshal(res.Body.Bytes(), &body) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, body["id"], expectedTokenId)
+		require.True(t, strings.HasPrefix(body["token"], apiTokenPrefix))
+		require.Equal(t, stored.AuthorId, author.Id)
+		require.Equal(t, stored.Hash, hashToken(body["token"]))
+	})
+
+	t.Run("can revoke token", func(t *testing.T) {
+		r := &MockService{
+			RevokeTokenFunc: func(authorId string, id string) error {
+				require.Equal(t, authorId, author.Id)
+				if id != expectedTokenId {
+					return repo.ErrTokenNotFound
+				}
+				return nil
+			},
+		}
+
+		a := &Authenticator{Service: r, Secret: secret}
+		for id, code := range map[string]int{expectedTokenId: http.StatusOK, expectedArticleId: http.StatusNotFound} {
+			req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/auth/tokens/%s", id), nil)
+			req = mux.SetURLVars(req, map[string]string{"id": id})
+			req = asAuthor(req)
+			res := httptest.NewRecorder()
+			a.RevokeToken(res, req)
+
+			require.Equal(t, res.Code, code)
+		}
+	})
+
+	t.Run("return 401 if not authenticated", func(t *testing.T) {
+		a := &Authenticator{Service: &MockService{}, Secret: secret}
+		req := httptest.NewRequest(http.MethodGet, "/auth/tokens", nil)
+		res := httptest.NewRecorder()
+		a.ListTokens(res, req)
+
+		require.Equal(t, res.Code, http.StatusUnauthorized)
+	})
+}
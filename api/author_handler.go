@@ -0,0 +1,416 @@
+package main
+
+import (
+	repo "blog/repo"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// authorBody is the body of the requests adding or updating an author.
+type authorBody struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// authorPageArticles is the number of recent articles on the page of an author.
+const authorPageArticles = 5
+
+// authorPage is the public page of an author: its profile, its most recent published articles
+// and the number of articles it published.
+type authorPage struct {
+	Author       repo.Author    `json:"author"`
+	Articles     []repo.Article `json:"articles"`
+	ArticleCount int            `json:"article_count"`
+}
+
+// authorView returns the author as shown to the caller of the request: the email is hidden
+// from anonymous callers and from the authors and readers other than the author itself,
+// the markdown bio is rendered to html.
+func authorView(ctx context.Context, a repo.Author) repo.Author {
+	p, ok := PrincipalFrom(ctx)
+	if !ok || (p.AuthorId != a.Id && authorize(p, ActionViewEmails, Resource{OwnerId: a.Id}) != nil) {
+		a.Email = ""
+	}
+	a.BioHTML = repo.RenderMarkdown(a.Bio)
+	return a
+}
+
+func (h *BlogServer) ListAuthors(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	authors, err := h.Service.ListAuthors(ctx)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	for i := range authors {
+		authors[i] = authorView(ctx, authors[i])
+	}
+
+	data, err := json.Marshal(authors)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (h *BlogServer) GetAuthorById(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	author, err := h.Service.GetAuthorById(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(authorView(ctx, author))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// ListAuthorArticles lists the articles of an author, with the query parameters of ListArticles.
+func (h *BlogServer) ListAuthorArticles(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	q, err := parseArticleQuery(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	// an unknown author is not found rather than without articles
+	_, err = h.Service.GetAuthorById(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	q.AuthorId = id.String()
+	q.AuthorEmail = ""
+	h.listArticles(w, r, q)
+}
+
+// GetAuthorPage returns the profile of an author with its most recent published articles.
+func (h *BlogServer) GetAuthorPage(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	author, err := h.Service.GetAuthorById(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	author = authorView(ctx, author)
+
+	// the total of the page is the number of published articles
+	q := repo.ArticleQuery{AuthorId: author.Id, Status: repo.StatusPublished, Limit: authorPageArticles}
+	page, err := h.Service.ListArticles(ctx, q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	for i := range page.Articles {
+		page.Articles[i].Author = author
+		renderBody(&page.Articles[i])
+	}
+
+	data, err := json.Marshal(authorPage{Author: author, Articles: page.Articles, ArticleCount: page.Total})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// AddAuthor adds an author without credentials, the body is of the form {"name": "...", "email": "..."}.
+func (h *BlogServer) AddAuthor(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	err := authorize(p, ActionManageAuthors, Resource{})
+	if err != nil {
+		forbid(w, r, err)
+		return
+	}
+
+	var body authorBody
+	err = decodeBody(w, r, &body)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var v validator
+	v.author("", body.Name, body.Email)
+	err = v.err()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	id, err := h.Service.AddAuthor(ctx, repo.Author{Name: body.Name, Email: body.Email})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// UpdateAuthor replaces the name and email of an author, the body is of the form {"name": "...", "email": "..."}.
+func (h *BlogServer) UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	err := authorize(p, ActionManageAuthors, Resource{})
+	if err != nil {
+		forbid(w, r, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	var body authorBody
+	err = decodeBody(w, r, &body)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var v validator
+	v.author("", body.Name, body.Email)
+	err = v.err()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.Service.UpdateAuthor(ctx, repo.Author{Id: id.String(), Name: body.Name, Email: body.Email})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// SetAuthorProfile replaces the profile of an author, the body is the profile. Authors edit their own
+// profile, admins the profile of any author.
+func (h *BlogServer) SetAuthorProfile(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	if p.AuthorId != id.String() {
+		err = authorize(p, ActionManageAuthors, Resource{OwnerId: id.String()})
+		if err != nil {
+			forbid(w, r, err)
+			return
+		}
+	}
+
+	var profile repo.Profile
+	err = decodeBody(w, r, &profile)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var v validator
+	v.profile(profile)
+	err = v.err()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.Service.SetAuthorProfile(ctx, id.String(), profile)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (h *BlogServer) DeleteAuthorByNameAndEmail(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	err := authorize(p, ActionManageAuthors, Resource{})
+	if err != nil {
+		forbid(w, r, err)
+		return
+	}
+
+	name := r.FormValue("name")
+	email := r.FormValue("email")
+
+	err = h.Service.DeleteAuthorByNameAndEmail(ctx, name, email)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (h *BlogServer) DeleteAuthorById(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	err := authorize(p, ActionManageAuthors, Resource{})
+	if err != nil {
+		forbid(w, r, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	err = h.Service.DeleteAuthorById(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// SetAuthorRole grants a role to an author, the body is of the form {"role": "editor"}.
+func (h *BlogServer) SetAuthorRole(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	err := authorize(p, ActionManageAuthors, Resource{})
+	if err != nil {
+		forbid(w, r, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+	err = decodeBody(w, r, &body)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !repo.ValidRole(body.Role) {
+		writeError(w, r, repo.InvalidField("role", "must be one of admin, editor, author or reader"))
+		return
+	}
+
+	err = h.Service.SetAuthorRole(ctx, id.String(), body.Role)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,615 @@
+package main
+
+import (
+	repo "blog/repo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAuthors(t *testing.T) {
+
+	t.Run("can list authors", func(t *testing.T) {
+		r := &MockService{
+			ListAuthorsFunc: func() ([]repo.Author, error) {
+				return []repo.Author{author}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/authors", nil)
+		res := httptest.NewRecorder()
+		h.ListAuthors(res, req)
+
+		var authors []repo.Author
+		json.Unmarshal(res.Body.Bytes(), &authors) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, authors, []repo.Author{publicAuthor()})
+	})
+
+	t.Run("editors see author emails", func(t *testing.T) {
+		r := &MockService{
+			ListAuthorsFunc: func() ([]repo.Author, error) {
+				return []repo.Author{author}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/authors", nil)
+		req = asRole(req, otherAuthorId, repo.RoleEditor)
+		res := httptest.NewRecorder()
+		h.ListAuthors(res, req)
+
+		var authors []repo.Author
+		json.Unmarshal(res.Body.Bytes(), &authors) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, authors, []repo.Author{author})
+	})
+
+	t.Run("return 503 if service fails", func(t *testing.T) {
+		r := &MockService{
+			ListAuthorsFunc: func() ([]repo.Author, error) {
+				return nil, repo.Unavailable(errors.New("service fails"))
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/authors", nil)
+		res := httptest.NewRecorder()
+		h.ListAuthors(res, req)
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+	})
+}
+
+func TestGetAuthorById(t *testing.T) {
+
+	get := func(r *MockService, id string, as ...func(*http.Request) *http.Request) *httptest.ResponseRecorder {
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/authors/%s", id), nil)
+		for _, f := range as {
+			req = f(req)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		res := httptest.NewRecorder()
+		h.GetAuthorById(res, req)
+		return res
+	}
+
+	r := &MockService{
+		GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+			require.Equal(t, id, author.Id)
+			return author, nil
+		},
+	}
+
+	t.Run("can get author by id", func(t *testing.T) {
+		res := get(r, author.Id)
+		var a repo.Author
+		json.Unmarshal(res.Body.Bytes(), &a) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, a, publicAuthor())
+	})
+
+	t.Run("authors see their own email", func(t *testing.T) {
+		res := get(r, author.Id, asAuthor)
+		var a repo.Author
+		json.Unmarshal(res.Body.Bytes(), &a) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, a, author)
+	})
+
+	t.Run("authors do not see the email of others", func(t *testing.T) {
+		res := get(r, author.Id, func(req *http.Request) *http.Request {
+			return asRole(req, otherAuthorId, repo.RoleAuthor)
+		})
+		var a repo.Author
+		json.Unmarshal(res.Body.Bytes(), &a) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, a, publicAuthor())
+	})
+
+	t.Run("bio is rendered to html", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				a := author
+				a.Bio = "Writes *tests*.<script>alert(1)</script>"
+				return a, nil
+			},
+		}
+
+		res := get(r, author.Id)
+		var a repo.Author
+		json.Unmarshal(res.Body.Bytes(), &a) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, a.BioHTML, "<p>Writes <em>tests</em>.</p>\n")
+	})
+
+	t.Run("return 400 if id is not valid", func(t *testing.T) {
+		res := get(&MockService{}, "invalid-uuid")
+		require.Equal(t, res.Code, http.StatusBadRequest)
+	})
+
+	t.Run("return 404 if author not found", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				return repo.Author{}, repo.ErrAuthorNotFound
+			},
+		}
+
+		res := get(r, author.Id)
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+}
+
+func TestListAuthorArticles(t *testing.T) {
+
+	t.Run("can list articles of author", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				return author, nil
+			},
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				require.Equal(t, q.AuthorId, author.Id)
+				require.Equal(t, q.Status, repo.StatusPublished)
+				require.Equal(t, q.Limit, 1)
+				return repo.ArticlePage{Articles: []repo.Article{article}, Total: 1}, nil
+			},
+			GetAuthorsByIdsFunc: func(ids []string) ([]repo.Author, error) {
+				return []repo.Author{author}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/authors/%s/articles?limit=1", author.Id), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+		h.ListAuthorArticles(res, req)
+
+		var page repo.ArticlePage
+		json.Unmarshal(res.Body.Bytes(), &page) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Len(t, page.Articles, 1)
+		require.Equal(t, page.Articles[0].Author, publicAuthor())
+	})
+
+	t.Run("return 404 if author not found", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				return repo.Author{}, repo.ErrAuthorNotFound
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/authors/%s/articles", author.Id), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+		h.ListAuthorArticles(res, req)
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+}
+
+func TestGetAuthorPage(t *testing.T) {
+
+	get := func(r *MockService) *httptest.ResponseRecorder {
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/authors/%s/page", author.Id), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+		h.GetAuthorPage(res, req)
+		return res
+	}
+
+	t.Run("can get author page", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				return author, nil
+			},
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				require.Equal(t, q.AuthorId, author.Id)
+				require.Equal(t, q.Status, repo.StatusPublished)
+				require.Equal(t, q.Limit, authorPageArticles)
+				return repo.ArticlePage{Articles: []repo.Article{article}, Total: 7}, nil
+			},
+		}
+
+		res := get(r)
+		var page authorPage
+		json.Unmarshal(res.Body.Bytes(), &page) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, page.Author, publicAuthor())
+		require.Len(t, page.Articles, 1)
+		require.Equal(t, page.Articles[0].Author, publicAuthor())
+		require.Equal(t, page.ArticleCount, 7)
+	})
+
+	t.Run("return 404 if author not found", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				return repo.Author{}, repo.ErrAuthorNotFound
+			},
+		}
+
+		res := get(r)
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+
+	t.Run("return 503 if service fails", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				return author, nil
+			},
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				return repo.ArticlePage{}, repo.Unavailable(errors.New("service fails"))
+			},
+		}
+
+		res := get(r)
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+	})
+}
+
+func TestSetAuthorProfile(t *testing.T) {
+
+	profile := repo.Profile{
+		DisplayName: "Test",
+		Bio:         "Writes *tests*.",
+		AvatarURL:   "https://example.com/avatar.png",
+		Website:     "https://example.com",
+		Social:      repo.SocialLinks{"github": "test"},
+	}
+
+	put := func(r *MockService, body io.Reader, as func(*http.Request) *http.Request) *httptest.ResponseRecorder {
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/authors/%s/profile", author.Id), body)
+		req = as(req)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+		h.SetAuthorProfile(res, req)
+		return res
+	}
+
+	asAdmin := func(req *http.Request) *http.Request {
+		return asRole(req, otherAuthorId, repo.RoleAdmin)
+	}
+
+	t.Run("authors can set their own profile", func(t *testing.T) {
+		r := &MockService{
+			SetAuthorProfileFunc: func(id string, p repo.Profile) error {
+				require.Equal(t, id, author.Id)
+				require.Equal(t, p, profile)
+				return nil
+			},
+		}
+
+		res := put(r, toJson(profile), asAuthor)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("admins can set any profile", func(t *testing.T) {
+		r := &MockService{
+			SetAuthorProfileFunc: func(id string, p repo.Profile) error {
+				return nil
+			},
+		}
+
+		res := put(r, toJson(profile), asAdmin)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 403 if not the author", func(t *testing.T) {
+		res := put(&MockService{}, toJson(profile), func(req *http.Request) *http.Request {
+			return asRole(req, otherAuthorId, repo.RoleEditor)
+		})
+		require.Equal(t, res.Code, http.StatusForbidden)
+	})
+
+	t.Run("return 422 if profile is not valid", func(t *testing.T) {
+		for _, body := range []string{
+			`{"avatar_url": "javascript:alert(1)"}`,
+			`{"website": "example.com"}`,
+			`{"social": {"": "test"}}`,
+			`{"social": {"github": " "}}`,
+			`{"bio": 1}`,
+		} {
+			res := put(&MockService{}, strings.NewReader(body), asAuthor)
+			require.Equal(t, res.Code, http.StatusUnprocessableEntity, body)
+		}
+	})
+
+	t.Run("return 404 if author not found", func(t *testing.T) {
+		r := &MockService{
+			SetAuthorProfileFunc: func(id string, p repo.Profile) error {
+				return repo.ErrAuthorNotFound
+			},
+		}
+
+		res := put(r, toJson(profile), asAdmin)
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+}
+
+func TestAddAuthor(t *testing.T) {
+
+	add := func(r *MockService, body string, role string) *httptest.ResponseRecorder {
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, "/authors", strings.NewReader(body))
+		req = asRole(req, otherAuthorId, role)
+		res := httptest.NewRecorder()
+		h.AddAuthor(res, req)
+		return res
+	}
+
+	t.Run("can add author", func(t *testing.T) {
+		r := &MockService{
+			AddAuthorFunc: func(a repo.Author) (string, error) {
+				return expectedAuthorId, nil
+			},
+		}
+
+		res := add(r, `{"name": "test", "email": "test@email.com"}`, repo.RoleAdmin)
+		var id string
+		json.Unmarshal(res.Body.Bytes(), &id) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, id, expectedAuthorId)
+		require.Equal(t, r.Authors, []repo.Author{{Name: "test", Email: "test@email.com"}})
+	})
+
+	t.Run("return 422 if name or email is missing", func(t *testing.T) {
+		res := add(&MockService{}, `{"name": "test"}`, repo.RoleAdmin)
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("return 409 if author exists", func(t *testing.T) {
+		r := &MockService{
+			AddAuthorFunc: func(a repo.Author) (string, error) {
+				return "", repo.ErrAuthorExists
+			},
+		}
+
+		res := add(r, `{"name": "test", "email": "test@email.com"}`, repo.RoleAdmin)
+		require.Equal(t, res.Code, http.StatusConflict)
+	})
+
+	t.Run("return 403 if not admin", func(t *testing.T) {
+		res := add(&MockService{}, `{"name": "test", "email": "test@email.com"}`, repo.RoleEditor)
+		require.Equal(t, res.Code, http.StatusForbidden)
+	})
+}
+
+func TestUpdateAuthor(t *testing.T) {
+
+	update := func(r *MockService, body string) *httptest.ResponseRecorder {
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/authors/%s", author.Id), strings.NewReader(body))
+		req = asRole(req, otherAuthorId, repo.RoleAdmin)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+		h.UpdateAuthor(res, req)
+		return res
+	}
+
+	t.Run("can update author", func(t *testing.T) {
+		r := &MockService{
+			UpdateAuthorFunc: func(a repo.Author) error {
+				require.Equal(t, a, repo.Author{Id: author.Id, Name: "new name", Email: "new@email.com"})
+				return nil
+			},
+		}
+
+		res := update(r, `{"name": "new name", "email": "new@email.com"}`)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 404 if author not found", func(t *testing.T) {
+		r := &MockService{
+			UpdateAuthorFunc: func(a repo.Author) error {
+				return repo.ErrAuthorNotFound
+			},
+		}
+
+		res := update(r, `{"name": "new name", "email": "new@email.com"}`)
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+
+	t.Run("return 409 if email is taken", func(t *testing.T) {
+		r := &MockService{
+			UpdateAuthorFunc: func(a repo.Author) error {
+				return repo.ErrAuthorExists
+			},
+		}
+
+		res := update(r, `{"name": "new name", "email": "other@email.com"}`)
+		require.Equal(t, res.Code, http.StatusConflict)
+	})
+
+	t.Run("return 403 if not admin", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/authors/%s", author.Id), strings.NewReader(`{"name": "new name", "email": "new@email.com"}`))
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+
+		h.UpdateAuthor(res, req)
+		require.Equal(t, res.Code, http.StatusForbidden)
+	})
+}
+
+func TestDeleteAuthorByNameAndEmail(t *testing.T) {
+
+	t.Run("can delete article by name and email", func(t *testing.T) {
+		r := &MockService{
+			DeleteAuthorByNameAndEmailFunc: func(name string, email string) error {
+				require.Equal(t, name, author.Name)
+				require.Equal(t, email, author.Email)
+				return nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles?name=%s&email=%s", author.Name, author.Email), nil)
+		req = asRole(req, otherAuthorId, repo.RoleAdmin)
+		res := httptest.NewRecorder()
+
+		h.DeleteAuthorByNameAndEmail(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 404 if article not found", func(t *testing.T) {
+		r := &MockService{
+			DeleteAuthorByNameAndEmailFunc: func(name string, email string) error {
+				require.Equal(t, name, author.Name)
+				require.Equal(t, email, author.Email)
+				return repo.ErrAuthorNotFound
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles?name=%s&email=%s", author.Name, author.Email), nil)
+		req = asRole(req, otherAuthorId, repo.RoleAdmin)
+		res := httptest.NewRecorder()
+
+		h.DeleteAuthorByNameAndEmail(res, req)
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+
+	t.Run("return 503 if service fails", func(t *testing.T) {
+		r := &MockService{
+			DeleteAuthorByNameAndEmailFunc: func(name string, email string) error {
+				require.Equal(t, name, author.Name)
+				require.Equal(t, email, author.Email)
+				return repo.Unavailable(errors.New("service fails"))
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles?name=%s&email=%s", author.Name, author.Email), nil)
+		req = asRole(req, otherAuthorId, repo.RoleAdmin)
+		res := httptest.NewRecorder()
+
+		h.DeleteAuthorByNameAndEmail(res, req)
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+	})
+
+	t.Run("return 403 if not admin", func(t *testing.T) {
+		for _, role := range []string{repo.RoleEditor, repo.RoleAuthor, repo.RoleReader} {
+			h := BlogServer{Service: &MockService{}}
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles?name=%s&email=%s", author.Name, author.Email), nil)
+			req = asRole(req, author.Id, role)
+			res := httptest.NewRecorder()
+
+			h.DeleteAuthorByNameAndEmail(res, req)
+			require.Equal(t, res.Code, http.StatusForbidden, role)
+		}
+	})
+}
+
+func TestDeleteAuthorById(t *testing.T) {
+
+	t.Run("can delete author by id", func(t *testing.T) {
+		r := &MockService{
+			DeleteAuthorByIdFunc: func(id string) error {
+				require.Equal(t, id, author.Id)
+				return nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/authors/%s", author.Id), nil)
+		req = asRole(req, otherAuthorId, repo.RoleAdmin)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+
+		h.DeleteAuthorById(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 404 if author not found", func(t *testing.T) {
+		r := &MockService{
+			DeleteAuthorByIdFunc: func(id string) error {
+				return repo.ErrAuthorNotFound
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/authors/%s", author.Id), nil)
+		req = asRole(req, otherAuthorId, repo.RoleAdmin)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+
+		h.DeleteAuthorById(res, req)
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+
+	t.Run("return 403 if not admin", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/authors/%s", author.Id), nil)
+		req = asRole(req, author.Id, repo.RoleEditor)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+
+		h.DeleteAuthorById(res, req)
+		require.Equal(t, res.Code, http.StatusForbidden)
+		require.Contains(t, res.Body.String(), "only admins can manage authors")
+	})
+}
+
+func TestSetAuthorRole(t *testing.T) {
+
+	t.Run("can set author role", func(t *testing.T) {
+		r := &MockService{
+			SetAuthorRoleFunc: func(id string, role string) error {
+				require.Equal(t, id, author.Id)
+				require.Equal(t, role, repo.RoleEditor)
+				return nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/authors/%s/role", author.Id), strings.NewReader(`{"role": "editor"}`))
+		req = asRole(req, otherAuthorId, repo.RoleAdmin)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+
+		h.SetAuthorRole(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 422 if role is not valid", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/authors/%s/role", author.Id), strings.NewReader(`{"role": "owner"}`))
+		req = asRole(req, otherAuthorId, repo.RoleAdmin)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+
+		h.SetAuthorRole(res, req)
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("return 403 if not admin", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/authors/%s/role", author.Id), strings.NewReader(`{"role": "admin"}`))
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": author.Id})
+		res := httptest.NewRecorder()
+
+		h.SetAuthorRole(res, req)
+		require.Equal(t, res.Code, http.StatusForbidden)
+	})
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	repo "blog/repo"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// commentBody is the body of the requests adding a comment, the other fields
+// of the comment are set by the server.
+type commentBody struct {
+	ParentId    string `json:"parent_id"`
+	AuthorName  string `json:"author_name"`
+	AuthorEmail string `json:"author_email"`
+	Body        string `json:"body"`
+}
+
+// comment returns the comment of the body.
+func (b commentBody) comment() repo.Comment {
+	return repo.Comment{ParentId: b.ParentId, AuthorName: b.AuthorName, AuthorEmail: b.AuthorEmail, Body: b.Body}
+}
+
+func (h *BlogServer) ListComments(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	// the article must exist and be published
+	article, err := h.Service.GetArticleById(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if article.Status != repo.StatusPublished {
+		writeError(w, r, repo.ErrArticleNotFound)
+		return
+	}
+
+	comments, err := h.Service.ListComments(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(buildCommentTree(comments))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (h *BlogServer) AddComment(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	var body commentBody
+
+	// decode the request body, only the fields the client may write
+	err = decodeBody(w, r, &body)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	comment := body.comment()
+
+	var v validator
+	v.comment(comment)
+	err = v.err()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// the article must exist and be published
+	article, err := h.Service.GetArticleById(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if article.Status != repo.StatusPublished {
+		writeError(w, r, repo.ErrArticleNotFound)
+		return
+	}
+
+	comment.ArticleId = id.String()
+
+	// a reply must be on a comment of the same article, within the maximum depth
+	if comment.ParentId != "" {
+		parentId, err := uuid.Parse(comment.ParentId)
+		if err != nil {
+			writeError(w, r, repo.InvalidField("parent_id", "is not a valid uuid"))
+			return
+		}
+
+		parent, err := h.Service.GetCommentById(ctx, parentId.String())
+		if err != nil {
+			if errors.Is(err, repo.ErrCommentNotFound) {
+				writeError(w, r, repo.InvalidField("parent_id", "is not a comment"))
+				return
+			}
+			writeError(w, r, err)
+			return
+		}
+		if parent.ArticleId != comment.ArticleId {
+			writeError(w, r, repo.InvalidField("parent_id", "is a comment of another article"))
+			return
+		}
+		if parent.Depth >= h.maxCommentDepth() {
+			writeError(w, r, repo.InvalidField("parent_id", "is at the maximum reply depth"))
+			return
+		}
+
+		comment.ParentId = parent.Id
+		comment.Depth = parent.Depth + 1
+	}
+
+	c, err := h.Service.AddComment(ctx, comment)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (h *BlogServer) DeleteCommentById(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	// comments are moderated by the author of their article
+	comment, err := h.Service.GetCommentById(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if _, ok := h.authorizeArticle(w, r, p, comment.ArticleId, ActionDeleteComment); !ok {
+		return
+	}
+
+	err = h.Service.DeleteCommentById(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// maxCommentDepth returns the configured maximum reply depth, or the default one.
+func (h *BlogServer) maxCommentDepth() int {
+	if h.MaxCommentDepth <= 0 {
+		return DefaultMaxCommentDepth
+	}
+	return h.MaxCommentDepth
+}
+
+// buildCommentTree nests the given comments under their parents and returns the top-level ones.
+// Comments keep their relative order, emails of the commenters are not published.
+func buildCommentTree(comments []repo.Comment) []repo.Comment {
+
+	children := make(map[string][]repo.Comment)
+	for _, c := range comments {
+		c.AuthorEmail = ""
+		children[c.ParentId] = append(children[c.ParentId], c)
+	}
+
+	var replies func(parentId string) []repo.Comment
+	replies = func(parentId string) []repo.Comment {
+		nodes := children[parentId]
+		for i := range nodes {
+			nodes[i].Replies = replies(nodes[i].Id)
+		}
+		return nodes
+	}
+
+	tree := replies("")
+	if tree == nil {
+		tree = make([]repo.Comment, 0)
+	}
+	return tree
+}
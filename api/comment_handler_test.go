@@ -0,0 +1,263 @@
+package main
+
+import (
+	repo "blog/repo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+var comment = repo.Comment{
+	AuthorName:  "reader",
+	AuthorEmail: "reader@email.com",
+	Body:        "test",
+}
+var expectedCommentId = "b4a4de9e-2f52-4cf1-8907-3d828d403128"
+
+func TestListComments(t *testing.T) {
+
+	t.Run("can get comments as a tree", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: func(id string) (repo.Article, error) {
+				return article, nil
+			},
+			ListCommentsFunc: func(articleId string) ([]repo.Comment, error) {
+				require.Equal(t, articleId, expectedArticleId)
+				return []repo.Comment{
+					{Id: "1", Body: "first", AuthorEmail: "reader@email.com"},
+					{Id: "2", Body: "second"},
+					{Id: "3", ParentId: "1", Body: "reply", Depth: 1},
+					{Id: "4", ParentId: "3", Body: "reply to reply", Depth: 2},
+				}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s/comments", expectedArticleId), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+		h.ListComments(res, req)
+
+		var c []repo.Comment
+		json.Unmarshal(res.Body.Bytes(), &c) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Len(t, c, 2)
+		require.Empty(t, c[0].AuthorEmail)
+		require.Len(t, c[0].Replies, 1)
+		require.Equal(t, c[0].Replies[0].Body, "reply")
+		require.Len(t, c[0].Replies[0].Replies, 1)
+		require.Equal(t, c[0].Replies[0].Replies[0].Body, "reply to reply")
+		require.Empty(t, c[1].Replies)
+	})
+
+	t.Run("return 404 if article not found", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: func(id string) (repo.Article, error) {
+				return repo.Article{}, repo.ErrArticleNotFound
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s/comments", expectedArticleId), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+		h.ListComments(res, req)
+
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+
+	t.Run("return 403 if the article is someone else's", func(t *testing.T) {
+		r := &MockService{
+			GetCommentByIdFunc: func(id string) (repo.Comment, error) {
+				return repo.Comment{Id: id, ArticleId: expectedArticleId}, nil
+			},
+			GetArticleByIdFunc: getOwnArticle,
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/comments/%s", expectedCommentId), nil)
+		req = asRole(req, otherAuthorId, repo.RoleAuthor)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedCommentId})
+		res := httptest.NewRecorder()
+		h.DeleteCommentById(res, req)
+
+		require.Equal(t, res.Code, http.StatusForbidden)
+	})
+}
+
+func TestAddComment(t *testing.T) {
+
+	newService := func(parent repo.Comment) *MockService {
+		return &MockService{
+			GetArticleByIdFunc: func(id string) (repo.Article, error) {
+				return article, nil
+			},
+			GetCommentByIdFunc: func(id string) (repo.Comment, error) {
+				if id != parent.Id {
+					return repo.Comment{}, repo.ErrCommentNotFound
+				}
+				return parent, nil
+			},
+			AddCommentFunc: func(c repo.Comment) (string, error) {
+				return expectedCommentId, nil
+			},
+		}
+	}
+
+	post := func(h BlogServer, c repo.Comment) *httptest.ResponseRecorder {
+		body := commentBody{ParentId: c.ParentId, AuthorName: c.AuthorName, AuthorEmail: c.AuthorEmail, Body: c.Body}
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/articles/%s/comments", expectedArticleId), toJson(body))
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+		h.AddComment(res, req)
+		return res
+	}
+
+	t.Run("can add top-level comment", func(t *testing.T) {
+		r := newService(repo.Comment{})
+		res := post(BlogServer{Service: r}, comment)
+
+		var id string
+		json.Unmarshal(res.Body.Bytes(), &id) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, id, expectedCommentId)
+		require.Len(t, r.Comments, 1)
+		require.Equal(t, r.Comments[0].ArticleId, expectedArticleId)
+		require.Equal(t, r.Comments[0].Depth, 0)
+	})
+
+	t.Run("can reply to a comment", func(t *testing.T) {
+		r := newService(repo.Comment{Id: expectedCommentId, ArticleId: expectedArticleId, Depth: 1})
+		reply := comment
+		reply.ParentId = expectedCommentId
+		res := post(BlogServer{Service: r}, reply)
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Len(t, r.Comments, 1)
+		require.Equal(t, r.Comments[0].Depth, 2)
+	})
+
+	t.Run("return 422 if maximum depth reached", func(t *testing.T) {
+		r := newService(repo.Comment{Id: expectedCommentId, ArticleId: expectedArticleId, Depth: 2})
+		reply := comment
+		reply.ParentId = expectedCommentId
+		res := post(BlogServer{Service: r, MaxCommentDepth: 2}, reply)
+
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+		require.Empty(t, r.Comments)
+	})
+
+	t.Run("return 422 if parent belongs to another article", func(t *testing.T) {
+		r := newService(repo.Comment{Id: expectedCommentId, ArticleId: expectedAuthorId})
+		reply := comment
+		reply.ParentId = expectedCommentId
+		res := post(BlogServer{Service: r}, reply)
+
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("return 422 if parent not found", func(t *testing.T) {
+		r := newService(repo.Comment{Id: expectedCommentId})
+		reply := comment
+		reply.ParentId = expectedAuthorId
+		res := post(BlogServer{Service: r}, reply)
+
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("return 422 if body is missing", func(t *testing.T) {
+		res := post(BlogServer{Service: &MockService{}}, repo.Comment{AuthorName: "reader", AuthorEmail: "reader@email.com"})
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("return 422 if body has fields set by the server", func(t *testing.T) {
+		body := `{"author_name": "reader", "author_email": "reader@email.com", "body": "test", "article_id": "` + otherAuthorId + `"}`
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/articles/%s/comments", expectedArticleId), strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+		h := BlogServer{Service: newService(repo.Comment{})}
+		h.AddComment(res, req)
+
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+		require.Contains(t, res.Body.String(), "article_id")
+	})
+
+	t.Run("return 503 if add comment fails", func(t *testing.T) {
+		r := newService(repo.Comment{})
+		r.AddCommentFunc = func(c repo.Comment) (string, error) {
+			return "", repo.Unavailable(errors.New("couldn't add comment"))
+		}
+		res := post(BlogServer{Service: r}, comment)
+
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+	})
+}
+
+func TestDeleteCommentById(t *testing.T) {
+
+	t.Run("can delete comment by id", func(t *testing.T) {
+		r := &MockService{
+			GetCommentByIdFunc: func(id string) (repo.Comment, error) {
+				return repo.Comment{Id: id, ArticleId: expectedArticleId}, nil
+			},
+			GetArticleByIdFunc: getOwnArticle,
+			DeleteCommentByIdFunc: func(id string) error {
+				require.Equal(t, id, expectedCommentId)
+				return nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/comments/%s", expectedCommentId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedCommentId})
+		res := httptest.NewRecorder()
+		h.DeleteCommentById(res, req)
+
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 404 if comment not found", func(t *testing.T) {
+		r := &MockService{
+			GetCommentByIdFunc: func(id string) (repo.Comment, error) {
+				return repo.Comment{}, repo.ErrCommentNotFound
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/comments/%s", expectedCommentId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedCommentId})
+		res := httptest.NewRecorder()
+		h.DeleteCommentById(res, req)
+
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+
+	t.Run("return 403 if the article is someone else's", func(t *testing.T) {
+		r := &MockService{
+			GetCommentByIdFunc: func(id string) (repo.Comment, error) {
+				return repo.Comment{Id: id, ArticleId: expectedArticleId}, nil
+			},
+			GetArticleByIdFunc: getOwnArticle,
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/comments/%s", expectedCommentId), nil)
+		req = asRole(req, otherAuthorId, repo.RoleAuthor)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedCommentId})
+		res := httptest.NewRecorder()
+		h.DeleteCommentById(res, req)
+
+		require.Equal(t, res.Code, http.StatusForbidden)
+	})
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	repo "blog/repo"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// DefaultFeedSize is the number of most recent articles in a feed when none is configured.
+const DefaultFeedSize = 20
+
+// DefaultFeedTitle is the title of the feeds when none is configured.
+const DefaultFeedTitle = "Blog"
+
+// feed holds the most recent published articles of the blog or of an author, with their authors.
+type feed struct {
+	Title string
+	// Base is the public url of the blog, Link the page of the feed and Self the url of the feed.
+	Base     string
+	Link     string
+	Self     string
+	Author   *repo.Author
+	Articles []repo.Article
+	// Updated is the last time an article of the feed changed, zero for an empty feed.
+	Updated time.Time
+}
+
+// rssFeed is the RSS 2.0 document of a feed.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// atomFeed is the Atom document of a feed.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  *atomPerson `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Id         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Link       atomLink       `xml:"link"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+// FeedRSS returns the RSS feed of the most recent published articles.
+func (h *BlogServer) FeedRSS(w http.ResponseWriter, r *http.Request) {
+
+	f, err := h.loadFeed(r, "")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	serveFeed(w, r, "application/rss+xml; charset=utf-8", f.rss())
+}
+
+// FeedAtom returns the Atom feed of the most recent published articles.
+func (h *BlogServer) FeedAtom(w http.ResponseWriter, r *http.Request) {
+
+	f, err := h.loadFeed(r, "")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	serveFeed(w, r, "application/atom+xml; charset=utf-8", f.atom())
+}
+
+// AuthorFeedAtom returns the Atom feed of the most recent published articles of an author.
+func (h *BlogServer) AuthorFeedAtom(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	f, err := h.loadFeed(r, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	serveFeed(w, r, "application/atom+xml; charset=utf-8", f.atom())
+}
+
+// serveFeed writes the feed document with its ETag, the conditional requests
+// of feed readers whose copy is still fresh are answered with 304 Not Modified.
+func serveFeed(w http.ResponseWriter, r *http.Request, contentType string, v interface{}) {
+
+	data, err := xml.Marshal(v)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	data = append([]byte(xml.Header), data...)
+
+	// the document changes with any of its articles or authors, its hash tells them apart
+	sum := sha256.Sum256(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// there is no Last-Modified: the newest article left in the feed does not date the removal
+	// of the others, so If-Modified-Since would keep stale feeds, ServeContent handles If-None-Match
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// loadFeed returns the feed of the blog, or of the author with the given id if any.
+func (h *BlogServer) loadFeed(r *http.Request, authorId string) (feed, error) {
+
+	ctx := r.Context()
+	base := h.feedURL(r)
+
+	f := feed{
+		Title: h.FeedTitle,
+		Base:  base,
+		Link:  base,
+		Self:  base + r.URL.Path,
+	}
+	if f.Title == "" {
+		f.Title = DefaultFeedTitle
+	}
+
+	if authorId != "" {
+		author, err := h.Service.GetAuthorById(ctx, authorId)
+		if err != nil {
+			return feed{}, err
+		}
+		author = authorView(ctx, author)
+		f.Author = &author
+		f.Title += " - " + authorName(author)
+		f.Link = base + "/authors/" + author.Id + "/page"
+	}
+
+	size := h.FeedSize
+	if size <= 0 {
+		size = DefaultFeedSize
+	}
+
+	page, err := h.Service.ListArticles(ctx, repo.ArticleQuery{Status: repo.StatusPublished, AuthorId: authorId, Limit: size})
+	if err != nil {
+		return feed{}, err
+	}
+
+	ids := make([]string, 0, len(page.Articles))
+	for _, a := range page.Articles {
+		ids = append(ids, a.Author.Id)
+	}
+	authors, err := h.getAuthorMap(ctx, ids)
+	if err != nil {
+		return feed{}, err
+	}
+
+	for i := range page.Articles {
+		a := &page.Articles[i]
+		a.Author = authors[a.Author.Id]
+		renderBody(a)
+		if a.UpdatedAt.After(f.Updated) {
+			f.Updated = a.UpdatedAt
+		}
+	}
+	f.Articles = page.Articles
+
+	return f, nil
+}
+
+// feedURL returns the public url of the blog, the configured one or else the one of the request.
+func (h *BlogServer) feedURL(r *http.Request) string {
+
+	if h.FeedURL != "" {
+		return strings.TrimSuffix(h.FeedURL, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// updated returns the time the feed last changed, truncated to the second of http dates.
+func (f feed) updated() time.Time {
+	if f.Updated.IsZero() {
+		return f.Updated
+	}
+	return f.Updated.UTC().Truncate(time.Second)
+}
+
+// articleURL returns the permalink of an article of the feed, by slug if it has one.
+func (f feed) articleURL(a repo.Article) string {
+	if a.Slug != "" {
+		return f.Base + "/articles/by-slug/" + url.PathEscape(a.Slug)
+	}
+	return f.Base + "/articles/" + a.Id
+}
+
+// rss returns the RSS 2.0 document of the feed.
+func (f feed) rss() rssFeed {
+
+	doc := rssFeed{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: "The most recent articles of " + f.Title + ".",
+			Self:        atomLink{Href: f.Self, Rel: "self", Type: "application/rss+xml"},
+			Items:       make([]rssItem, 0, len(f.Articles)),
+		},
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.updated().Format(time.RFC1123Z)
+	}
+
+	for _, a := range f.Articles {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       a.Title,
+			Link:        f.articleURL(a),
+			GUID:        rssGUID{Value: "urn:uuid:" + a.Id},
+			PubDate:     a.PostedAt.UTC().Format(time.RFC1123Z),
+			Creator:     authorName(a.Author),
+			Categories:  a.Tags,
+			Description: a.BodyHTML,
+		})
+	}
+
+	return doc
+}
+
+// atom returns the Atom document of the feed. Atom requires the time of every feed,
+// the empty feeds are dated at the epoch so that their document does not change.
+func (f feed) atom() atomFeed {
+
+	doc := atomFeed{
+		Id:      f.Self,
+		Title:   f.Title,
+		Updated: f.updated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate"},
+		},
+		Entries: make([]atomEntry, 0, len(f.Articles)),
+	}
+	if f.Updated.IsZero() {
+		doc.Updated = time.Unix(0, 0).UTC().Format(time.RFC3339)
+	}
+	if f.Author != nil {
+		doc.Author = &atomPerson{Name: authorName(*f.Author), URI: f.Author.Website}
+	}
+
+	for _, a := range f.Articles {
+		categories := make([]atomCategory, 0, len(a.Tags))
+		for _, t := range a.Tags {
+			categories = append(categories, atomCategory{Term: t})
+		}
+		doc.Entries = append(doc.Entries, atomEntry{
+			Id:         "urn:uuid:" + a.Id,
+			Title:      a.Title,
+			Updated:    a.UpdatedAt.UTC().Format(time.RFC3339),
+			Published:  a.PostedAt.UTC().Format(time.RFC3339),
+			Link:       atomLink{Href: f.articleURL(a), Rel: "alternate"},
+			Author:     atomPerson{Name: authorName(a.Author), URI: a.Author.Website},
+			Categories: categories,
+			Content:    atomContent{Type: "html", Body: a.BodyHTML},
+		})
+	}
+
+	return doc
+}
+
+// authorName returns the name an author is shown with, its display name if any.
+func authorName(a repo.Author) string {
+	if a.DisplayName != "" {
+		return a.DisplayName
+	}
+	return a.Name
+}
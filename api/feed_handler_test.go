@@ -0,0 +1,217 @@
+package main
+
+import (
+	repo "blog/repo"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+// feedService returns a service listing the test article, published and last updated at the given time.
+func feedService(t *testing.T, updated time.Time) *MockService {
+	return &MockService{
+		ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+			require.Equal(t, q.Status, repo.StatusPublished)
+			a := article
+			a.Id, a.Slug, a.Body, a.BodyHTML = expectedArticleId, "test-title", "*body*", ""
+			a.Tags = []string{"go"}
+			a.PostedAt, a.UpdatedAt = updated.Add(-time.Hour), updated
+			return repo.ArticlePage{Articles: []repo.Article{a}, Total: 1}, nil
+		},
+		GetAuthorsByIdsFunc: func(ids []string) ([]repo.Author, error) {
+			return []repo.Author{author}, nil
+		},
+		GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+			return author, nil
+		},
+	}
+}
+
+func TestFeedRSS(t *testing.T) {
+
+	updated := time.Date(2022, 3, 1, 10, 30, 15, 500, time.UTC)
+
+	get := func(r *MockService, header http.Header) *httptest.ResponseRecorder {
+		h := BlogServer{Service: r, FeedTitle: "Test blog", FeedURL: "https://blog.example.com/", FeedSize: 10}
+		req := httptest.NewRequest(http.MethodGet, "/feed.rss", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		res := httptest.NewRecorder()
+		h.FeedRSS(res, req)
+		return res
+	}
+
+	t.Run("can get rss feed", func(t *testing.T) {
+		res := get(feedService(t, updated), nil)
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, res.Header().Get("Content-Type"), "application/rss+xml; charset=utf-8")
+		require.Empty(t, res.Header().Get("Last-Modified"))
+		require.NotEmpty(t, res.Header().Get("ETag"))
+
+		var doc struct {
+			Channel struct {
+				Title string `xml:"title"`
+				Items []struct {
+					Title       string `xml:"title"`
+					Link        string `xml:"link"`
+					GUID        string `xml:"guid"`
+					Creator     string `xml:"creator"`
+					Category    string `xml:"category"`
+					Description string `xml:"description"`
+				} `xml:"item"`
+			} `xml:"channel"`
+		}
+		require.NoError(t, xml.Unmarshal(res.Body.Bytes(), &doc))
+		require.Equal(t, doc.Channel.Title, "Test blog")
+		require.Len(t, doc.Channel.Items, 1)
+		item := doc.Channel.Items[0]
+		require.Equal(t, item.Title, article.Title)
+		require.Equal(t, item.Link, "https://blog.example.com/articles/by-slug/test-title")
+		require.Equal(t, item.GUID, "urn:uuid:"+expectedArticleId)
+		require.Equal(t, item.Creator, author.Name)
+		require.Equal(t, item.Category, "go")
+		require.Equal(t, item.Description, "<p><em>body</em></p>\n")
+		require.NotContains(t, res.Body.String(), author.Email)
+	})
+
+	t.Run("return 304 if feed not modified", func(t *testing.T) {
+		etag := get(feedService(t, updated), nil).Header().Get("ETag")
+
+		res := get(feedService(t, updated), http.Header{"If-None-Match": {etag}})
+		require.Equal(t, res.Code, http.StatusNotModified)
+		require.Empty(t, res.Body.Bytes())
+	})
+
+	t.Run("return feed if modified", func(t *testing.T) {
+		etag := get(feedService(t, updated), nil).Header().Get("ETag")
+
+		res := get(feedService(t, updated.Add(time.Minute)), http.Header{"If-None-Match": {etag}})
+		require.Equal(t, res.Code, http.StatusOK)
+		require.NotEqual(t, res.Header().Get("ETag"), etag)
+
+		// an article leaving the feed does not make the remaining ones newer
+		res = get(feedService(t, updated), http.Header{"If-Modified-Since": {"Tue, 01 Mar 2022 10:30:15 GMT"}})
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 503 if service fails", func(t *testing.T) {
+		r := &MockService{
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				return repo.ArticlePage{}, repo.Unavailable(errors.New("service fails"))
+			},
+		}
+
+		res := get(r, nil)
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+	})
+}
+
+func TestFeedAtom(t *testing.T) {
+
+	updated := time.Date(2022, 3, 1, 10, 30, 15, 0, time.UTC)
+
+	t.Run("can get atom feed", func(t *testing.T) {
+		h := BlogServer{Service: feedService(t, updated)}
+		req := httptest.NewRequest(http.MethodGet, "http://blog.example.com/feed.atom", nil)
+		res := httptest.NewRecorder()
+		h.FeedAtom(res, req)
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, res.Header().Get("Content-Type"), "application/atom+xml; charset=utf-8")
+
+		var doc atomFeed
+		require.NoError(t, xml.Unmarshal(res.Body.Bytes(), &doc))
+		require.Equal(t, doc.Id, "http://blog.example.com/feed.atom")
+		require.Equal(t, doc.Title, DefaultFeedTitle)
+		require.Equal(t, doc.Updated, "2022-03-01T10:30:15Z")
+		require.Len(t, doc.Entries, 1)
+		entry := doc.Entries[0]
+		require.Equal(t, entry.Id, "urn:uuid:"+expectedArticleId)
+		require.Equal(t, entry.Published, "2022-03-01T09:30:15Z")
+		require.Equal(t, entry.Link.Href, "http://blog.example.com/articles/by-slug/test-title")
+		require.Equal(t, entry.Author.Name, author.Name)
+		require.Equal(t, entry.Categories, []atomCategory{{Term: "go"}})
+		require.Equal(t, entry.Content, atomContent{Type: "html", Body: "<p><em>body</em></p>\n"})
+	})
+
+	t.Run("empty feed does not change", func(t *testing.T) {
+		r := &MockService{
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				return repo.ArticlePage{Articles: []repo.Article{}}, nil
+			},
+			GetAuthorsByIdsFunc: func(ids []string) ([]repo.Author, error) {
+				return []repo.Author{}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/feed.atom", nil)
+		res := httptest.NewRecorder()
+		h.FeedAtom(res, req)
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Empty(t, res.Header().Get("Last-Modified"))
+
+		req = httptest.NewRequest(http.MethodGet, "/feed.atom", nil)
+		req.Header.Set("If-None-Match", res.Header().Get("ETag"))
+		res = httptest.NewRecorder()
+		h.FeedAtom(res, req)
+		require.Equal(t, res.Code, http.StatusNotModified)
+	})
+}
+
+func TestAuthorFeedAtom(t *testing.T) {
+
+	get := func(r *MockService, id string) *httptest.ResponseRecorder {
+		h := BlogServer{Service: r, FeedURL: "https://blog.example.com"}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/authors/%s/feed.atom", id), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		res := httptest.NewRecorder()
+		h.AuthorFeedAtom(res, req)
+		return res
+	}
+
+	t.Run("can get author feed", func(t *testing.T) {
+		r := feedService(t, time.Now())
+		list := r.ListArticlesFunc
+		r.ListArticlesFunc = func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+			require.Equal(t, q.AuthorId, author.Id)
+			require.Equal(t, q.Limit, DefaultFeedSize)
+			return list(q)
+		}
+
+		res := get(r, author.Id)
+		require.Equal(t, res.Code, http.StatusOK)
+
+		var doc atomFeed
+		require.NoError(t, xml.Unmarshal(res.Body.Bytes(), &doc))
+		require.Equal(t, doc.Title, DefaultFeedTitle+" - "+author.Name)
+		require.Equal(t, doc.Author, &atomPerson{Name: author.Name})
+		require.Contains(t, doc.Links, atomLink{Href: "https://blog.example.com/authors/" + author.Id + "/page", Rel: "alternate"})
+		require.Len(t, doc.Entries, 1)
+	})
+
+	t.Run("return 400 if id is not valid", func(t *testing.T) {
+		res := get(&MockService{}, "invalid-uuid")
+		require.Equal(t, res.Code, http.StatusBadRequest)
+	})
+
+	t.Run("return 404 if author not found", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				return repo.Author{}, repo.ErrAuthorNotFound
+			},
+		}
+
+		res := get(r, author.Id)
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+}
@@ -2,188 +2,711 @@ package main
 
 import (
 	repo "blog/repo"
-	db "blog/repo/postgres"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
+// DefaultMaxCommentDepth is the maximum depth of comment replies when none is configured.
+const DefaultMaxCommentDepth = 5
+
+// articleBody is the body of the requests replacing an article, the other fields
+// of the article are set by the server.
+type articleBody struct {
+	Title string   `json:"title"`
+	Body  string   `json:"body"`
+	Tags  []string `json:"tags"`
+}
+
+// newArticleBody is the body of the requests adding an article, with its lifecycle and its author.
+type newArticleBody struct {
+	articleBody
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+	Author    authorBody `json:"author"`
+}
+
+// article returns the article of the body.
+func (b articleBody) article() repo.Article {
+	return repo.Article{Title: b.Title, Body: b.Body, Tags: b.Tags}
+}
+
+// article returns the article of the body, with its author.
+func (b newArticleBody) article() repo.Article {
+	a := b.articleBody.article()
+	a.Status, a.PublishAt = b.Status, b.PublishAt
+	a.Author = repo.Author{Name: b.Author.Name, Email: b.Author.Email}
+	return a
+}
+
 // BlogServer is responsible to answer to http request.
 type BlogServer struct {
 	Service repo.BlogService
+	// MaxCommentDepth is the maximum number of nested reply levels under a top-level comment.
+	MaxCommentDepth int
+	// ReadyTimeout is how long the readiness check waits for the database.
+	ReadyTimeout time.Duration
+	// FeedTitle, FeedURL and FeedSize are the title of the feeds, the public url of the blog
+	// they link to, by default the url of the request, and their number of articles.
+	FeedTitle string
+	FeedURL   string
+	FeedSize  int
 }
 
 func (h *BlogServer) ListArticles(w http.ResponseWriter, r *http.Request) {
 
-	// get articles
-	articles, err := h.Service.ListArticles()
+	q, err := parseArticleQuery(r)
 	if err != nil {
-		http.Error(w, "Service unavailable.", http.StatusServiceUnavailable)
+		writeError(w, r, err)
+		return
+	}
+
+	h.listArticles(w, r, q)
+}
+
+func (h *BlogServer) ListArticlesByTag(w http.ResponseWriter, r *http.Request) {
+
+	q, err := parseArticleQuery(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+	q.Tag = repo.NormalizeTag(vars["tag"])
+	if q.Tag == "" {
+		writeError(w, r, invalidParam("tag", "is empty"))
+		return
+	}
+
+	h.listArticles(w, r, q)
+}
+
+// listArticles writes the page of articles matching the query, with their authors.
+func (h *BlogServer) listArticles(w http.ResponseWriter, r *http.Request, q repo.ArticleQuery) {
+
+	ctx := r.Context()
+
+	// unpublished articles are only visible to authenticated authors, published ones by default
+	p, ok := PrincipalFrom(ctx)
+	if !ok || q.Status == "" {
+		q.Status = repo.StatusPublished
+	}
+
+	// the emails of the authors are private, only those who may see them can filter by them
+	if q.AuthorEmail != "" {
+		if !ok {
+			writeError(w, r, errUnauthenticated)
+			return
+		}
+		err := authorize(p, ActionViewEmails, Resource{})
+		if err != nil {
+			forbid(w, r, err)
+			return
+		}
+	}
+	if q.Status != repo.StatusPublished {
+		// authors list their own unpublished articles when no author is given
+		if p.Role == repo.RoleAuthor && q.AuthorId == "" && q.AuthorEmail == "" {
+			q.AuthorId = p.AuthorId
+		}
+		err := authorize(p, ActionViewUnpublished, Resource{OwnerId: q.AuthorId})
+		if err != nil {
+			forbid(w, r, err)
+			return
+		}
+	}
+
+	// get a page of articles
+	page, err := h.Service.ListArticles(ctx, q)
+	if err != nil {
+		if errors.Is(err, repo.ErrInvalidCursor) {
+			writeError(w, r, invalidParam("cursor", "is not valid"))
+			return
+		}
+		writeError(w, r, err)
 		return
 	}
 
 	// get author ids as a slice
 	ids := make([]string, 0)
-	for _, a := range articles {
+	for _, a := range page.Articles {
 		ids = append(ids, a.Author.Id)
 	}
 
-	// get authors by ids
-	authors, err := h.Service.GetAuthorsByIds(ids)
+	author_map, err := h.getAuthorMap(ctx, ids)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// for each article, fill in the author
+	for i := range page.Articles {
+		page.Articles[i].Author = author_map[page.Articles[i].Author.Id]
+		renderBody(&page.Articles[i])
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (h *BlogServer) SearchArticles(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	query := r.FormValue("q")
+	if query == "" {
+		writeError(w, r, invalidParam("q", "is required"))
+		return
+	}
+
+	var opts repo.SearchOptions
+	var err error
+
+	if v := r.FormValue("limit"); v != "" {
+		opts.Limit, err = strconv.Atoi(v)
+		if err != nil || opts.Limit <= 0 {
+			writeError(w, r, invalidParam("limit", "must be a positive integer"))
+			return
+		}
+	}
+	if v := r.FormValue("offset"); v != "" {
+		opts.Offset, err = strconv.Atoi(v)
+		if err != nil || opts.Offset < 0 {
+			writeError(w, r, invalidParam("offset", "must be a non-negative integer"))
+			return
+		}
+	}
+
+	// get the matching published articles, most relevant first
+	opts.Status = repo.StatusPublished
+	results, err := h.Service.SearchArticles(ctx, query, opts.WithDefaults())
 	if err != nil {
-		http.Error(w, "Service unavailable.", http.StatusServiceUnavailable)
+		writeError(w, r, err)
 		return
 	}
 
-	// define a map author_id: author
+	// get author ids as a slice
+	ids := make([]string, 0)
+	for _, res := range results {
+		ids = append(ids, res.Author.Id)
+	}
+
+	author_map, err := h.getAuthorMap(ctx, ids)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// for each result, fill in the author
+	for i := range results {
+		results[i].Author = author_map[results[i].Author.Id]
+		renderBody(&results[i].Article)
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// getAuthorMap returns the authors with the given ids as shown to the caller, keyed by id.
+func (h *BlogServer) getAuthorMap(ctx context.Context, ids []string) (map[string]repo.Author, error) {
+
+	authors, err := h.Service.GetAuthorsByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
 	author_map := make(map[string]repo.Author, len(authors))
 	for _, a := range authors {
-		author_map[a.Id] = a
+		author_map[a.Id] = authorView(ctx, a)
 	}
+	return author_map, nil
+}
 
-	// for each article, fill in the author
-	for i := range articles {
-		articles[i].Author = author_map[articles[i].Author.Id]
+// renderBody renders the html of the bodies stored before it was cached along with them.
+func renderBody(a *repo.Article) {
+	if a.BodyHTML == "" {
+		a.BodyHTML = repo.RenderMarkdown(a.Body)
+	}
+}
+
+// parseArticleQuery reads the paging, sorting and filtering options from the query string.
+func parseArticleQuery(r *http.Request) (repo.ArticleQuery, error) {
+
+	var q repo.ArticleQuery
+	var err error
+	values := r.URL.Query()
+
+	if v := values.Get("limit"); v != "" {
+		q.Limit, err = strconv.Atoi(v)
+		if err != nil || q.Limit <= 0 {
+			return q, invalidParam("limit", "must be a positive integer")
+		}
+	}
+
+	q.Cursor = values.Get("cursor")
+
+	switch q.SortBy = values.Get("sort"); q.SortBy {
+	case "", repo.SortByPostedAt, repo.SortByTitle:
+	default:
+		return q, invalidParam("sort", "must be posted_at or title")
 	}
 
-	data, err := json.Marshal(articles)
+	switch q.Order = values.Get("order"); q.Order {
+	case "", repo.OrderAsc, repo.OrderDesc:
+	default:
+		return q, invalidParam("order", "must be asc or desc")
+	}
+
+	if v := values.Get("author_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return q, invalidParam("author_id", "is not a valid uuid")
+		}
+		q.AuthorId = id.String()
+	}
+
+	q.AuthorEmail = values.Get("author_email")
+	q.Tag = repo.NormalizeTag(values.Get("tag"))
+
+	if q.Status = values.Get("status"); q.Status != "" && !repo.ValidStatus(q.Status) {
+		return q, invalidParam("status", "must be one of draft, scheduled, published or archived")
+	}
+
+	if v := values.Get("from"); v != "" {
+		q.From, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, invalidParam("from", "is not a valid RFC 3339 date")
+		}
+	}
+
+	if v := values.Get("to"); v != "" {
+		q.To, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, invalidParam("to", "is not a valid RFC 3339 date")
+		}
+	}
+
+	return q.WithDefaults(), nil
+}
+
+func (h *BlogServer) ListTags(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	tags, err := h.Service.ListTags(ctx)
 	if err != nil {
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(tags)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_, err = w.Write(data)
 	if err != nil {
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 }
 
 func (h *BlogServer) GetArticleById(w http.ResponseWriter, r *http.Request) {
 
+	ctx := r.Context()
+
 	vars := mux.Vars(r)
 	id, err := uuid.Parse(vars["id"])
 	if err != nil {
-		http.Error(w, "Bad request: id is not a valid uuid.", http.StatusBadRequest)
+		writeError(w, r, invalidId)
 		return
 	}
 
 	q := id.String()
-	article, err := h.Service.GetArticleById(q)
+	article, err := h.Service.GetArticleById(ctx, q)
 	if err != nil {
-		if errors.Is(err, db.ErrArticleNotFound) {
-			http.Error(w, "Article not found.", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Service unavailable.", http.StatusServiceUnavailable)
+		writeError(w, r, err)
+		return
+	}
+
+	if !canView(w, r, article) {
 		return
 	}
 
+	h.writeArticle(w, r, article)
+}
+
+// GetArticleBySlug returns the article with the given slug, the previous slugs of an article
+// are redirected to its current one.
+func (h *BlogServer) GetArticleBySlug(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+
+	article, err := h.Service.GetArticleBySlug(ctx, slug)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// the current slug of an unpublished article is not given away to those who cannot see it
+	if !canView(w, r, article) {
+		return
+	}
+
+	if article.Slug != slug {
+		http.Redirect(w, r, "/articles/by-slug/"+url.PathEscape(article.Slug), http.StatusMovedPermanently)
+		return
+	}
+
+	h.writeArticle(w, r, article)
+}
+
+// canView writes the error response and returns false if the caller cannot see the article:
+// unpublished articles are only visible to the authenticated authors allowed to see them.
+func canView(w http.ResponseWriter, r *http.Request, article repo.Article) bool {
+
+	if article.Status == repo.StatusPublished {
+		return true
+	}
+
+	p, ok := PrincipalFrom(r.Context())
+	if !ok {
+		writeError(w, r, repo.ErrArticleNotFound)
+		return false
+	}
+	err := authorize(p, ActionViewUnpublished, Resource{OwnerId: article.Author.Id})
+	if err != nil {
+		forbid(w, r, err)
+		return false
+	}
+
+	return true
+}
+
+// writeArticle writes the article with its author.
+func (h *BlogServer) writeArticle(w http.ResponseWriter, r *http.Request, article repo.Article) {
+
+	ctx := r.Context()
+
 	// get article's author
-	article.Author, err = h.Service.GetAuthorById(article.Author.Id)
+	author, err := h.Service.GetAuthorById(ctx, article.Author.Id)
 	if err != nil {
-		http.Error(w, "Service unavailable.", http.StatusServiceUnavailable)
+		writeError(w, r, err)
 		return
 	}
+	article.Author = authorView(ctx, author)
+	renderBody(&article)
 
 	data, err := json.Marshal(article)
 	if err != nil {
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_, err = w.Write(data)
 	if err != nil {
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 }
 
 func (h *BlogServer) AddArticle(w http.ResponseWriter, r *http.Request) {
 
-	var article repo.Article
+	ctx := r.Context()
 
-	// decode the request body into an Article
-	err := json.NewDecoder(r.Body).Decode(&article)
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	var body newArticleBody
+
+	// decode the request body, only the fields the client may write
+	err := decodeBody(w, r, &body)
 	if err != nil {
-		http.Error(w, "Bad request: body is not correct.", http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
+	article := body.article()
 
-	// add Author field in blog.authors table if not already exists
-	author, err := h.Service.GetAuthorByNameAndEmail(article.Author.Name, article.Author.Email)
+	var v validator
+	v.article(article)
+	v.author("author.", article.Author.Name, article.Author.Email)
+	v.check(checkLifecycle(&article.Status, &article.PublishAt, time.Now()))
+	err = v.err()
 	if err != nil {
-		if errors.Is(err, db.ErrAuthorNotFound) {
-			article.Author.Id, err = h.Service.AddAuthor(article.Author)
-			if err != nil {
-				http.Error(w, "Service unavailable.", http.StatusServiceUnavailable)
-				return
-			}
-		}
-		http.Error(w, "Service unavailable.", http.StatusServiceUnavailable)
+		writeError(w, r, err)
+		return
+	}
+
+	// authors are identified by their email
+	author, err := h.Service.GetAuthorByEmail(ctx, article.Author.Email)
+	if err != nil && !errors.Is(err, repo.ErrAuthorNotFound) {
+		writeError(w, r, err)
 		return
-	} else {
-		article.Author.Id = author.Id
 	}
 
-	// add Article in blog.articles table
-	a, err := h.Service.AddArticle(article)
+	// only editors can post on behalf of other authors, or of new ones
+	err = authorize(p, ActionCreateArticle, Resource{OwnerId: author.Id})
 	if err != nil {
-		http.Error(w, "Service unavailable.", http.StatusServiceUnavailable)
+		forbid(w, r, err)
+		return
+	}
+
+	// add Article in blog.articles table, with its Author if not already exists, in a single transaction
+	a, err := h.Service.CreateArticleWithAuthor(ctx, article)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	data, err := json.Marshal(a)
 	if err != nil {
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_, err = w.Write(data)
 	if err != nil {
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 }
 
-func (h *BlogServer) DeleteArticleById(w http.ResponseWriter, r *http.Request) {
+func (h *BlogServer) UpdateArticle(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
 
 	vars := mux.Vars(r)
 	id, err := uuid.Parse(vars["id"])
 	if err != nil {
-		http.Error(w, "Bad request: id is not a valid uuid.", http.StatusBadRequest)
+		writeError(w, r, invalidId)
 		return
 	}
 
-	err = h.Service.DeleteArticleById(id.String())
+	var body articleBody
+
+	// decode the request body, all its fields are replaced
+	err = decodeBody(w, r, &body)
 	if err != nil {
-		if errors.Is(err, db.ErrArticleNotFound) {
-			http.Error(w, "Article not found.", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Service unavailable.", http.StatusServiceUnavailable)
+		writeError(w, r, err)
+		return
+	}
+	article := body.article()
+
+	var v validator
+	v.article(article)
+	err = v.err()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, ok := h.authorizeArticle(w, r, p, id.String(), ActionEditArticle); !ok {
+		return
+	}
+
+	article.Id = id.String()
+	err = h.Service.UpdateArticle(ctx, article)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 }
 
-func (h *BlogServer) DeleteAuthorByNameAndEmail(w http.ResponseWriter, r *http.Request) {
+func (h *BlogServer) PatchArticle(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
 
-	name := r.FormValue("name")
-	email := r.FormValue("email")
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
 
-	err := h.Service.DeleteAuthorByNameAndEmail(name, email)
+	var patch repo.ArticlePatch
+
+	// decode the request body into an ArticlePatch, only the given fields are replaced
+	err = decodeBody(w, r, &patch)
 	if err != nil {
-		if errors.Is(err, db.ErrAuthorNotFound) {
-			http.Error(w, "Author not found.", http.StatusNotFound)
-			return
+		writeError(w, r, err)
+		return
+	}
+
+	var v validator
+	v.patch(&patch, time.Now())
+	err = v.err()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, ok := h.authorizeArticle(w, r, p, id.String(), ActionEditArticle); !ok {
+		return
+	}
+
+	err = h.Service.PatchArticle(ctx, id.String(), patch)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (h *BlogServer) PublishArticle(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	if _, ok := h.authorizeArticle(w, r, p, id.String(), ActionPublishArticle); !ok {
+		return
+	}
+
+	err = h.Service.PublishArticle(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (h *BlogServer) UnpublishArticle(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	if _, ok := h.authorizeArticle(w, r, p, id.String(), ActionPublishArticle); !ok {
+		return
+	}
+
+	err = h.Service.UnpublishArticle(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// checkLifecycle validates an article status and publication time, filling in the defaults:
+// an article without status is published, or scheduled if it has a publication time.
+// Publication times are stored in UTC.
+func checkLifecycle(status *string, publishAt **time.Time, now time.Time) error {
+
+	if *status == "" {
+		*status = repo.StatusPublished
+		if *publishAt != nil {
+			*status = repo.StatusScheduled
 		}
-		http.Error(w, "Service unavailable.", http.StatusServiceUnavailable)
+	}
+
+	if !repo.ValidStatus(*status) {
+		return repo.InvalidField("status", "must be one of draft, scheduled, published or archived")
+	}
+
+	if *status != repo.StatusScheduled {
+		if *publishAt != nil {
+			return repo.InvalidField("publish_at", "can only be set for a scheduled article")
+		}
+		return nil
+	}
+
+	if *publishAt == nil || !(*publishAt).After(now) {
+		return repo.InvalidField("publish_at", "must be in the future for a scheduled article")
+	}
+	t := (*publishAt).UTC()
+	*publishAt = &t
+
+	return nil
+}
+
+func (h *BlogServer) DeleteArticleById(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	if _, ok := h.authorizeArticle(w, r, p, id.String(), ActionDeleteArticle); !ok {
+		return
+	}
+
+	err = h.Service.DeleteArticleById(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 }
@@ -193,7 +716,7 @@ func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusMethodNotAllowed)
 	_, err := w.Write([]byte("Method not allowed."))
 	if err != nil {
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 }
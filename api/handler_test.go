@@ -2,8 +2,8 @@ package main
 
 import (
 	repo "blog/repo"
-	db "blog/repo/postgres"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +12,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
@@ -25,16 +26,73 @@ var author = repo.Author{
 var article = repo.Article{
 	Title:  "test",
 	Body:   "test",
+	Status: repo.StatusPublished,
 	Author: author,
 }
 var expectedArticleId = "b4a4de9e-2f52-4cf1-8907-3d828d403127"
 var expectedAuthorId = "b4a4de9e-2f52-4cf1-8907-3d828d403126"
+var otherAuthorId = "b4a4de9e-2f52-4cf1-8907-3d828d403125"
 
 func toJson(v interface{}) io.Reader {
 	json, _ := json.Marshal(v)
 	return bytes.NewReader(json)
 }
 
+// newArticleJson returns the body of a request adding the given article.
+func newArticleJson(a repo.Article) io.Reader {
+	return toJson(newArticleBody{
+		articleBody: articleBody{Title: a.Title, Body: a.Body, Tags: a.Tags},
+		Status:      a.Status,
+		PublishAt:   a.PublishAt,
+		Author:      authorBody{Name: a.Author.Name, Email: a.Author.Email},
+	})
+}
+
+// articleJson returns the body of a request replacing the given article.
+func articleJson(a repo.Article) io.Reader {
+	return toJson(articleBody{Title: a.Title, Body: a.Body, Tags: a.Tags})
+}
+
+// publicAuthor returns the test author as shown to anonymous callers.
+func publicAuthor() repo.Author {
+	a := author
+	a.Email = ""
+	return a
+}
+
+// asAuthor returns the request authenticated as the test author.
+func asAuthor(req *http.Request) *http.Request {
+	return asRole(req, author.Id, repo.RoleAuthor)
+}
+
+// contextService records the contexts of the calls getting articles and authors.
+type contextService struct {
+	*MockService
+	contexts []context.Context
+}
+
+func (s *contextService) GetArticleById(ctx context.Context, id string) (repo.Article, error) {
+	s.contexts = append(s.contexts, ctx)
+	return s.MockService.GetArticleById(ctx, id)
+}
+
+func (s *contextService) GetAuthorById(ctx context.Context, id string) (repo.Author, error) {
+	s.contexts = append(s.contexts, ctx)
+	return s.MockService.GetAuthorById(ctx, id)
+}
+
+// getOwnArticle returns the test article, written by the test author, under the given id.
+func getOwnArticle(id string) (repo.Article, error) {
+	a := article
+	a.Id = id
+	return a, nil
+}
+
+// asRole authenticates the request as the given author with the given role.
+func asRole(req *http.Request, authorId string, role string) *http.Request {
+	return req.WithContext(withPrincipal(req.Context(), Principal{AuthorId: authorId, Role: role}))
+}
+
 func TestListArticles(t *testing.T) {
 
 	t.Run("can get all articles", func(t *testing.T) {
@@ -42,33 +100,185 @@ func TestListArticles(t *testing.T) {
 			GetAuthorsByIdsFunc: func(ids []string) ([]repo.Author, error) {
 				return []repo.Author{author}, nil
 			},
-			ListArticlesFunc: func() ([]repo.Article, error) {
-				return []repo.Article{article}, nil
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				return repo.ArticlePage{Articles: []repo.Article{article}, Total: 1}, nil
 			},
 		}
 
-		h := BlogServer{r}
+		h := BlogServer{Service: r}
 		req := httptest.NewRequest(http.MethodGet, "/articles", nil)
 		res := httptest.NewRecorder()
 		h.ListArticles(res, req)
 
-		var a []repo.Article
-		json.Unmarshal(res.Body.Bytes(), &a) // nolint: errcheck
+		var p repo.ArticlePage
+		json.Unmarshal(res.Body.Bytes(), &p) // nolint: errcheck
 
 		require.Equal(t, res.Code, http.StatusOK)
-		require.Len(t, a, 1)
-		require.Equal(t, a[0].Title, article.Title)
-		require.Equal(t, a[0].Author.Name, article.Author.Name)
+		require.Len(t, p.Articles, 1)
+		require.Equal(t, p.Total, 1)
+		require.Empty(t, p.NextCursor)
+		require.Equal(t, p.Articles[0].Title, article.Title)
+		require.Equal(t, p.Articles[0].Author.Name, article.Author.Name)
+	})
+
+	t.Run("passes query options to the service", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorsByIdsFunc: func(ids []string) ([]repo.Author, error) {
+				return []repo.Author{}, nil
+			},
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				require.Equal(t, q.Limit, 5)
+				require.Equal(t, q.Cursor, "abc")
+				require.Equal(t, q.SortBy, repo.SortByTitle)
+				require.Equal(t, q.Order, repo.OrderAsc)
+				require.Equal(t, q.AuthorId, expectedAuthorId)
+				require.Equal(t, q.AuthorEmail, author.Email)
+				require.Equal(t, q.From, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+				require.True(t, q.To.IsZero())
+				return repo.ArticlePage{Articles: []repo.Article{}, NextCursor: "def"}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		url := fmt.Sprintf("/articles?limit=5&cursor=abc&sort=title&order=asc&author_id=%s&author_email=%s&from=2021-01-01T00:00:00Z",
+			expectedAuthorId, author.Email)
+		req := asRole(httptest.NewRequest(http.MethodGet, url, nil), author.Id, repo.RoleEditor)
+		res := httptest.NewRecorder()
+		h.ListArticles(res, req)
+
+		var p repo.ArticlePage
+		json.Unmarshal(res.Body.Bytes(), &p) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, p.NextCursor, "def")
+	})
+
+	t.Run("anonymous readers only list published articles", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorsByIdsFunc: func(ids []string) ([]repo.Author, error) {
+				return []repo.Author{}, nil
+			},
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				require.Equal(t, q.Status, repo.StatusPublished)
+				return repo.ArticlePage{Articles: []repo.Article{}}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/articles?status=draft", nil)
+		res := httptest.NewRecorder()
+		h.ListArticles(res, req)
+
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("authenticated authors can list their drafts", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorsByIdsFunc: func(ids []string) ([]repo.Author, error) {
+				return []repo.Author{}, nil
+			},
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				require.Equal(t, q.Status, repo.StatusDraft)
+				require.Equal(t, q.AuthorId, author.Id)
+				return repo.ArticlePage{Articles: []repo.Article{}}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/articles?status=draft", nil)
+		req = asAuthor(req)
+		res := httptest.NewRecorder()
+		h.ListArticles(res, req)
+
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("editors can list everyone's drafts", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorsByIdsFunc: func(ids []string) ([]repo.Author, error) {
+				return []repo.Author{}, nil
+			},
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				require.Equal(t, q.Status, repo.StatusDraft)
+				require.Empty(t, q.AuthorId)
+				return repo.ArticlePage{Articles: []repo.Article{}}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/articles?status=draft", nil)
+		req = asRole(req, author.Id, repo.RoleEditor)
+		res := httptest.NewRecorder()
+		h.ListArticles(res, req)
+
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 403 if not allowed to list the drafts", func(t *testing.T) {
+		for _, req := range []*http.Request{
+			asAuthor(httptest.NewRequest(http.MethodGet, "/articles?status=draft&author_id="+otherAuthorId, nil)),
+			asRole(httptest.NewRequest(http.MethodGet, "/articles?status=draft", nil), author.Id, repo.RoleReader),
+		} {
+			h := BlogServer{Service: &MockService{}}
+			res := httptest.NewRecorder()
+			h.ListArticles(res, req)
+
+			require.Equal(t, res.Code, http.StatusForbidden, req.URL.String())
+		}
+	})
+
+	t.Run("only those who may view emails can filter by them", func(t *testing.T) {
+		url := "/articles?author_email=" + author.Email
+		for i, c := range []struct {
+			req  *http.Request
+			code int
+		}{
+			{httptest.NewRequest(http.MethodGet, url, nil), http.StatusUnauthorized},
+			{asAuthor(httptest.NewRequest(http.MethodGet, url, nil)), http.StatusForbidden},
+			{asRole(httptest.NewRequest(http.MethodGet, url, nil), author.Id, repo.RoleReader), http.StatusForbidden},
+		} {
+			h := BlogServer{Service: &MockService{}}
+			res := httptest.NewRecorder()
+			h.ListArticles(res, c.req)
+
+			require.Equal(t, res.Code, c.code, i)
+		}
+	})
+
+	t.Run("return 400 if query options are not valid", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		for _, query := range []string{"limit=0", "limit=x", "sort=body", "status=pending", "order=up", "author_id=x", "from=yesterday", "to=2021-01-01"} {
+			req := httptest.NewRequest(http.MethodGet, "/articles?"+query, nil)
+			res := httptest.NewRecorder()
+			h.ListArticles(res, req)
+
+			require.Equal(t, res.Code, http.StatusBadRequest, query)
+		}
+	})
+
+	t.Run("return 400 if cursor is not valid", func(t *testing.T) {
+		r := &MockService{
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				return repo.ArticlePage{}, repo.ErrInvalidCursor
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/articles?cursor=abc", nil)
+		res := httptest.NewRecorder()
+		h.ListArticles(res, req)
+
+		require.Equal(t, res.Code, http.StatusBadRequest)
 	})
 
 	t.Run("return 503 if get articles fails", func(t *testing.T) {
 		r := &MockService{
-			ListArticlesFunc: func() ([]repo.Article, error) {
-				return []repo.Article{}, errors.New("couldn't fetch articles")
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				return repo.ArticlePage{}, repo.Unavailable(errors.New("couldn't fetch articles"))
 			},
 		}
 
-		h := BlogServer{r}
+		h := BlogServer{Service: r}
 		req := httptest.NewRequest(http.MethodGet, "/articles", nil)
 		res := httptest.NewRecorder()
 		h.ListArticles(res, req)
@@ -79,18 +289,173 @@ func TestListArticles(t *testing.T) {
 	t.Run("return 503 if get authors fails", func(t *testing.T) {
 		r := &MockService{
 			GetAuthorsByIdsFunc: func(ids []string) ([]repo.Author, error) {
-				return []repo.Author{}, errors.New("couldn't fetch authors")
+				return []repo.Author{}, repo.Unavailable(errors.New("couldn't fetch authors"))
+			},
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				return repo.ArticlePage{Articles: []repo.Article{article}}, nil
 			},
-			ListArticlesFunc: func() ([]repo.Article, error) {
-				return []repo.Article{article}, nil
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/articles", nil)
+		res := httptest.NewRecorder()
+		h.ListArticles(res, req)
+
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+	})
+
+	t.Run("return 500 if get articles fails unexpectedly", func(t *testing.T) {
+		r := &MockService{
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				return repo.ArticlePage{}, errors.New("cannot scan row")
 			},
 		}
 
-		h := BlogServer{r}
+		h := BlogServer{Service: r}
 		req := httptest.NewRequest(http.MethodGet, "/articles", nil)
 		res := httptest.NewRecorder()
 		h.ListArticles(res, req)
 
+		require.Equal(t, res.Code, http.StatusInternalServerError)
+		require.Equal(t, res.Header().Get("Content-Type"), "application/problem+json")
+		require.NotContains(t, res.Body.String(), "cannot scan row")
+	})
+}
+
+func TestListArticlesByTag(t *testing.T) {
+
+	t.Run("can get articles by tag", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorsByIdsFunc: func(ids []string) ([]repo.Author, error) {
+				return []repo.Author{author}, nil
+			},
+			ListArticlesFunc: func(q repo.ArticleQuery) (repo.ArticlePage, error) {
+				require.Equal(t, q.Tag, "golang")
+				return repo.ArticlePage{Articles: []repo.Article{article}, Total: 1}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/tags/GoLang/articles", nil)
+		req = mux.SetURLVars(req, map[string]string{"tag": "GoLang"})
+		res := httptest.NewRecorder()
+		h.ListArticlesByTag(res, req)
+
+		var p repo.ArticlePage
+		json.Unmarshal(res.Body.Bytes(), &p) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Len(t, p.Articles, 1)
+	})
+
+	t.Run("return 400 if tag is empty", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodGet, "/tags/%20/articles", nil)
+		req = mux.SetURLVars(req, map[string]string{"tag": " "})
+		res := httptest.NewRecorder()
+		h.ListArticlesByTag(res, req)
+
+		require.Equal(t, res.Code, http.StatusBadRequest)
+	})
+}
+
+func TestListTags(t *testing.T) {
+
+	t.Run("can get all tags", func(t *testing.T) {
+		r := &MockService{
+			ListTagsFunc: func() ([]repo.TagCount, error) {
+				return []repo.TagCount{{Name: "golang", Articles: 2}}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/tags", nil)
+		res := httptest.NewRecorder()
+		h.ListTags(res, req)
+
+		var tags []repo.TagCount
+		json.Unmarshal(res.Body.Bytes(), &tags) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, tags, []repo.TagCount{{Name: "golang", Articles: 2}})
+	})
+
+	t.Run("return 503 if service fails", func(t *testing.T) {
+		r := &MockService{
+			ListTagsFunc: func() ([]repo.TagCount, error) {
+				return nil, repo.Unavailable(errors.New("couldn't fetch tags"))
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/tags", nil)
+		res := httptest.NewRecorder()
+		h.ListTags(res, req)
+
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+	})
+}
+
+func TestSearchArticles(t *testing.T) {
+
+	t.Run("can search articles", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorsByIdsFunc: func(ids []string) ([]repo.Author, error) {
+				return []repo.Author{author}, nil
+			},
+			SearchArticlesFunc: func(query string, opts repo.SearchOptions) ([]repo.SearchResult, error) {
+				require.Equal(t, query, "test body")
+				require.Equal(t, opts.Limit, 5)
+				require.Equal(t, opts.Offset, 10)
+				return []repo.SearchResult{{Article: article, Rank: 0.5, Snippet: "<mark>test</mark>"}}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/articles/search?q=test+body&limit=5&offset=10", nil)
+		res := httptest.NewRecorder()
+		h.SearchArticles(res, req)
+
+		var results []repo.SearchResult
+		json.Unmarshal(res.Body.Bytes(), &results) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Len(t, results, 1)
+		require.Equal(t, results[0].Title, article.Title)
+		require.Equal(t, results[0].Snippet, "<mark>test</mark>")
+		require.Equal(t, results[0].Author.Name, article.Author.Name)
+	})
+
+	t.Run("return 400 if query is missing", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodGet, "/articles/search", nil)
+		res := httptest.NewRecorder()
+		h.SearchArticles(res, req)
+
+		require.Equal(t, res.Code, http.StatusBadRequest)
+	})
+
+	t.Run("return 400 if offset is not valid", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodGet, "/articles/search?q=test&offset=-1", nil)
+		res := httptest.NewRecorder()
+		h.SearchArticles(res, req)
+
+		require.Equal(t, res.Code, http.StatusBadRequest)
+	})
+
+	t.Run("return 503 if search fails", func(t *testing.T) {
+		r := &MockService{
+			SearchArticlesFunc: func(query string, opts repo.SearchOptions) ([]repo.SearchResult, error) {
+				return nil, repo.Unavailable(errors.New("couldn't search articles"))
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/articles/search?q=test", nil)
+		res := httptest.NewRecorder()
+		h.SearchArticles(res, req)
+
 		require.Equal(t, res.Code, http.StatusServiceUnavailable)
 	})
 }
@@ -109,7 +474,7 @@ func TestGetArticleById(t *testing.T) {
 			},
 		}
 
-		h := BlogServer{r}
+		h := BlogServer{Service: r}
 		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
 		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
 		res := httptest.NewRecorder()
@@ -123,9 +488,40 @@ func TestGetArticleById(t *testing.T) {
 		require.Equal(t, a.Author.Name, article.Author.Name)
 	})
 
+	t.Run("render body html if not cached", func(t *testing.T) {
+		for _, cached := range []string{"", "<p>cached</p>"} {
+			r := &MockService{
+				GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+					return author, nil
+				},
+				GetArticleByIdFunc: func(id string) (repo.Article, error) {
+					a := article
+					a.Body, a.BodyHTML = "*body*", cached
+					return a, nil
+				},
+			}
+
+			h := BlogServer{Service: r}
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+			req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+			res := httptest.NewRecorder()
+
+			h.GetArticleById(res, req)
+			var a repo.Article
+			json.Unmarshal(res.Body.Bytes(), &a) // nolint: errcheck
+
+			require.Equal(t, res.Code, http.StatusOK)
+			if cached == "" {
+				require.Equal(t, a.BodyHTML, "<p><em>body</em></p>\n")
+			} else {
+				require.Equal(t, a.BodyHTML, cached)
+			}
+		}
+	})
+
 	t.Run("return 400 when id is invalid uuid", func(t *testing.T) {
 		r := &MockService{}
-		h := BlogServer{r}
+		h := BlogServer{Service: r}
 
 		req := httptest.NewRequest(http.MethodGet, "/articles/id", nil)
 		req = mux.SetURLVars(req, map[string]string{"id": "id"})
@@ -139,11 +535,11 @@ func TestGetArticleById(t *testing.T) {
 		r := &MockService{
 			GetArticleByIdFunc: func(id string) (repo.Article, error) {
 				require.Equal(t, id, expectedArticleId)
-				return repo.Article{}, db.ErrArticleNotFound
+				return repo.Article{}, repo.ErrArticleNotFound
 			},
 		}
 
-		h := BlogServer{r}
+		h := BlogServer{Service: r}
 		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
 		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
 		res := httptest.NewRecorder()
@@ -152,15 +548,97 @@ func TestGetArticleById(t *testing.T) {
 		require.Equal(t, res.Code, http.StatusNotFound)
 	})
 
+	t.Run("return 404 when article is not published", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: func(id string) (repo.Article, error) {
+				draft := article
+				draft.Status = repo.StatusDraft
+				return draft, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.GetArticleById(res, req)
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+
+	t.Run("can get unpublished article when authenticated", func(t *testing.T) {
+		r := &MockService{
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				return author, nil
+			},
+			GetArticleByIdFunc: func(id string) (repo.Article, error) {
+				draft := article
+				draft.Status = repo.StatusDraft
+				return draft, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		req = asAuthor(req)
+		res := httptest.NewRecorder()
+
+		h.GetArticleById(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 403 when unpublished article is someone else's", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: func(id string) (repo.Article, error) {
+				draft := article
+				draft.Status = repo.StatusDraft
+				return draft, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		req = asRole(req, otherAuthorId, repo.RoleAuthor)
+		res := httptest.NewRecorder()
+
+		h.GetArticleById(res, req)
+		require.Equal(t, res.Code, http.StatusForbidden)
+	})
+
+	t.Run("passes the request context to the service", func(t *testing.T) {
+		r := &contextService{MockService: &MockService{
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				return author, nil
+			},
+			GetArticleByIdFunc: getOwnArticle,
+		}}
+
+		h := BlogServer{Service: r}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil).WithContext(ctx)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.GetArticleById(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Len(t, r.contexts, 2)
+		for _, c := range r.contexts {
+			require.Equal(t, c.Done(), ctx.Done())
+		}
+	})
+
 	t.Run("return 503 when get article fails", func(t *testing.T) {
 		r := &MockService{
 			GetArticleByIdFunc: func(id string) (repo.Article, error) {
 				require.Equal(t, id, expectedArticleId)
-				return repo.Article{}, errors.New("couldn't fetch article")
+				return repo.Article{}, repo.Unavailable(errors.New("couldn't fetch article"))
 			},
 		}
 
-		h := BlogServer{r}
+		h := BlogServer{Service: r}
 		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
 		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
 		res := httptest.NewRecorder()
@@ -177,11 +655,11 @@ func TestGetArticleById(t *testing.T) {
 			},
 			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
 				require.Equal(t, id, expectedAuthorId)
-				return repo.Author{}, errors.New("couldn't fetch author")
+				return repo.Author{}, repo.Unavailable(errors.New("couldn't fetch author"))
 			},
 		}
 
-		h := BlogServer{r}
+		h := BlogServer{Service: r}
 		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
 		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
 		res := httptest.NewRecorder()
@@ -191,24 +669,94 @@ func TestGetArticleById(t *testing.T) {
 	})
 }
 
+func TestGetArticleBySlug(t *testing.T) {
+
+	get := func(r *MockService, slug string, as ...func(*http.Request) *http.Request) *httptest.ResponseRecorder {
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/articles/by-slug/"+slug, nil)
+		for _, f := range as {
+			req = f(req)
+		}
+		req = mux.SetURLVars(req, map[string]string{"slug": slug})
+		res := httptest.NewRecorder()
+		h.GetArticleBySlug(res, req)
+		return res
+	}
+
+	service := func(status string) *MockService {
+		return &MockService{
+			GetArticleBySlugFunc: func(slug string) (repo.Article, error) {
+				if slug != "new-title" && slug != "old-title" {
+					return repo.Article{}, repo.ErrArticleNotFound
+				}
+				a := article
+				a.Id, a.Slug, a.Status = expectedArticleId, "new-title", status
+				return a, nil
+			},
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				return author, nil
+			},
+		}
+	}
+
+	t.Run("can get article by slug", func(t *testing.T) {
+		res := get(service(repo.StatusPublished), "new-title")
+		var a repo.Article
+		json.Unmarshal(res.Body.Bytes(), &a) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, a.Id, expectedArticleId)
+		require.Equal(t, a.Slug, "new-title")
+	})
+
+	t.Run("redirect old slug to current slug", func(t *testing.T) {
+		res := get(service(repo.StatusPublished), "old-title")
+		require.Equal(t, res.Code, http.StatusMovedPermanently)
+		require.Equal(t, res.Header().Get("Location"), "/articles/by-slug/new-title")
+	})
+
+	t.Run("redirect old slug of unpublished article only to those who can see it", func(t *testing.T) {
+		res := get(service(repo.StatusDraft), "old-title")
+		require.Equal(t, res.Code, http.StatusNotFound)
+		require.Empty(t, res.Header().Get("Location"))
+
+		res = get(service(repo.StatusDraft), "old-title", asAuthor)
+		require.Equal(t, res.Code, http.StatusMovedPermanently)
+	})
+
+	t.Run("return 404 when slug not found", func(t *testing.T) {
+		res := get(service(repo.StatusPublished), "missing")
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+
+	t.Run("return 503 when service fails", func(t *testing.T) {
+		r := &MockService{
+			GetArticleBySlugFunc: func(slug string) (repo.Article, error) {
+				return repo.Article{}, repo.Unavailable(errors.New("service fails"))
+			},
+		}
+
+		res := get(r, "new-title")
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+	})
+}
+
 func TestAddArticle(t *testing.T) {
 
 	t.Run("can add valid article", func(t *testing.T) {
 
 		r := &MockService{
-			AddAuthorFunc: func(a repo.Author) (string, error) {
-				return author.Id, nil
-			},
-			AddArticleFunc: func(a repo.Article) (string, error) {
+			CreateArticleWithAuthorFunc: func(a repo.Article) (string, error) {
 				return expectedArticleId, nil
 			},
-			GetAuthorByNameAndEmailFunc: func(name, email string) (repo.Author, error) {
+			GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
 				return author, nil
 			},
 		}
 
-		h := BlogServer{r}
-		req := httptest.NewRequest(http.MethodPost, "/articles", toJson(article))
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, "/articles", newArticleJson(article))
+		req = asAuthor(req)
 		res := httptest.NewRecorder()
 
 		h.AddArticle(res, req)
@@ -218,13 +766,91 @@ func TestAddArticle(t *testing.T) {
 		require.Equal(t, res.Code, http.StatusOK)
 		require.Equal(t, id, expectedArticleId)
 		require.Len(t, r.Articles, 1)
-		require.Equal(t, r.Articles[0], article)
+		expected := article
+		expected.Author = repo.Author{Name: author.Name, Email: author.Email}
+		require.Equal(t, r.Articles[0], expected)
+	})
+
+	t.Run("returns 422 if body has fields set by the server", func(t *testing.T) {
+
+		for _, body := range []string{
+			`{"id": "` + expectedArticleId + `", "title": "test", "body": "test"}`,
+			`{"title": "test", "body": "test", "body_html": "<script></script>"}`,
+			`{"title": "test", "body": "test", "author": {"id": "` + otherAuthorId + `", "name": "test", "email": "test@email.com"}}`,
+		} {
+			h := BlogServer{Service: &MockService{}}
+			req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader(body))
+			req = asAuthor(req)
+			res := httptest.NewRecorder()
+
+			h.AddArticle(res, req)
+			require.Equal(t, res.Code, http.StatusUnprocessableEntity, body)
+		}
+	})
+
+	t.Run("can add scheduled article", func(t *testing.T) {
+
+		r := &MockService{
+			CreateArticleWithAuthorFunc: func(a repo.Article) (string, error) {
+				return expectedArticleId, nil
+			},
+			GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
+				return author, nil
+			},
+		}
+
+		publishAt := time.Now().Add(time.Hour)
+		scheduled := article
+		scheduled.Status = ""
+		scheduled.PublishAt = &publishAt
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, "/articles", newArticleJson(scheduled))
+		req = asAuthor(req)
+		res := httptest.NewRecorder()
+
+		h.AddArticle(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Len(t, r.Articles, 1)
+		require.Equal(t, r.Articles[0].Status, repo.StatusScheduled)
+		require.True(t, r.Articles[0].PublishAt.Equal(publishAt))
+	})
+
+	t.Run("returns 422 if lifecycle is not valid", func(t *testing.T) {
+
+		past := time.Now().Add(-time.Hour)
+		for _, a := range []repo.Article{
+			{Title: "test", Body: "test", Status: "pending"},
+			{Title: "test", Body: "test", Status: repo.StatusScheduled},
+			{Title: "test", Body: "test", Status: repo.StatusScheduled, PublishAt: &past},
+			{Title: "test", Body: "test", Status: repo.StatusDraft, PublishAt: &past},
+		} {
+			h := BlogServer{Service: &MockService{}}
+			req := httptest.NewRequest(http.MethodPost, "/articles", newArticleJson(a))
+			req = asAuthor(req)
+			res := httptest.NewRecorder()
+
+			h.AddArticle(res, req)
+			require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+		}
+	})
+
+	t.Run("returns 401 if not authenticated", func(t *testing.T) {
+
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodPost, "/articles", newArticleJson(article))
+		res := httptest.NewRecorder()
+
+		h.AddArticle(res, req)
+		require.Equal(t, res.Code, http.StatusUnauthorized)
+		require.NotEmpty(t, res.Header().Get("WWW-Authenticate"))
 	})
 
 	t.Run("returns 400 if body is not valid article JSON", func(t *testing.T) {
 
-		h := BlogServer{nil}
+		h := BlogServer{Service: nil}
 		req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader("invalid json"))
+		req = asAuthor(req)
 		res := httptest.NewRecorder()
 
 		h.AddArticle(res, req)
@@ -234,177 +860,459 @@ func TestAddArticle(t *testing.T) {
 	t.Run("returns 503 if add article fails", func(t *testing.T) {
 
 		r := &MockService{
-			AddAuthorFunc: func(a repo.Author) (string, error) {
-				return author.Id, nil
-			},
-			AddArticleFunc: func(a repo.Article) (string, error) {
-				return "", errors.New("couldn't add new article")
+			CreateArticleWithAuthorFunc: func(a repo.Article) (string, error) {
+				return "", repo.Unavailable(errors.New("couldn't add new article"))
 			},
-			GetAuthorByNameAndEmailFunc: func(name, email string) (repo.Author, error) {
+			GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
 				return author, nil
 			},
 		}
 
-		h := BlogServer{r}
-		req := httptest.NewRequest(http.MethodPost, "/articles", toJson(article))
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, "/articles", newArticleJson(article))
+		req = asAuthor(req)
 		res := httptest.NewRecorder()
 
 		h.AddArticle(res, req)
 		require.Equal(t, res.Code, http.StatusServiceUnavailable)
 	})
 
-	t.Run("returns 503 if get author by name and email fails", func(t *testing.T) {
+	t.Run("returns 503 if get author by email fails", func(t *testing.T) {
 
 		r := &MockService{
-			GetAuthorByNameAndEmailFunc: func(name, email string) (repo.Author, error) {
-				return repo.Author{}, errors.New("couldn't get author by name and email")
+			GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
+				return repo.Author{}, repo.Unavailable(errors.New("couldn't get author by email"))
 			},
 		}
 
-		h := BlogServer{r}
-		req := httptest.NewRequest(http.MethodPost, "/articles", toJson(article))
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, "/articles", newArticleJson(article))
+		req = asAuthor(req)
 		res := httptest.NewRecorder()
 
 		h.AddArticle(res, req)
 		require.Equal(t, res.Code, http.StatusServiceUnavailable)
 	})
 
-	t.Run("returns 503 if add author fails", func(t *testing.T) {
+	t.Run("editors can add article for a new author", func(t *testing.T) {
 
 		r := &MockService{
-			AddAuthorFunc: func(a repo.Author) (string, error) {
-				return "", errors.New("couldn't add new author")
+			CreateArticleWithAuthorFunc: func(a repo.Article) (string, error) {
+				return expectedArticleId, nil
 			},
-			GetAuthorByNameAndEmailFunc: func(name, email string) (repo.Author, error) {
-				return repo.Author{}, db.ErrAuthorNotFound
+			GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
+				return repo.Author{}, repo.ErrAuthorNotFound
 			},
 		}
 
-		h := BlogServer{r}
-		req := httptest.NewRequest(http.MethodPost, "/articles", toJson(article))
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, "/articles", newArticleJson(article))
+		req = asRole(req, otherAuthorId, repo.RoleEditor)
 		res := httptest.NewRecorder()
 
 		h.AddArticle(res, req)
-		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Len(t, r.Articles, 1)
+		require.Equal(t, r.Articles[0].Author.Email, author.Email)
+	})
+
+	t.Run("returns 403 if not allowed to post as the author", func(t *testing.T) {
+
+		for _, role := range []string{repo.RoleAuthor, repo.RoleReader} {
+			r := &MockService{
+				GetAuthorByEmailFunc: func(email string) (repo.Author, error) {
+					return author, nil
+				},
+			}
+
+			h := BlogServer{Service: r}
+			req := httptest.NewRequest(http.MethodPost, "/articles", newArticleJson(article))
+			req = asRole(req, otherAuthorId, role)
+			res := httptest.NewRecorder()
+
+			h.AddArticle(res, req)
+			require.Equal(t, res.Code, http.StatusForbidden, role)
+			require.Empty(t, r.Articles)
+		}
 	})
 }
 
-func TestDeleteArticleById(t *testing.T) {
+func TestUpdateArticle(t *testing.T) {
 
-	t.Run("return 400 when id is invalid uuid", func(t *testing.T) {
-		r := &MockService{}
-		h := BlogServer{r}
+	t.Run("can update article", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			UpdateArticleFunc: func(a repo.Article) error {
+				require.Equal(t, a.Id, expectedArticleId)
+				require.Equal(t, a.Title, article.Title)
+				require.Equal(t, a.Body, article.Body)
+				return nil
+			},
+		}
 
-		req := httptest.NewRequest(http.MethodDelete, "/articles/id", nil)
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/articles/%s", expectedArticleId), articleJson(article))
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.UpdateArticle(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("editors can update anyone's article", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			UpdateArticleFunc: func(a repo.Article) error {
+				return nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/articles/%s", expectedArticleId), articleJson(article))
+		req = asRole(req, otherAuthorId, repo.RoleEditor)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.UpdateArticle(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 403 if article is someone else's", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/articles/%s", expectedArticleId), articleJson(article))
+		req = asRole(req, otherAuthorId, repo.RoleAuthor)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.UpdateArticle(res, req)
+		require.Equal(t, res.Code, http.StatusForbidden)
+		require.Contains(t, res.Body.String(), "authors can only edit their own articles")
+	})
+
+	t.Run("return 400 when id is invalid uuid", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodPut, "/articles/id", articleJson(article))
+		req = asAuthor(req)
 		req = mux.SetURLVars(req, map[string]string{"id": "id"})
 		res := httptest.NewRecorder()
 
-		h.DeleteArticleById(res, req)
+		h.UpdateArticle(res, req)
 		require.Equal(t, res.Code, http.StatusBadRequest)
 	})
 
-	t.Run("can delete article by id", func(t *testing.T) {
+	t.Run("return 422 when title is missing", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/articles/%s", expectedArticleId), articleJson(repo.Article{Body: "test"}))
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.UpdateArticle(res, req)
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("return 404 if article not found", func(t *testing.T) {
 		r := &MockService{
-			DeleteArticleByIdFunc: func(id string) error {
+			GetArticleByIdFunc: getOwnArticle,
+			UpdateArticleFunc: func(a repo.Article) error {
+				return repo.ErrArticleNotFound
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/articles/%s", expectedArticleId), articleJson(article))
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.UpdateArticle(res, req)
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+
+	t.Run("return 503 if service fails", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			UpdateArticleFunc: func(a repo.Article) error {
+				return repo.Unavailable(errors.New("service fails"))
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/articles/%s", expectedArticleId), articleJson(article))
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.UpdateArticle(res, req)
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+	})
+}
+
+func TestPatchArticle(t *testing.T) {
+
+	t.Run("can patch article title", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			PatchArticleFunc: func(id string, p repo.ArticlePatch) error {
 				require.Equal(t, id, expectedArticleId)
+				require.Equal(t, *p.Title, "new title")
+				require.Nil(t, p.Body)
 				return nil
 			},
 		}
 
-		h := BlogServer{r}
-		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/articles/%s", expectedArticleId), strings.NewReader(`{"title": "new title"}`))
+		req = asAuthor(req)
 		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
 		res := httptest.NewRecorder()
 
-		h.DeleteArticleById(res, req)
+		h.PatchArticle(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 422 when body is empty string", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/articles/%s", expectedArticleId), strings.NewReader(`{"body": ""}`))
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.PatchArticle(res, req)
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("can archive article", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			PatchArticleFunc: func(id string, p repo.ArticlePatch) error {
+				require.Equal(t, *p.Status, repo.StatusArchived)
+				return nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/articles/%s", expectedArticleId), strings.NewReader(`{"status": "archived"}`))
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.PatchArticle(res, req)
 		require.Equal(t, res.Code, http.StatusOK)
 	})
 
+	t.Run("return 422 when publishing through patch", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		for _, body := range []string{`{"status": "published"}`, `{"publish_at": "2100-01-01T00:00:00Z"}`} {
+			req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/articles/%s", expectedArticleId), strings.NewReader(body))
+			req = asAuthor(req)
+			req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+			res := httptest.NewRecorder()
+
+			h.PatchArticle(res, req)
+			require.Equal(t, res.Code, http.StatusUnprocessableEntity, body)
+		}
+	})
+
 	t.Run("return 404 if article not found", func(t *testing.T) {
 		r := &MockService{
-			DeleteArticleByIdFunc: func(id string) error {
+			GetArticleByIdFunc: getOwnArticle,
+			PatchArticleFunc: func(id string, p repo.ArticlePatch) error {
+				return repo.ErrArticleNotFound
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/articles/%s", expectedArticleId), strings.NewReader(`{"title": "new title"}`))
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.PatchArticle(res, req)
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+}
+
+func TestPublishArticle(t *testing.T) {
+
+	t.Run("can publish article", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			PublishArticleFunc: func(id string) error {
 				require.Equal(t, id, expectedArticleId)
-				return db.ErrArticleNotFound
+				return nil
 			},
 		}
 
-		h := BlogServer{r}
-		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/articles/%s/publish", expectedArticleId), nil)
+		req = asAuthor(req)
 		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
 		res := httptest.NewRecorder()
 
-		h.DeleteArticleById(res, req)
+		h.PublishArticle(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 404 if article not found", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			PublishArticleFunc: func(id string) error {
+				return repo.ErrArticleNotFound
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/articles/%s/publish", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.PublishArticle(res, req)
 		require.Equal(t, res.Code, http.StatusNotFound)
 	})
+}
 
-	t.Run("return 503 if service fails", func(t *testing.T) {
+func TestUnpublishArticle(t *testing.T) {
+
+	t.Run("can unpublish article", func(t *testing.T) {
 		r := &MockService{
-			DeleteArticleByIdFunc: func(id string) error {
+			GetArticleByIdFunc: getOwnArticle,
+			UnpublishArticleFunc: func(id string) error {
 				require.Equal(t, id, expectedArticleId)
-				return errors.New("service fails")
+				return nil
 			},
 		}
 
-		h := BlogServer{r}
-		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/articles/%s/unpublish", expectedArticleId), nil)
+		req = asAuthor(req)
 		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
 		res := httptest.NewRecorder()
 
-		h.DeleteArticleById(res, req)
+		h.UnpublishArticle(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 503 if service fails", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			UnpublishArticleFunc: func(id string) error {
+				return repo.Unavailable(errors.New("service fails"))
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/articles/%s/unpublish", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.UnpublishArticle(res, req)
 		require.Equal(t, res.Code, http.StatusServiceUnavailable)
 	})
 }
 
-func TestDeleteAuthorByNameAndEmail(t *testing.T) {
+func TestDeleteArticleById(t *testing.T) {
+
+	t.Run("return 401 if not authenticated", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.DeleteArticleById(res, req)
+		require.Equal(t, res.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("return 400 when id is invalid uuid", func(t *testing.T) {
+		r := &MockService{}
+		h := BlogServer{Service: r}
+
+		req := httptest.NewRequest(http.MethodDelete, "/articles/id", nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": "id"})
+		res := httptest.NewRecorder()
+
+		h.DeleteArticleById(res, req)
+		require.Equal(t, res.Code, http.StatusBadRequest)
+	})
+
+	t.Run("return 403 if article is someone else's", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+		}
+		h := BlogServer{Service: r}
 
-	t.Run("can delete article by name and email", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+		req = asRole(req, otherAuthorId, repo.RoleAuthor)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+
+		h.DeleteArticleById(res, req)
+		require.Equal(t, res.Code, http.StatusForbidden)
+	})
+
+	t.Run("can delete article by id", func(t *testing.T) {
 		r := &MockService{
-			DeleteAuthorByNameAndEmailFunc: func(name string, email string) error {
-				require.Equal(t, name, author.Name)
-				require.Equal(t, email, author.Email)
+			GetArticleByIdFunc: getOwnArticle,
+			DeleteArticleByIdFunc: func(id string) error {
+				require.Equal(t, id, expectedArticleId)
 				return nil
 			},
 		}
 
-		h := BlogServer{r}
-		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles?name=%s&email=%s", author.Name, author.Email), nil)
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
 		res := httptest.NewRecorder()
 
-		h.DeleteAuthorByNameAndEmail(res, req)
+		h.DeleteArticleById(res, req)
 		require.Equal(t, res.Code, http.StatusOK)
 	})
 
 	t.Run("return 404 if article not found", func(t *testing.T) {
 		r := &MockService{
-			DeleteAuthorByNameAndEmailFunc: func(name string, email string) error {
-				require.Equal(t, name, author.Name)
-				require.Equal(t, email, author.Email)
-				return db.ErrAuthorNotFound
+			GetArticleByIdFunc: getOwnArticle,
+			DeleteArticleByIdFunc: func(id string) error {
+				require.Equal(t, id, expectedArticleId)
+				return repo.ErrArticleNotFound
 			},
 		}
 
-		h := BlogServer{r}
-		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles?name=%s&email=%s", author.Name, author.Email), nil)
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
 		res := httptest.NewRecorder()
 
-		h.DeleteAuthorByNameAndEmail(res, req)
+		h.DeleteArticleById(res, req)
 		require.Equal(t, res.Code, http.StatusNotFound)
 	})
 
 	t.Run("return 503 if service fails", func(t *testing.T) {
 		r := &MockService{
-			DeleteAuthorByNameAndEmailFunc: func(name string, email string) error {
-				require.Equal(t, name, author.Name)
-				require.Equal(t, email, author.Email)
-				return errors.New("service fails")
+			GetArticleByIdFunc: getOwnArticle,
+			DeleteArticleByIdFunc: func(id string) error {
+				require.Equal(t, id, expectedArticleId)
+				return repo.Unavailable(errors.New("service fails"))
 			},
 		}
 
-		h := BlogServer{r}
-		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles?name=%s&email=%s", author.Name, author.Email), nil)
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
 		res := httptest.NewRecorder()
 
-		h.DeleteAuthorByNameAndEmail(res, req)
+		h.DeleteArticleById(res, req)
 		require.Equal(t, res.Code, http.StatusServiceUnavailable)
 	})
 }
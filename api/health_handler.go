@@ -0,0 +1,44 @@
+package main
+
+import (
+	repo "blog/repo"
+	"context"
+	"net/http"
+	"time"
+)
+
+// DefaultReadyTimeout is how long the readiness check waits for the database when no timeout is configured.
+const DefaultReadyTimeout = 2 * time.Second
+
+// Healthz answers 200 as long as the process serves requests.
+func (h *BlogServer) Healthz(w http.ResponseWriter, r *http.Request) {
+	_, err := w.Write([]byte("OK"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// Readyz answers 200 if the database can be reached within the ready timeout, 503 otherwise.
+// The failures are logged by the repository, like the errors of the other calls.
+func (h *BlogServer) Readyz(w http.ResponseWriter, r *http.Request) {
+
+	timeout := h.ReadyTimeout
+	if timeout <= 0 {
+		timeout = DefaultReadyTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	err := h.Service.Ping(ctx)
+	if err != nil {
+		writeError(w, r, repo.Unavailable(err))
+		return
+	}
+
+	_, err = w.Write([]byte("OK"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"blog/logging"
+	repo "blog/repo"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthz(t *testing.T) {
+
+	t.Run("returns 200 without checking the database", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		res := httptest.NewRecorder()
+
+		h.Healthz(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+}
+
+func TestReadyz(t *testing.T) {
+
+	t.Run("returns 200 if the database is reachable", func(t *testing.T) {
+		r := &MockService{
+			PingFunc: func(ctx context.Context) error {
+				deadline, ok := ctx.Deadline()
+				require.True(t, ok)
+				require.WithinDuration(t, deadline, time.Now().Add(time.Second), 100*time.Millisecond)
+				return nil
+			},
+		}
+
+		h := BlogServer{Service: r, ReadyTimeout: time.Second}
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		res := httptest.NewRecorder()
+
+		h.Readyz(res, req)
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("returns 503 if the database is not reachable", func(t *testing.T) {
+		r := &MockService{
+			PingFunc: func(ctx context.Context) error {
+				return errors.New("connection refused")
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		res := httptest.NewRecorder()
+
+		h.Readyz(res, req)
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+	})
+
+	t.Run("logs the failure once", func(t *testing.T) {
+		r := &MockService{
+			PingFunc: func(ctx context.Context) error {
+				return errors.New("connection refused")
+			},
+		}
+
+		var out bytes.Buffer
+		h := BlogServer{Service: repo.Annotated(r)}
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		req = req.WithContext(logging.NewContext(req.Context(), logging.New(&out)))
+		res := httptest.NewRecorder()
+
+		h.Readyz(res, req)
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+
+		entries := logEntries(t, &out)
+		require.Len(t, entries, 1)
+		require.Equal(t, entries[0]["msg"], "request failed")
+		require.Equal(t, entries[0]["operation"], "Ping")
+	})
+}
@@ -1,54 +1,208 @@
 package main
 
 import (
+	"blog/config"
+	"blog/logging"
+	"blog/migrations"
+	repo "blog/repo"
+	"blog/repo/memory"
 	"blog/repo/postgres"
+	"blog/repo/sqlite"
+	"context"
+	"crypto/rand"
 	"database/sql"
-	"fmt"
-	"log"
+	"errors"
+	"flag"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
 )
 
-const (
-	host     = "34.72.188.178"
-	port     = 5432
-	user     = "bemihai"
-	password = "bemihai"
-	dbname   = "blog"
-	schema   = "blog"
-)
-
 func main() {
 
-	// define handler for http requests with postgres repository
-	database := psqlConnect(host, port, user, password, dbname, schema)
-	defer database.Close()
+	// log as JSON lines, the requests with their id
+	logger := logging.New(os.Stderr)
+
+	// manage the database schema instead of serving with "migrate up|down|status|redo"
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(os.Args[0], os.Args[2:], logger)
+		if err != nil && !errors.Is(err, flag.ErrHelp) {
+			logger.Error("cannot migrate the database", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// load the configuration from the config file, the environment and the command line
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.Getenv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		logger.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// define handler for http requests with the configured repository, naming the operation of its unexpected errors
+	service, closeStore := openStore(cfg, logger)
 
 	handler := BlogServer{
-		Service: &postgres.PSQLRepository{DB: database},
+		Service:         repo.Annotated(service),
+		MaxCommentDepth: cfg.Comments.MaxDepth,
+		ReadyTimeout:    cfg.Server.ReadyTimeout,
+		FeedTitle:       cfg.Feed.Title,
+		FeedURL:         cfg.Feed.URL,
+		FeedSize:        cfg.Feed.Size,
+	}
+
+	// authenticate requests with the JWTs issued on login or with API tokens
+	auth := &Authenticator{
+		Service:    handler.Service,
+		Secret:     authSecret(cfg.Auth.Secret, logger),
+		TokenTTL:   cfg.Auth.TokenTTL,
+		AdminEmail: cfg.Auth.AdminEmail,
+	}
+
+	// make the configured author admin, the other roles are granted by the admins
+	err = grantAdmin(context.Background(), handler.Service, cfg.Auth.AdminEmail, logger)
+	if err != nil {
+		logger.Error("cannot grant the admin role", "error", err)
+		os.Exit(1)
 	}
 
 	// define the associations between endpoints and handlers
 	router := mux.NewRouter()
 
+	// tell the access log the route template of the requests
+	router.Use(RecordRoute)
+
+	// define handler for GET on "/healthz" endpoint, the process is alive
+	router.Handle("/healthz", http.HandlerFunc(handler.Healthz)).Methods(http.MethodGet)
+
+	// define handler for GET on "/readyz" endpoint, the database is reachable
+	router.Handle("/readyz", http.HandlerFunc(handler.Readyz)).Methods(http.MethodGet)
+
+	// define handler for POST on "/auth/register" endpoint
+	router.Handle("/auth/register", http.HandlerFunc(auth.Register)).Methods(http.MethodPost)
+
+	// define handler for POST on "/auth/login" endpoint
+	router.Handle("/auth/login", http.HandlerFunc(auth.Login)).Methods(http.MethodPost)
+
+	// define handler for POST on "/auth/password" endpoint, setting a password with a reset token
+	router.Handle("/auth/password", http.HandlerFunc(auth.ResetPassword)).Methods(http.MethodPost)
+
+	// define handler for GET on "/auth/tokens" endpoint
+	router.Handle("/auth/tokens", http.HandlerFunc(auth.ListTokens)).Methods(http.MethodGet)
+
+	// define handler for POST on "/auth/tokens" endpoint
+	router.Handle("/auth/tokens", http.HandlerFunc(auth.CreateToken)).Methods(http.MethodPost)
+
+	// define handler for DELETE on "/auth/tokens/id" endpoint
+	router.Handle("/auth/tokens/{id}", http.HandlerFunc(auth.RevokeToken)).Methods(http.MethodDelete)
+
 	// define handler for GET on "/articles" endpoint
 	router.Handle("/articles", http.HandlerFunc(handler.ListArticles)).Methods(http.MethodGet)
 
+	// define handler for GET on "/articles/search" endpoint, before "/articles/id" so that it is not taken for an id
+	router.Handle("/articles/search", http.HandlerFunc(handler.SearchArticles)).Methods(http.MethodGet)
+
+	// define handler for GET on "/articles/by-slug/slug" endpoint, the previous slugs of an article redirect to its current one
+	router.Handle("/articles/by-slug/{slug}", http.HandlerFunc(handler.GetArticleBySlug)).Methods(http.MethodGet)
+
 	// define handler for GET on "/articles/id" endpoint
 	router.Handle("/articles/{id}", http.HandlerFunc(handler.GetArticleById)).Methods(http.MethodGet)
 
 	// define handler for POST on "/articles" endpoint
 	router.Handle("/articles", http.HandlerFunc(handler.AddArticle)).Methods(http.MethodPost)
 
+	// define handler for PUT on "/articles/id" endpoint
+	router.Handle("/articles/{id}", http.HandlerFunc(handler.UpdateArticle)).Methods(http.MethodPut)
+
+	// define handler for PATCH on "/articles/id" endpoint
+	router.Handle("/articles/{id}", http.HandlerFunc(handler.PatchArticle)).Methods(http.MethodPatch)
+
+	// define handler for POST on "/articles/id/publish" endpoint
+	router.Handle("/articles/{id}/publish", http.HandlerFunc(handler.PublishArticle)).Methods(http.MethodPost)
+
+	// define handler for POST on "/articles/id/unpublish" endpoint
+	router.Handle("/articles/{id}/unpublish", http.HandlerFunc(handler.UnpublishArticle)).Methods(http.MethodPost)
+
+	// define handler for GET on "/articles/id/revisions" endpoint
+	router.Handle("/articles/{id}/revisions", http.HandlerFunc(handler.ListRevisions)).Methods(http.MethodGet)
+
+	// define handler for GET on "/articles/id/revisions/n" endpoint
+	router.Handle("/articles/{id}/revisions/{n}", http.HandlerFunc(handler.GetRevision)).Methods(http.MethodGet)
+
+	// define handler for POST on "/articles/id/revisions/n/restore" endpoint
+	router.Handle("/articles/{id}/revisions/{n}/restore", http.HandlerFunc(handler.RestoreRevision)).Methods(http.MethodPost)
+
+	// define handler for GET on "/articles/id/diff?from=n&to=m" endpoint
+	router.Handle("/articles/{id}/diff", http.HandlerFunc(handler.DiffRevisions)).Methods(http.MethodGet)
+
 	// define handler for DELETE on "/articles/id" endpoint
 	router.Handle("/articles/{id}", http.HandlerFunc(handler.DeleteArticleById)).Methods(http.MethodDelete)
 
+	// define handler for GET on "/articles/id/comments" endpoint
+	router.Handle("/articles/{id}/comments", http.HandlerFunc(handler.ListComments)).Methods(http.MethodGet)
+
+	// define handler for POST on "/articles/id/comments" endpoint
+	router.Handle("/articles/{id}/comments", http.HandlerFunc(handler.AddComment)).Methods(http.MethodPost)
+
+	// define handler for DELETE on "/comments/id" endpoint
+	router.Handle("/comments/{id}", http.HandlerFunc(handler.DeleteCommentById)).Methods(http.MethodDelete)
+
+	// define handler for GET on "/tags" endpoint
+	router.Handle("/tags", http.HandlerFunc(handler.ListTags)).Methods(http.MethodGet)
+
+	// define handler for GET on "/tags/tag/articles" endpoint
+	router.Handle("/tags/{tag}/articles", http.HandlerFunc(handler.ListArticlesByTag)).Methods(http.MethodGet)
+
+	// define handler for GET on "/feed.rss" endpoint
+	router.Handle("/feed.rss", http.HandlerFunc(handler.FeedRSS)).Methods(http.MethodGet)
+
+	// define handler for GET on "/feed.atom" endpoint
+	router.Handle("/feed.atom", http.HandlerFunc(handler.FeedAtom)).Methods(http.MethodGet)
+
+	// define handler for GET on "/authors" endpoint
+	router.Handle("/authors", http.HandlerFunc(handler.ListAuthors)).Methods(http.MethodGet)
+
+	// define handler for POST on "/authors" endpoint
+	router.Handle("/authors", http.HandlerFunc(handler.AddAuthor)).Methods(http.MethodPost)
+
+	// define handler for GET on "/authors/{id}" endpoint
+	router.Handle("/authors/{id}", http.HandlerFunc(handler.GetAuthorById)).Methods(http.MethodGet)
+
+	// define handler for PUT on "/authors/{id}" endpoint
+	router.Handle("/authors/{id}", http.HandlerFunc(handler.UpdateAuthor)).Methods(http.MethodPut)
+
+	// define handler for PUT on "/authors/{id}/profile" endpoint
+	router.Handle("/authors/{id}/profile", http.HandlerFunc(handler.SetAuthorProfile)).Methods(http.MethodPut)
+
+	// define handler for GET on "/authors/{id}/page" endpoint
+	router.Handle("/authors/{id}/page", http.HandlerFunc(handler.GetAuthorPage)).Methods(http.MethodGet)
+
+	// define handler for GET on "/authors/{id}/feed.atom" endpoint
+	router.Handle("/authors/{id}/feed.atom", http.HandlerFunc(handler.AuthorFeedAtom)).Methods(http.MethodGet)
+
+	// define handler for GET on "/authors/{id}/articles" endpoint
+	router.Handle("/authors/{id}/articles", http.HandlerFunc(handler.ListAuthorArticles)).Methods(http.MethodGet)
+
 	// define handler for DELETE on "/authors" endpoint
 	router.Handle("/authors", http.HandlerFunc(handler.DeleteAuthorByNameAndEmail)).Methods(http.MethodDelete)
 
+	// define handler for DELETE on "/authors/{id}" endpoint
+	router.Handle("/authors/{id}", http.HandlerFunc(handler.DeleteAuthorById)).Methods(http.MethodDelete)
+
+	// define handler for POST on "/authors/{id}/password-reset" endpoint
+	router.Handle("/authors/{id}/password-reset", http.HandlerFunc(auth.IssuePasswordReset)).Methods(http.MethodPost)
+
+	// define handler for PUT on "/authors/{id}/role" endpoint
+	router.Handle("/authors/{id}/role", http.HandlerFunc(handler.SetAuthorRole)).Methods(http.MethodPut)
+
 	// define handler for not found endpoint
 	router.NotFoundHandler = http.NotFoundHandler()
 
@@ -57,29 +211,99 @@ func main() {
 
 	// defines the server instance by specifing the endpoints handler and the address (host:port)
 	server := &http.Server{
-		Handler: router,
-		Addr:    "127.0.0.1:8000",
+		Handler: RequestID(logger)(AccessLog(auth.Middleware(router))),
+		Addr:    cfg.Server.Addr,
 		// Good practice: enforce timeouts for servers you create!
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	// stop on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(logging.NewContext(context.Background(), logger), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// publish the scheduled articles in the background
+	scheduler := Scheduler{Service: handler.Service, Interval: cfg.Scheduler.Interval}
+	schedulerDone := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(schedulerDone)
+	}()
+
+	logger.Info("starting the server", "addr", cfg.Server.Addr)
+
+	// start the server, until it fails or a signal is received
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		logger.Error("server failed", "error", err)
+	case <-ctx.Done():
+		logger.Info("shutting down the server")
+	}
+	stop()
+
+	// stop accepting connections and let the in-flight requests finish within the drain timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if e := server.Shutdown(shutdownCtx); e != nil {
+		logger.Error("cannot drain the connections", "error", e)
 	}
 
-	log.Println("Starting the server...listening on port 8000")
+	// close the store once nothing uses it anymore
+	<-schedulerDone
+	if e := closeStore(); e != nil {
+		logger.Error("cannot close the store", "error", e)
+	}
 
-	// start the server
-	err := server.ListenAndServe()
 	if err != nil {
-		log.Fatal(err)
+		os.Exit(1)
 	}
+	logger.Info("server stopped")
 }
 
-// Connects to a postgres database.
-func psqlConnect(host string, port int, user string, password string, dbname string, schema string) *sql.DB {
+// Opens the configured store, along with the function closing it on shutdown.
+// The memory store is loaded from its snapshot file and saved to it when closed, if any,
+// the sqlite database is created if needed and its pending migrations are applied, slugs included.
+func openStore(cfg config.Config, logger *logging.Logger) (repo.BlogService, func() error) {
+
+	switch cfg.Driver() {
+	case config.StoreMemory:
+		if cfg.Store.Snapshot == "" {
+			logger.Info("using the memory store, the content is lost on shutdown")
+			return memory.New(), func() error { return nil }
+		}
+
+		store, err := memory.Load(cfg.Store.Snapshot)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info("using the memory store, saved on shutdown", "snapshot", cfg.Store.Snapshot)
+		return store, func() error { return store.Save(cfg.Store.Snapshot) }
+
+	case config.StoreSQLite:
+		database := sqliteConnect(cfg.Database, logger)
+		if _, err := migrations.SQLite.Up(database); err != nil {
+			panic(err)
+		}
+		if err := backfillSlugs(config.StoreSQLite, database, logger); err != nil {
+			panic(err)
+		}
+		return &sqlite.SQLiteRepository{DB: database, QueryTimeout: cfg.Database.QueryTimeout}, database.Close
+	}
+
+	database := psqlConnect(cfg.Database, logger)
+	return &postgres.PSQLRepository{DB: database, QueryTimeout: cfg.Database.QueryTimeout}, database.Close
+}
 
-	psqlConString := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable search_path=%s",
-		host, port, user, password, dbname, schema)
+// Connects to a sqlite database.
+func sqliteConnect(c config.Database, logger *logging.Logger) *sql.DB {
 
-	db, err := sql.Open("postgres", psqlConString)
+	db, err := sqlite.Open(c.SQLitePath())
 	if err != nil {
 		panic(err)
 	}
@@ -89,6 +313,39 @@ func psqlConnect(host string, port int, user string, password string, dbname str
 		panic(err)
 	}
 
-	fmt.Println("Successfully connected to postgres!")
+	logger.Info("opened database", "driver", "sqlite", "path", c.SQLitePath())
 	return db
 }
+
+// Connects to a postgres database.
+func psqlConnect(c config.Database, logger *logging.Logger) *sql.DB {
+
+	db, err := sql.Open("postgres", c.DataSourceName())
+	if err != nil {
+		panic(err)
+	}
+
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	logger.Info("opened database", "driver", "postgres", "host", c.Host, "name", c.Name)
+	return db
+}
+
+// Returns the configured secret used to sign JWTs.
+// Without it a random secret is used, and the issued JWTs do not survive a restart.
+func authSecret(configured string, logger *logging.Logger) []byte {
+
+	if configured != "" {
+		return []byte(configured)
+	}
+
+	logger.Info("auth secret is not configured, using a random secret")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"blog/logging"
+	"context"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader is the header carrying the id of a request, from the client or the proxies
+// in front of the server, and back in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID matches the request ids taken from the clients, other ids are replaced.
+var validRequestID = regexp.MustCompile(`^[\w.:-]{1,128}$`)
+
+// RequestIDFrom returns the id of the request the context belongs to, if any.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// RequestID returns a middleware giving every request an id, the one of its X-Request-ID header
+// if valid or else a new uuid. The id is sent back in the response, and the logger of the request
+// context, derived from the given one, logs it with every entry.
+func RequestID(logger *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID.MatchString(id) {
+				id = uuid.New().String()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := context.WithValue(r.Context(), requestIDKey, id)
+			ctx = logging.NewContext(ctx, logger.With("request_id", id))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// accessEntry is what the access log knows of a request, the route is set once the router matched it.
+type accessEntry struct {
+	route string
+}
+
+// responseRecorder records the status and the size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// AccessLog logs every request once served, with the logger of its context: its method, the template
+// of its route, the status and size of the response, its latency and the address of the client.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		entry := &accessEntry{}
+		rec := &responseRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessEntryKey, entry)))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		logging.FromContext(r.Context()).Info("request",
+			"method", r.Method,
+			"route", entry.route,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// RecordRoute is the middleware of the router telling the access log the template of the matched route,
+// rather than the path which would make a route of every article.
+func RecordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if entry, ok := r.Context().Value(accessEntryKey).(*accessEntry); ok {
+			if route := mux.CurrentRoute(r); route != nil {
+				entry.route, _ = route.GetPathTemplate()
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
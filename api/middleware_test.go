@@ -0,0 +1,141 @@
+package main
+
+import (
+	"blog/logging"
+	repo "blog/repo"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+// logEntries returns the JSON lines of a log.
+func logEntries(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRequestID(t *testing.T) {
+
+	serve := func(header string) (*httptest.ResponseRecorder, string) {
+		var id string
+		h := RequestID(logging.New(&bytes.Buffer{}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = RequestIDFrom(r.Context())
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/articles", nil)
+		if header != "" {
+			req.Header.Set(RequestIDHeader, header)
+		}
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res, id
+	}
+
+	t.Run("propagate the id of the request", func(t *testing.T) {
+		res, id := serve("req-42.a:b")
+		require.Equal(t, id, "req-42.a:b")
+		require.Equal(t, res.Header().Get(RequestIDHeader), "req-42.a:b")
+	})
+
+	t.Run("assign an id to requests without one", func(t *testing.T) {
+		res, id := serve("")
+		require.Len(t, id, 36)
+		require.Equal(t, res.Header().Get(RequestIDHeader), id)
+	})
+
+	t.Run("replace invalid ids", func(t *testing.T) {
+		_, id := serve("bad id\n")
+		require.Len(t, id, 36)
+
+		_, id = serve(strings.Repeat("a", 129))
+		require.Len(t, id, 36)
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+
+	serve := func(r *MockService, method string, path string) (*httptest.ResponseRecorder, []map[string]interface{}) {
+		var out bytes.Buffer
+		h := BlogServer{Service: repo.Annotated(r)}
+
+		router := mux.NewRouter()
+		router.Use(RecordRoute)
+		router.Handle("/articles/{id}", http.HandlerFunc(h.GetArticleById)).Methods(http.MethodGet)
+
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(RequestIDHeader, "abc")
+		req.RemoteAddr = "192.0.2.1:1234"
+		res := httptest.NewRecorder()
+		RequestID(logging.New(&out))(AccessLog(router)).ServeHTTP(res, req)
+		return res, logEntries(t, &out)
+	}
+
+	t.Run("log requests with their route template", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: func(id string) (repo.Article, error) {
+				a := article
+				a.Id = id
+				return a, nil
+			},
+			GetAuthorByIdFunc: func(id string) (repo.Author, error) {
+				return author, nil
+			},
+		}
+
+		res, entries := serve(r, http.MethodGet, "/articles/"+expectedArticleId)
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Len(t, entries, 1)
+
+		entry := entries[0]
+		require.Equal(t, entry["level"], "info")
+		require.Equal(t, entry["msg"], "request")
+		require.Equal(t, entry["request_id"], "abc")
+		require.Equal(t, entry["method"], http.MethodGet)
+		require.Equal(t, entry["route"], "/articles/{id}")
+		require.Equal(t, entry["status"], float64(http.StatusOK))
+		require.Equal(t, entry["bytes"], float64(res.Body.Len()))
+		require.Equal(t, entry["remote_addr"], "192.0.2.1:1234")
+		require.Contains(t, entry, "latency_ms")
+	})
+
+	t.Run("log repository errors with the id of the request", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: func(id string) (repo.Article, error) {
+				return repo.Article{}, errors.New("cannot scan article")
+			},
+		}
+
+		res, entries := serve(r, http.MethodGet, "/articles/"+expectedArticleId)
+		require.Equal(t, res.Code, http.StatusInternalServerError)
+		require.Len(t, entries, 2)
+
+		require.Equal(t, entries[0]["msg"], "request failed")
+		require.Equal(t, entries[0]["operation"], "GetArticleById")
+		require.Equal(t, entries[0]["error"], "cannot scan article")
+		require.Equal(t, entries[1]["msg"], "request")
+		require.Equal(t, entries[1]["status"], float64(http.StatusInternalServerError))
+		for _, entry := range entries {
+			require.Equal(t, entry["request_id"], "abc")
+		}
+	})
+
+	t.Run("log requests matching no route", func(t *testing.T) {
+		res, entries := serve(&MockService{}, http.MethodGet, "/unknown")
+		require.Equal(t, res.Code, http.StatusNotFound)
+		require.Len(t, entries, 1)
+		require.Equal(t, entries[0]["route"], "")
+		require.Equal(t, entries[0]["status"], float64(http.StatusNotFound))
+	})
+}
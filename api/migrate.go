@@ -0,0 +1,123 @@
+package main
+
+import (
+	"blog/config"
+	"blog/logging"
+	"blog/migrations"
+	"blog/repo/postgres"
+	"blog/repo/sqlite"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// runMigrate runs the migrate subcommand, its arguments are the action (up, down, status or redo)
+// followed by the configuration flags of the database.
+func runMigrate(name string, args []string, logger *logging.Logger) error {
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s migrate up|down|status|redo [flags]", name)
+	}
+	action := args[0]
+	switch action {
+	case "up", "down", "status", "redo":
+	default:
+		return fmt.Errorf("unknown migrate action %q, expected up, down, status or redo", action)
+	}
+
+	cfg, err := config.Load(name+" migrate "+action, args[1:], os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	// the migrations of the database of the configuration
+	var database *sql.DB
+	var set migrations.Set
+
+	switch cfg.Driver() {
+	case config.StorePostgres:
+		database, set = psqlConnect(cfg.Database, logger), migrations.Postgres
+		defer database.Close()
+
+		err = migrations.CreateSchema(database, cfg.Database.Schema)
+		if err != nil {
+			return err
+		}
+
+	case config.StoreSQLite:
+		database, set = sqliteConnect(cfg.Database, logger), migrations.SQLite
+		defer database.Close()
+
+	default:
+		return fmt.Errorf("migrations only apply to the %s and %s stores", config.StorePostgres, config.StoreSQLite)
+	}
+
+	switch action {
+	case "up":
+		n, err := set.Up(database)
+		if err != nil {
+			return err
+		}
+		logger.Info("applied migrations", "migrations", n)
+		if err := backfillSlugs(cfg.Driver(), database, logger); err != nil {
+			return err
+		}
+
+	case "down":
+		n, err := set.Down(database, 1)
+		if err != nil {
+			return err
+		}
+		logger.Info("rolled back migrations", "migrations", n)
+
+	case "redo":
+		err := set.Redo(database)
+		if err != nil {
+			return err
+		}
+		logger.Info("rolled back and applied the last migration again")
+		if err := backfillSlugs(cfg.Driver(), database, logger); err != nil {
+			return err
+		}
+
+	case "status":
+		statuses, err := set.List(database)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "MIGRATION\tAPPLIED AT")
+		for _, s := range statuses {
+			applied := "no"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", s.Id, applied)
+		}
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// backfillSlugs gives the articles of the database the slugs of their titles,
+// which the migrations adding the slugs leave to Slugify.
+func backfillSlugs(driver string, database *sql.DB, logger *logging.Logger) error {
+
+	backfill := postgres.BackfillSlugs
+	if driver == config.StoreSQLite {
+		backfill = sqlite.BackfillSlugs
+	}
+
+	n, err := backfill(context.Background(), database)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logger.Info("gave the articles the slugs of their titles", "articles", n)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	repo "blog/repo"
+	"errors"
+	"net/http"
+)
+
+// Action is an operation of the api subject to authorization.
+type Action string
+
+const (
+	ActionCreateArticle   Action = "create articles"
+	ActionEditArticle     Action = "edit articles"
+	ActionPublishArticle  Action = "publish articles"
+	ActionDeleteArticle   Action = "delete articles"
+	ActionViewUnpublished Action = "view unpublished articles"
+	ActionViewRevisions   Action = "view revisions"
+	ActionDeleteComment   Action = "delete comments"
+	ActionManageAuthors   Action = "manage authors"
+	ActionViewEmails      Action = "view author emails"
+)
+
+// Resource is what an action applies to, OwnerId is the id of the author owning it.
+type Resource struct {
+	OwnerId string
+}
+
+// rule lists the roles allowed to do an action on any resource. Authors may do it on the resources
+// they own when the rule has an owned reason, which is given when they try on someone else's.
+type rule struct {
+	roles []string
+	owned string
+}
+
+var editors = []string{repo.RoleAdmin, repo.RoleEditor}
+
+// policy holds the rule of every action, admins may do everything.
+var policy = map[Action]rule{
+	ActionCreateArticle:   {roles: editors, owned: "authors can only post articles as themselves"},
+	ActionEditArticle:     {roles: editors, owned: "authors can only edit their own articles"},
+	ActionPublishArticle:  {roles: editors, owned: "authors can only publish their own articles"},
+	ActionDeleteArticle:   {roles: editors, owned: "authors can only delete their own articles"},
+	ActionViewUnpublished: {roles: editors, owned: "authors can only view their own unpublished articles"},
+	ActionViewRevisions:   {roles: editors, owned: "authors can only view revisions of their own articles"},
+	ActionDeleteComment:   {roles: editors, owned: "authors can only delete comments on their own articles"},
+	ActionManageAuthors:   {roles: []string{repo.RoleAdmin}},
+	ActionViewEmails:      {roles: editors},
+}
+
+// ErrForbidden is the error of denied actions, wrapped with the reason of the denial.
+var ErrForbidden = errors.New("forbidden")
+
+type denial struct {
+	reason string
+}
+
+func (d *denial) Error() string { return d.reason }
+
+func (d *denial) Unwrap() error { return ErrForbidden }
+
+// authorize returns nil if the principal may do the action on the resource, or an error giving the reason.
+func authorize(p Principal, action Action, res Resource) error {
+
+	if p.Role == repo.RoleAdmin {
+		return nil
+	}
+
+	rule := policy[action]
+	for _, role := range rule.roles {
+		if p.Role == role {
+			return nil
+		}
+	}
+
+	if rule.owned != "" && p.Role == repo.RoleAuthor {
+		if res.OwnerId == p.AuthorId {
+			return nil
+		}
+		return &denial{rule.owned}
+	}
+
+	if len(rule.roles) == 1 {
+		return &denial{"only " + rule.roles[0] + "s can " + string(action)}
+	}
+	return &denial{p.Role + "s cannot " + string(action)}
+}
+
+// forbid answers 403 with the reason of the denial.
+func forbid(w http.ResponseWriter, r *http.Request, err error) {
+	writeError(w, r, err)
+}
+
+// authorizeArticle returns the article with the given id if the principal may do the action on it,
+// or answers 404 if the article does not exist and 403 if the action is denied.
+func (h *BlogServer) authorizeArticle(w http.ResponseWriter, r *http.Request, p Principal, id string, action Action) (repo.Article, bool) {
+
+	article, err := h.Service.GetArticleById(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return repo.Article{}, false
+	}
+
+	err = authorize(p, action, Resource{OwnerId: article.Author.Id})
+	if err != nil {
+		forbid(w, r, err)
+		return repo.Article{}, false
+	}
+
+	return article, true
+}
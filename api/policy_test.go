@@ -0,0 +1,44 @@
+package main
+
+import (
+	repo "blog/repo"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+
+	own := Resource{OwnerId: author.Id}
+	other := Resource{OwnerId: otherAuthorId}
+
+	for _, c := range []struct {
+		role    string
+		action  Action
+		res     Resource
+		allowed bool
+	}{
+		{repo.RoleAdmin, ActionManageAuthors, Resource{}, true},
+		{repo.RoleAdmin, ActionDeleteArticle, other, true},
+		{repo.RoleEditor, ActionEditArticle, other, true},
+		{repo.RoleEditor, ActionDeleteComment, other, true},
+		{repo.RoleEditor, ActionManageAuthors, Resource{}, false},
+		{repo.RoleAuthor, ActionEditArticle, own, true},
+		{repo.RoleAuthor, ActionDeleteArticle, own, true},
+		{repo.RoleAuthor, ActionEditArticle, other, false},
+		{repo.RoleAuthor, ActionViewRevisions, other, false},
+		{repo.RoleAuthor, ActionManageAuthors, Resource{}, false},
+		{repo.RoleReader, ActionCreateArticle, own, false},
+		{repo.RoleReader, ActionViewUnpublished, own, false},
+		{"", ActionEditArticle, own, false},
+	} {
+		err := authorize(Principal{AuthorId: author.Id, Role: c.role}, c.action, c.res)
+		if c.allowed {
+			require.NoError(t, err, c)
+			continue
+		}
+		require.True(t, errors.Is(err, ErrForbidden), c)
+		require.NotEmpty(t, err.Error(), c)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"blog/logging"
+	repo "blog/repo"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Problem is the application/problem+json body of the error responses (RFC 7807). Code is a stable
+// identifier of the error for clients to rely on, Errors lists the invalid fields of a request.
+type Problem struct {
+	Type   string            `json:"type"`
+	Title  string            `json:"title"`
+	Status int               `json:"status"`
+	Code   string            `json:"code"`
+	Detail string            `json:"detail,omitempty"`
+	Errors []repo.FieldError `json:"errors,omitempty"`
+}
+
+func (p *Problem) Error() string { return p.Detail }
+
+// problem returns the problem of an error detected by the api rather than by the repository.
+func problem(status int, code string, detail string) *Problem {
+	return &Problem{Status: status, Code: code, Detail: detail}
+}
+
+// Errors of the api which are not errors of the repository.
+var (
+	errMalformedBody   = problem(http.StatusBadRequest, "malformed_body", "body is not correct")
+	errUnauthenticated = problem(http.StatusUnauthorized, "unauthenticated", "authentication required")
+	errInvalidToken    = problem(http.StatusUnauthorized, "invalid_token", "invalid or expired token")
+)
+
+// invalidParam returns the error of a parameter of the url or of the query string which is not valid.
+// Unlike the invalid fields of a body, which are answered with 422, invalid parameters are bad requests.
+func invalidParam(name string, message string) *Problem {
+	return &Problem{
+		Status: http.StatusBadRequest,
+		Code:   "invalid_parameter",
+		Detail: name + " " + message,
+		Errors: []repo.FieldError{{Field: name, Message: message}},
+	}
+}
+
+// invalidId is the error of an id of the url which is not a valid uuid.
+var invalidId = invalidParam("id", "is not a valid uuid")
+
+// kindStatus is the status code of every kind of repository error.
+var kindStatus = map[repo.Kind]int{
+	repo.KindInternal:    http.StatusInternalServerError,
+	repo.KindNotFound:    http.StatusNotFound,
+	repo.KindConflict:    http.StatusConflict,
+	repo.KindValidation:  http.StatusUnprocessableEntity,
+	repo.KindUnavailable: http.StatusServiceUnavailable,
+}
+
+// toProblem returns the problem an error is answered with. The internal and unavailable errors
+// are logged, with the operation of the repository which failed if known, and answered without
+// their detail, which could leak the internals of the server.
+func toProblem(ctx context.Context, err error) *Problem {
+
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	var d *denial
+	if errors.As(err, &d) {
+		return problem(http.StatusForbidden, "forbidden", d.reason)
+	}
+
+	var e *repo.Error
+	if errors.As(err, &e) && e.Kind != repo.KindInternal && e.Kind != repo.KindUnavailable {
+		return &Problem{Status: kindStatus[e.Kind], Code: e.Code, Detail: e.Message, Errors: e.Fields}
+	}
+
+	logger := logging.FromContext(ctx)
+	if op := repo.OperationOf(err); op != "" {
+		logger = logger.With("operation", op)
+	}
+	logger.Error("request failed", "error", err)
+	if repo.KindOf(err) == repo.KindUnavailable {
+		return problem(http.StatusServiceUnavailable, "service_unavailable", "service unavailable")
+	}
+	return problem(http.StatusInternalServerError, "internal_error", "internal server error")
+}
+
+// writeError answers a request with the problem of an error.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+
+	p := *toProblem(r.Context(), err)
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	repo "blog/repo"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteError(t *testing.T) {
+
+	write := func(err error) (*httptest.ResponseRecorder, Problem) {
+		res := httptest.NewRecorder()
+		writeError(res, httptest.NewRequest(http.MethodGet, "/articles", nil), err)
+
+		var p Problem
+		require.Equal(t, res.Header().Get("Content-Type"), "application/problem+json")
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &p))
+		require.Equal(t, p.Status, res.Code)
+		require.Equal(t, p.Type, "about:blank")
+		require.Equal(t, p.Title, http.StatusText(res.Code))
+		return res, p
+	}
+
+	t.Run("not found errors are 404", func(t *testing.T) {
+		res, p := write(fmt.Errorf("cannot get article: %w", repo.ErrArticleNotFound))
+		require.Equal(t, res.Code, http.StatusNotFound)
+		require.Equal(t, p.Code, "article_not_found")
+		require.Equal(t, p.Detail, "article not found")
+	})
+
+	t.Run("conflicts are 409", func(t *testing.T) {
+		res, p := write(repo.ErrAuthorExists)
+		require.Equal(t, res.Code, http.StatusConflict)
+		require.Equal(t, p.Code, "author_exists")
+	})
+
+	t.Run("validation errors are 422 with their fields", func(t *testing.T) {
+		var v validator
+		v.required("title", "")
+		v.required("body", "")
+		v.required("slug", "test-title")
+		res, p := write(v.err())
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+		require.Equal(t, p.Code, "validation_failed")
+		require.Equal(t, p.Errors, []repo.FieldError{{Field: "title", Message: "is required"}, {Field: "body", Message: "is required"}})
+	})
+
+	t.Run("denials are 403 with their reason", func(t *testing.T) {
+		err := authorize(Principal{AuthorId: author.Id, Role: repo.RoleAuthor}, ActionManageAuthors, Resource{})
+		res, p := write(err)
+		require.Equal(t, res.Code, http.StatusForbidden)
+		require.Equal(t, p.Code, "forbidden")
+		require.Equal(t, p.Detail, "only admins can manage authors")
+	})
+
+	t.Run("invalid parameters are 400", func(t *testing.T) {
+		res, p := write(invalidId)
+		require.Equal(t, res.Code, http.StatusBadRequest)
+		require.Equal(t, p.Code, "invalid_parameter")
+		require.Equal(t, p.Errors, []repo.FieldError{{Field: "id", Message: "is not a valid uuid"}})
+	})
+
+	t.Run("api problems are written as is", func(t *testing.T) {
+		res, p := write(errUnauthenticated)
+		require.Equal(t, res.Code, http.StatusUnauthorized)
+		require.Equal(t, p.Code, "unauthenticated")
+	})
+
+	t.Run("timeouts are 503", func(t *testing.T) {
+		res, p := write(fmt.Errorf("cannot execute query: %w", context.DeadlineExceeded))
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+		require.Equal(t, p.Code, "service_unavailable")
+	})
+
+	t.Run("unexpected errors are 500 without details", func(t *testing.T) {
+		res, p := write(errors.New("pq: syntax error at or near \"FROM\""))
+		require.Equal(t, res.Code, http.StatusInternalServerError)
+		require.Equal(t, p.Code, "internal_error")
+		require.NotContains(t, res.Body.String(), "syntax error")
+	})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	repo "blog/repo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+func (h *BlogServer) ListRevisions(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	if _, ok := h.authorizeArticle(w, r, p, id.String(), ActionViewRevisions); !ok {
+		return
+	}
+
+	revisions, err := h.Service.ListRevisions(ctx, id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// every article has at least the revision recorded on creation
+	if len(revisions) == 0 {
+		writeError(w, r, repo.ErrArticleNotFound)
+		return
+	}
+
+	data, err := json.Marshal(revisions)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (h *BlogServer) GetRevision(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	n, err := parseRevisionNumber("n", vars["n"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, ok := h.authorizeArticle(w, r, p, id.String(), ActionViewRevisions); !ok {
+		return
+	}
+
+	revision, err := h.Service.GetRevision(ctx, id.String(), n)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(revision)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// DiffRevisions writes the unified diff between the revisions given by the from and to query parameters.
+func (h *BlogServer) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	from, err := parseRevisionNumber("from", r.FormValue("from"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	to, err := parseRevisionNumber("to", r.FormValue("to"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, ok := h.authorizeArticle(w, r, p, id.String(), ActionViewRevisions); !ok {
+		return
+	}
+
+	revisions := make([]repo.Revision, 0, 2)
+	for _, n := range []int{from, to} {
+		revision, err := h.Service.GetRevision(ctx, id.String(), n)
+		if err != nil {
+			if errors.Is(err, repo.ErrRevisionNotFound) {
+				writeError(w, r, repo.NewError(repo.KindNotFound, "revision_not_found", fmt.Sprintf("revision %d not found", n)))
+				return
+			}
+			writeError(w, r, err)
+			return
+		}
+		revisions = append(revisions, revision)
+	}
+
+	diff, err := diffRevisions(revisions[0], revisions[1])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err = w.Write([]byte(diff))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+func (h *BlogServer) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	p, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, r, invalidId)
+		return
+	}
+
+	n, err := parseRevisionNumber("n", vars["n"])
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, ok := h.authorizeArticle(w, r, p, id.String(), ActionEditArticle); !ok {
+		return
+	}
+
+	err = h.Service.RestoreRevision(ctx, id.String(), n)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// parseRevisionNumber parses the revision number of the named parameter, which starts at 1.
+func parseRevisionNumber(name string, s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, invalidParam(name, "must be a positive integer")
+	}
+	return n, nil
+}
+
+// diffRevisions returns the unified diff of the title and body between two revisions.
+func diffRevisions(from repo.Revision, to repo.Revision) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from.Title + "\n\n" + from.Body),
+		B:        difflib.SplitLines(to.Title + "\n\n" + to.Body),
+		FromFile: fmt.Sprintf("revision %d", from.Number),
+		ToFile:   fmt.Sprintf("revision %d", to.Number),
+		Context:  3,
+	})
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	repo "blog/repo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+var revisions = []repo.Revision{
+	{ArticleId: expectedArticleId, Number: 1, Title: "test", Body: "first line\nsecond line"},
+	{ArticleId: expectedArticleId, Number: 2, Title: "test", Body: "first line\nchanged line"},
+}
+
+func TestListRevisions(t *testing.T) {
+
+	t.Run("can list revisions", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			ListRevisionsFunc: func(articleId string) ([]repo.Revision, error) {
+				require.Equal(t, articleId, expectedArticleId)
+				return revisions, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s/revisions", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+		h.ListRevisions(res, req)
+
+		var revs []repo.Revision
+		json.Unmarshal(res.Body.Bytes(), &revs) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, revs, revisions)
+	})
+
+	t.Run("return 404 if article has no revisions", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			ListRevisionsFunc: func(articleId string) ([]repo.Revision, error) {
+				return []repo.Revision{}, nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s/revisions", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+		h.ListRevisions(res, req)
+
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+}
+
+func TestGetRevision(t *testing.T) {
+
+	t.Run("can get revision", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			GetRevisionFunc: func(articleId string, number int) (repo.Revision, error) {
+				require.Equal(t, number, 2)
+				return revisions[1], nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s/revisions/2", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId, "n": "2"})
+		res := httptest.NewRecorder()
+		h.GetRevision(res, req)
+
+		var rev repo.Revision
+		json.Unmarshal(res.Body.Bytes(), &rev) // nolint: errcheck
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, rev, revisions[1])
+	})
+
+	t.Run("return 400 if revision number is not valid", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s/revisions/0", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId, "n": "0"})
+		res := httptest.NewRecorder()
+		h.GetRevision(res, req)
+
+		require.Equal(t, res.Code, http.StatusBadRequest)
+	})
+
+	t.Run("return 404 if revision not found", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			GetRevisionFunc: func(articleId string, number int) (repo.Revision, error) {
+				return repo.Revision{}, repo.ErrRevisionNotFound
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s/revisions/3", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId, "n": "3"})
+		res := httptest.NewRecorder()
+		h.GetRevision(res, req)
+
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+}
+
+func TestDiffRevisions(t *testing.T) {
+
+	r := &MockService{
+		GetArticleByIdFunc: getOwnArticle,
+		GetRevisionFunc: func(articleId string, number int) (repo.Revision, error) {
+			if number > len(revisions) {
+				return repo.Revision{}, repo.ErrRevisionNotFound
+			}
+			return revisions[number-1], nil
+		},
+	}
+
+	t.Run("can diff two revisions", func(t *testing.T) {
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s/diff?from=1&to=2", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+		h.DiffRevisions(res, req)
+
+		require.Equal(t, res.Code, http.StatusOK)
+		require.Equal(t, res.Body.String(), `--- revision 1
++++ revision 2
+@@ -1,4 +1,4 @@
+ test
+ 
+ first line
+-second line
++changed line
+`)
+	})
+
+	t.Run("return 400 if to is missing", func(t *testing.T) {
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s/diff?from=1", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+		h.DiffRevisions(res, req)
+
+		require.Equal(t, res.Code, http.StatusBadRequest)
+	})
+
+	t.Run("return 404 if a revision is not found", func(t *testing.T) {
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/articles/%s/diff?from=1&to=3", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId})
+		res := httptest.NewRecorder()
+		h.DiffRevisions(res, req)
+
+		require.Equal(t, res.Code, http.StatusNotFound)
+	})
+}
+
+func TestRestoreRevision(t *testing.T) {
+
+	t.Run("can restore revision", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			RestoreRevisionFunc: func(articleId string, number int) error {
+				require.Equal(t, articleId, expectedArticleId)
+				require.Equal(t, number, 1)
+				return nil
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/articles/%s/revisions/1/restore", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId, "n": "1"})
+		res := httptest.NewRecorder()
+		h.RestoreRevision(res, req)
+
+		require.Equal(t, res.Code, http.StatusOK)
+	})
+
+	t.Run("return 503 if service fails", func(t *testing.T) {
+		r := &MockService{
+			GetArticleByIdFunc: getOwnArticle,
+			RestoreRevisionFunc: func(articleId string, number int) error {
+				return repo.Unavailable(errors.New("service fails"))
+			},
+		}
+
+		h := BlogServer{Service: r}
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/articles/%s/revisions/1/restore", expectedArticleId), nil)
+		req = asAuthor(req)
+		req = mux.SetURLVars(req, map[string]string{"id": expectedArticleId, "n": "1"})
+		res := httptest.NewRecorder()
+		h.RestoreRevision(res, req)
+
+		require.Equal(t, res.Code, http.StatusServiceUnavailable)
+	})
+}
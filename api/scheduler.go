@@ -0,0 +1,53 @@
+package main
+
+import (
+	"blog/logging"
+	repo "blog/repo"
+	"context"
+	"time"
+)
+
+// DefaultSchedulerInterval is how often the scheduler looks for articles to publish.
+const DefaultSchedulerInterval = time.Minute
+
+// Scheduler publishes the scheduled articles when their publication time comes.
+type Scheduler struct {
+	Service  repo.BlogService
+	Interval time.Duration
+}
+
+// Run publishes the due articles, then checks again every interval until the context is done.
+func (s *Scheduler) Run(ctx context.Context) {
+
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultSchedulerInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.PublishDue(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishDue publishes the articles scheduled before the given time and returns how many.
+func (s *Scheduler) PublishDue(ctx context.Context, now time.Time) int {
+
+	count, err := s.Service.PublishScheduledArticles(ctx, now.UTC())
+	if err != nil {
+		logging.FromContext(ctx).Error("cannot publish scheduled articles", "error", err)
+		return 0
+	}
+	if count > 0 {
+		logging.FromContext(ctx).Info("published scheduled articles", "count", count)
+	}
+	return count
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler(t *testing.T) {
+
+	t.Run("publishes due articles", func(t *testing.T) {
+		now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+		r := &MockService{
+			PublishScheduledArticlesFunc: func(at time.Time) (int, error) {
+				require.Equal(t, at, now.UTC())
+				return 2, nil
+			},
+		}
+
+		s := Scheduler{Service: r}
+		require.Equal(t, s.PublishDue(context.Background(), now), 2)
+	})
+
+	t.Run("service failure publishes nothing", func(t *testing.T) {
+		r := &MockService{
+			PublishScheduledArticlesFunc: func(at time.Time) (int, error) {
+				return 0, errors.New("service fails")
+			},
+		}
+
+		s := Scheduler{Service: r}
+		require.Equal(t, s.PublishDue(context.Background(), time.Now()), 0)
+	})
+
+	t.Run("runs until the context is done", func(t *testing.T) {
+		calls := make(chan struct{}, 10)
+		r := &MockService{
+			PublishScheduledArticlesFunc: func(at time.Time) (int, error) {
+				calls <- struct{}{}
+				return 0, nil
+			},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		s := Scheduler{Service: r, Interval: time.Millisecond}
+		go func() {
+			s.Run(ctx)
+			close(done)
+		}()
+
+		<-calls
+		<-calls
+		cancel()
+		<-done
+	})
+}
@@ -1,65 +1,202 @@
 package main
 
-import repo "blog/repo"
+import (
+	repo "blog/repo"
+	"context"
+	"time"
+)
 
 type MockService struct {
-	ListArticlesFunc               func() ([]repo.Article, error)
+	PingFunc                       func(ctx context.Context) error
+	ListArticlesFunc               func(q repo.ArticleQuery) (repo.ArticlePage, error)
 	ListAuthorsFunc                func() ([]repo.Author, error)
+	ListTagsFunc                   func() ([]repo.TagCount, error)
+	SearchArticlesFunc             func(query string, opts repo.SearchOptions) ([]repo.SearchResult, error)
 	GetArticleByIdFunc             func(id string) (repo.Article, error)
+	GetArticleBySlugFunc           func(slug string) (repo.Article, error)
 	GetAuthorByIdFunc              func(id string) (repo.Author, error)
 	GetAuthorsByIdsFunc            func(ids []string) ([]repo.Author, error)
 	GetAuthorByNameAndEmailFunc    func(name string, email string) (repo.Author, error)
+	GetAuthorByEmailFunc           func(email string) (repo.Author, error)
 	AddArticleFunc                 func(a repo.Article) (string, error)
 	AddAuthorFunc                  func(a repo.Author) (string, error)
+	UpdateAuthorFunc               func(a repo.Author) error
+	SetAuthorProfileFunc           func(id string, p repo.Profile) error
+	CreateArticleWithAuthorFunc    func(a repo.Article) (string, error)
+	UpdateArticleFunc              func(a repo.Article) error
+	PatchArticleFunc               func(id string, p repo.ArticlePatch) error
+	PublishArticleFunc             func(id string) error
+	UnpublishArticleFunc           func(id string) error
+	PublishScheduledArticlesFunc   func(now time.Time) (int, error)
 	DeleteArticleByIdFunc          func(id string) error
+	SetAuthorRoleFunc              func(id string, role string) error
+	SetAuthorPasswordFunc          func(id string, hash string) error
 	DeleteAuthorByIdFunc           func(id string) error
 	DeleteAuthorByNameAndEmailFunc func(name string, email string) error
+	ListRevisionsFunc              func(articleId string) ([]repo.Revision, error)
+	GetRevisionFunc                func(articleId string, number int) (repo.Revision, error)
+	RestoreRevisionFunc            func(articleId string, number int) error
+	ListCommentsFunc               func(articleId string) ([]repo.Comment, error)
+	GetCommentByIdFunc             func(id string) (repo.Comment, error)
+	AddCommentFunc                 func(c repo.Comment) (string, error)
+	DeleteCommentByIdFunc          func(id string) error
+	ListTokensFunc                 func(authorId string) ([]repo.Token, error)
+	GetTokenByHashFunc             func(hash string) (repo.Token, error)
+	AddTokenFunc                   func(t repo.Token) (string, error)
+	RevokeTokenFunc                func(authorId string, id string) error
 	Articles                       []repo.Article
 	Authors                        []repo.Author
+	Comments                       []repo.Comment
 }
 
-func (r *MockService) ListArticles() ([]repo.Article, error) {
-	return r.ListArticlesFunc()
+func (r *MockService) Ping(ctx context.Context) error {
+	return r.PingFunc(ctx)
 }
 
-func (r *MockService) ListAuthors() ([]repo.Author, error) {
+func (r *MockService) ListArticles(ctx context.Context, q repo.ArticleQuery) (repo.ArticlePage, error) {
+	return r.ListArticlesFunc(q)
+}
+
+func (r *MockService) ListAuthors(ctx context.Context) ([]repo.Author, error) {
 	return r.ListAuthorsFunc()
 }
 
-func (r *MockService) GetArticleById(id string) (repo.Article, error) {
+func (r *MockService) ListTags(ctx context.Context) ([]repo.TagCount, error) {
+	return r.ListTagsFunc()
+}
+
+func (r *MockService) SearchArticles(ctx context.Context, query string, opts repo.SearchOptions) ([]repo.SearchResult, error) {
+	return r.SearchArticlesFunc(query, opts)
+}
+
+func (r *MockService) GetArticleById(ctx context.Context, id string) (repo.Article, error) {
 	return r.GetArticleByIdFunc(id)
 }
 
-func (r *MockService) GetAuthorById(id string) (repo.Author, error) {
+func (r *MockService) GetArticleBySlug(ctx context.Context, slug string) (repo.Article, error) {
+	return r.GetArticleBySlugFunc(slug)
+}
+
+func (r *MockService) GetAuthorById(ctx context.Context, id string) (repo.Author, error) {
 	return r.GetAuthorByIdFunc(id)
 }
 
-func (r *MockService) GetAuthorsByIds(id []string) ([]repo.Author, error) {
+func (r *MockService) GetAuthorsByIds(ctx context.Context, id []string) ([]repo.Author, error) {
 	return r.GetAuthorsByIdsFunc(id)
 }
 
-func (r *MockService) GetAuthorByNameAndEmail(name string, email string) (repo.Author, error) {
+func (r *MockService) GetAuthorByNameAndEmail(ctx context.Context, name string, email string) (repo.Author, error) {
 	return r.GetAuthorByNameAndEmailFunc(name, email)
 }
 
-func (r *MockService) AddAuthor(a repo.Author) (string, error) {
+func (r *MockService) GetAuthorByEmail(ctx context.Context, email string) (repo.Author, error) {
+	return r.GetAuthorByEmailFunc(email)
+}
+
+func (r *MockService) AddAuthor(ctx context.Context, a repo.Author) (string, error) {
 	r.Authors = append(r.Authors, a)
 	return r.AddAuthorFunc(a)
 }
 
-func (r *MockService) AddArticle(a repo.Article) (string, error) {
+func (r *MockService) UpdateAuthor(ctx context.Context, a repo.Author) error {
+	return r.UpdateAuthorFunc(a)
+}
+
+func (r *MockService) SetAuthorProfile(ctx context.Context, id string, p repo.Profile) error {
+	return r.SetAuthorProfileFunc(id, p)
+}
+
+func (r *MockService) AddArticle(ctx context.Context, a repo.Article) (string, error) {
 	r.Articles = append(r.Articles, a)
 	return r.AddArticleFunc(a)
 }
 
-func (r *MockService) DeleteAuthorById(id string) error {
+func (r *MockService) CreateArticleWithAuthor(ctx context.Context, a repo.Article) (string, error) {
+	r.Articles = append(r.Articles, a)
+	return r.CreateArticleWithAuthorFunc(a)
+}
+
+func (r *MockService) UpdateArticle(ctx context.Context, a repo.Article) error {
+	return r.UpdateArticleFunc(a)
+}
+
+func (r *MockService) PatchArticle(ctx context.Context, id string, p repo.ArticlePatch) error {
+	return r.PatchArticleFunc(id, p)
+}
+
+func (r *MockService) PublishArticle(ctx context.Context, id string) error {
+	return r.PublishArticleFunc(id)
+}
+
+func (r *MockService) UnpublishArticle(ctx context.Context, id string) error {
+	return r.UnpublishArticleFunc(id)
+}
+
+func (r *MockService) PublishScheduledArticles(ctx context.Context, now time.Time) (int, error) {
+	return r.PublishScheduledArticlesFunc(now)
+}
+
+func (r *MockService) SetAuthorRole(ctx context.Context, id string, role string) error {
+	return r.SetAuthorRoleFunc(id, role)
+}
+
+func (r *MockService) SetAuthorPassword(ctx context.Context, id string, hash string) error {
+	return r.SetAuthorPasswordFunc(id, hash)
+}
+
+func (r *MockService) DeleteAuthorById(ctx context.Context, id string) error {
 	return r.DeleteAuthorByIdFunc(id)
 }
 
-func (r *MockService) DeleteArticleById(id string) error {
+func (r *MockService) DeleteArticleById(ctx context.Context, id string) error {
 	return r.DeleteArticleByIdFunc(id)
 }
 
-func (r *MockService) DeleteAuthorByNameAndEmail(name string, email string) error {
+func (r *MockService) DeleteAuthorByNameAndEmail(ctx context.Context, name string, email string) error {
 	return r.DeleteAuthorByNameAndEmailFunc(name, email)
 }
+
+func (r *MockService) ListRevisions(ctx context.Context, articleId string) ([]repo.Revision, error) {
+	return r.ListRevisionsFunc(articleId)
+}
+
+func (r *MockService) GetRevision(ctx context.Context, articleId string, number int) (repo.Revision, error) {
+	return r.GetRevisionFunc(articleId, number)
+}
+
+func (r *MockService) RestoreRevision(ctx context.Context, articleId string, number int) error {
+	return r.RestoreRevisionFunc(articleId, number)
+}
+
+func (r *MockService) ListComments(ctx context.Context, articleId string) ([]repo.Comment, error) {
+	return r.ListCommentsFunc(articleId)
+}
+
+func (r *MockService) GetCommentById(ctx context.Context, id string) (repo.Comment, error) {
+	return r.GetCommentByIdFunc(id)
+}
+
+func (r *MockService) AddComment(ctx context.Context, c repo.Comment) (string, error) {
+	r.Comments = append(r.Comments, c)
+	return r.AddCommentFunc(c)
+}
+
+func (r *MockService) DeleteCommentById(ctx context.Context, id string) error {
+	return r.DeleteCommentByIdFunc(id)
+}
+
+func (r *MockService) ListTokens(ctx context.Context, authorId string) ([]repo.Token, error) {
+	return r.ListTokensFunc(authorId)
+}
+
+func (r *MockService) GetTokenByHash(ctx context.Context, hash string) (repo.Token, error) {
+	return r.GetTokenByHashFunc(hash)
+}
+
+func (r *MockService) AddToken(ctx context.Context, t repo.Token) (string, error) {
+	return r.AddTokenFunc(t)
+}
+
+func (r *MockService) RevokeToken(ctx context.Context, authorId string, id string) error {
+	return r.RevokeTokenFunc(authorId, id)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	repo "blog/repo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxBodyBytes is the maximum size of the body of a write request.
+const MaxBodyBytes = 1 << 20
+
+// Maximum lengths of the fields of the write requests, in characters.
+const (
+	MaxTitleLength    = 200
+	MaxArticleLength  = 100000
+	MaxCommentLength  = 5000
+	MaxNameLength     = 100
+	MaxEmailLength    = 254
+	MaxTagLength      = 50
+	MaxTags           = 20
+	MaxBioLength      = 2000
+	MaxURLLength      = 2048
+	MaxSocialLinks    = 20
+	MaxPasswordLength = 72
+)
+
+// errBodyTooLarge is the error of a request body larger than MaxBodyBytes.
+var errBodyTooLarge = problem(http.StatusRequestEntityTooLarge, "body_too_large", fmt.Sprintf("body is larger than %d bytes", MaxBodyBytes))
+
+// bodyReader reads the body of a request, counting the bytes read and keeping the last read error.
+type bodyReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	if err != nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// exceeded reports whether reading stopped at the limit of the body, the body being larger.
+func (b *bodyReader) exceeded() bool {
+	return b.n >= MaxBodyBytes && b.err != nil && b.err != io.EOF
+}
+
+// decodeBody decodes the JSON body of a write request into v. Bodies larger than MaxBodyBytes,
+// with unknown fields, with fields of the wrong type or with trailing data are rejected.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+
+	body := &bodyReader{r: http.MaxBytesReader(w, r.Body, MaxBodyBytes)}
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil {
+		// the body holds a single value
+		if dec.Decode(&json.RawMessage{}) != io.EOF {
+			if body.exceeded() {
+				return errBodyTooLarge
+			}
+			return errMalformedBody
+		}
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case body.exceeded():
+		return errBodyTooLarge
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return repo.InvalidField(typeErr.Field, "must be "+jsonType(typeErr.Type))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json reports unknown fields with an untyped error, its message is all there is
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return repo.InvalidField(field, "is not a known field")
+	}
+	return errMalformedBody
+}
+
+// jsonType returns the JSON type of the values decoded into the given Go type.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Ptr:
+		return jsonType(t.Elem())
+	}
+	return "a valid value"
+}
+
+// validator collects the field errors of a request, so that all of them are answered at once.
+type validator struct {
+	fields []repo.FieldError
+}
+
+// add records an invalid field.
+func (v *validator) add(field string, message string) {
+	v.fields = append(v.fields, repo.FieldError{Field: field, Message: message})
+}
+
+// check records the invalid fields of a validation error, such as the errors of checkLifecycle.
+func (v *validator) check(err error) {
+	var e *repo.Error
+	if errors.As(err, &e) {
+		v.fields = append(v.fields, e.Fields...)
+	}
+}
+
+// required records the field if it is empty or blank, and reports whether it is not.
+func (v *validator) required(field string, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+		return false
+	}
+	return true
+}
+
+// maxLength records the field if it has more than n characters.
+func (v *validator) maxLength(field string, value string, n int) {
+	if utf8.RuneCountInString(value) > n {
+		v.add(field, fmt.Sprintf("must be at most %d characters long", n))
+	}
+}
+
+// text records the field if it is empty, blank or longer than n characters.
+func (v *validator) text(field string, value string, n int) {
+	if v.required(field, value) {
+		v.maxLength(field, value, n)
+	}
+}
+
+// email records the field if it is empty or not a bare RFC 5322 address, such as test@email.com.
+func (v *validator) email(field string, value string) {
+	if !v.required(field, value) {
+		return
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Name != "" || addr.Address != value || len(value) > MaxEmailLength {
+		v.add(field, "is not a valid email address")
+	}
+}
+
+// httpURL records the field if it is set and is not an absolute http(s) url.
+func (v *validator) httpURL(field string, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(value) > MaxURLLength {
+		v.add(field, "is not an http(s) url")
+	}
+}
+
+// tags records the tags if there are too many of them or one is too long.
+func (v *validator) tags(field string, tags []string) {
+	if len(tags) > MaxTags {
+		v.add(field, fmt.Sprintf("must have at most %d tags", MaxTags))
+	}
+	for i, t := range tags {
+		v.maxLength(fmt.Sprintf("%s[%d]", field, i), repo.NormalizeTag(t), MaxTagLength)
+	}
+}
+
+// err returns the validation error listing the invalid fields, or nil if there are none.
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return repo.Invalid(v.fields...)
+}
+
+// article records the invalid fields of the content of an article.
+func (v *validator) article(a repo.Article) {
+	v.text("title", a.Title, MaxTitleLength)
+	v.text("body", a.Body, MaxArticleLength)
+	v.tags("tags", a.Tags)
+}
+
+// patch records the invalid fields of an article patch, the fields which are given cannot be empty.
+// Publication times are checked as by checkLifecycle, which fills in the defaults.
+func (v *validator) patch(p *repo.ArticlePatch, now time.Time) {
+
+	if p.Title != nil {
+		v.text("title", *p.Title, MaxTitleLength)
+	}
+	if p.Body != nil {
+		v.text("body", *p.Body, MaxArticleLength)
+	}
+	if p.Tags != nil {
+		v.tags("tags", *p.Tags)
+	}
+
+	// articles are published through the publish action, which also sets posted_at
+	switch {
+	case p.Status != nil && (*p.Status == repo.StatusPublished || *p.Status == ""):
+		v.add("status", "must be draft, scheduled or archived, use the publish action to publish")
+	case p.Status != nil:
+		v.check(checkLifecycle(p.Status, &p.PublishAt, now))
+	case p.PublishAt != nil:
+		v.add("publish_at", "can only be set along with status scheduled")
+	}
+}
+
+// author records the invalid fields of the name and email of an author.
+func (v *validator) author(prefix string, name string, email string) {
+	v.text(prefix+"name", name, MaxNameLength)
+	v.email(prefix+"email", email)
+}
+
+// profile records the invalid fields of an author profile: the urls must be absolute http(s) urls,
+// and the social links must have a network and a handle.
+func (v *validator) profile(p repo.Profile) {
+
+	v.maxLength("display_name", p.DisplayName, MaxNameLength)
+	v.maxLength("bio", p.Bio, MaxBioLength)
+	v.httpURL("avatar_url", p.AvatarURL)
+	v.httpURL("website", p.Website)
+
+	if len(p.Social) > MaxSocialLinks {
+		v.add("social", fmt.Sprintf("must have at most %d links", MaxSocialLinks))
+	}
+	for network, handle := range p.Social {
+		if strings.TrimSpace(network) == "" || strings.TrimSpace(handle) == "" {
+			v.add("social", "links need a network and a handle")
+			return
+		}
+		if utf8.RuneCountInString(network) > MaxNameLength || utf8.RuneCountInString(handle) > MaxNameLength {
+			v.add("social", fmt.Sprintf("networks and handles must be at most %d characters long", MaxNameLength))
+			return
+		}
+	}
+}
+
+// comment records the invalid fields of a comment.
+func (v *validator) comment(c repo.Comment) {
+	v.text("author_name", c.AuthorName, MaxNameLength)
+	v.email("author_email", c.AuthorEmail)
+	v.text("body", c.Body, MaxCommentLength)
+}
+
+// password records the password if it is too short, or too long for bcrypt which only hashes 72 bytes.
+func (v *validator) password(field string, value string) {
+	switch {
+	case len(value) < MinPasswordLength:
+		v.add(field, fmt.Sprintf("must be at least %d characters long", MinPasswordLength))
+	case len(value) > MaxPasswordLength:
+		v.add(field, fmt.Sprintf("must be at most %d bytes long", MaxPasswordLength))
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	repo "blog/repo"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeBody(t *testing.T) {
+
+	decode := func(body string) error {
+		var a repo.Article
+		req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader(body))
+		return decodeBody(httptest.NewRecorder(), req, &a)
+	}
+
+	t.Run("can decode body", func(t *testing.T) {
+		require.NoError(t, decode(`{"title": "test", "body": "test", "tags": ["go"]}`))
+	})
+
+	t.Run("reject unknown fields", func(t *testing.T) {
+		err := decode(`{"title": "test", "text": "test"}`)
+		require.Equal(t, toProblem(context.Background(), err).Status, http.StatusUnprocessableEntity)
+		require.Equal(t, toProblem(context.Background(), err).Errors, []repo.FieldError{{Field: "text", Message: "is not a known field"}})
+	})
+
+	t.Run("reject fields of the wrong type", func(t *testing.T) {
+		err := decode(`{"title": 42}`)
+		require.Equal(t, toProblem(context.Background(), err).Errors, []repo.FieldError{{Field: "title", Message: "must be a string"}})
+
+		err = decode(`{"tags": "go"}`)
+		require.Equal(t, toProblem(context.Background(), err).Errors, []repo.FieldError{{Field: "tags", Message: "must be an array"}})
+	})
+
+	t.Run("reject malformed bodies", func(t *testing.T) {
+		require.Equal(t, decode(`{"title": "test"`), errMalformedBody)
+		require.Equal(t, decode(`{"title": "test"} {}`), errMalformedBody)
+		require.Equal(t, decode(``), errMalformedBody)
+	})
+
+	t.Run("reject bodies too large", func(t *testing.T) {
+		err := decode(`{"body": "` + strings.Repeat("a", MaxBodyBytes) + `"}`)
+		require.Equal(t, err, errBodyTooLarge)
+		require.Equal(t, toProblem(context.Background(), err).Status, http.StatusRequestEntityTooLarge)
+
+		// the limit is reached whatever the error of the decoder
+		err = decode(`{"tags": [` + strings.Repeat(`"go", `, MaxBodyBytes/6) + `"go"]}`)
+		require.Equal(t, err, errBodyTooLarge)
+		err = decode(`{"title": "test"}` + strings.Repeat(" ", MaxBodyBytes))
+		require.Equal(t, err, errBodyTooLarge)
+	})
+
+	t.Run("accept bodies of the maximum size", func(t *testing.T) {
+		body := `{"body": "` + strings.Repeat("a", MaxBodyBytes-len(`{"body": ""}`)) + `"}`
+		require.Len(t, body, MaxBodyBytes)
+		require.NoError(t, decode(body))
+
+		body = `{"body": "` + strings.Repeat("a", MaxBodyBytes-len(`{"body": ""}`)+1) + `"}`
+		require.Equal(t, decode(body), errBodyTooLarge)
+	})
+
+	t.Run("reject malformed bodies of the maximum size", func(t *testing.T) {
+		body := `{"body": "` + strings.Repeat("a", MaxBodyBytes-len(`{"body": "`))
+		require.Len(t, body, MaxBodyBytes)
+		require.Equal(t, decode(body), errMalformedBody)
+	})
+}
+
+func TestValidator(t *testing.T) {
+
+	t.Run("email addresses", func(t *testing.T) {
+		for email, valid := range map[string]bool{
+			"test@email.com":             true,
+			"first.last+tag@example.org": true,
+			"":                           false,
+			"test":                       false,
+			"test@":                      false,
+			"Test <test@email.com>":      false,
+			"test@email.com, a@b.c":      false,
+			strings.Repeat("a", MaxEmailLength) + "@email.com": false,
+		} {
+			var v validator
+			v.email("email", email)
+			require.Equal(t, v.err() == nil, valid, email)
+		}
+	})
+
+	t.Run("lengths are counted in characters", func(t *testing.T) {
+		var v validator
+		v.text("title", strings.Repeat("é", MaxTitleLength), MaxTitleLength)
+		require.NoError(t, v.err())
+
+		v.text("title", strings.Repeat("é", MaxTitleLength+1), MaxTitleLength)
+		require.Error(t, v.err())
+	})
+
+	t.Run("blank fields are missing", func(t *testing.T) {
+		var v validator
+		v.text("title", "  ", MaxTitleLength)
+		require.Equal(t, v.fields, []repo.FieldError{{Field: "title", Message: "is required"}})
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		var v validator
+		v.tags("tags", []string{"go", strings.Repeat("a", MaxTagLength+1)})
+		require.Equal(t, v.fields, []repo.FieldError{{Field: "tags[1]", Message: "must be at most 50 characters long"}})
+
+		v = validator{}
+		v.tags("tags", make([]string, MaxTags+1))
+		require.Len(t, v.fields, 1)
+	})
+}
+
+func TestAddArticleValidation(t *testing.T) {
+
+	t.Run("return all field errors at once", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		body := `{"title": "", "body": "` + strings.Repeat("a", MaxArticleLength+1) + `", "author": {"name": "test", "email": "not an email"}}`
+		req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader(body))
+		req = asAuthor(req)
+		res := httptest.NewRecorder()
+		h.AddArticle(res, req)
+
+		require.Equal(t, res.Code, http.StatusUnprocessableEntity)
+
+		var p Problem
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &p))
+		require.Equal(t, p.Code, "validation_failed")
+		require.Equal(t, p.Errors, []repo.FieldError{
+			{Field: "title", Message: "is required"},
+			{Field: "body", Message: "must be at most 100000 characters long"},
+			{Field: "author.email", Message: "is not a valid email address"},
+		})
+	})
+
+	t.Run("return 413 if body is too large", func(t *testing.T) {
+		h := BlogServer{Service: &MockService{}}
+		body := `{"title": "test", "body": "` + strings.Repeat("a", MaxBodyBytes) + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader(body))
+		req = asAuthor(req)
+		res := httptest.NewRecorder()
+		h.AddArticle(res, req)
+
+		require.Equal(t, res.Code, http.StatusRequestEntityTooLarge)
+	})
+}
@@ -0,0 +1,379 @@
+// Package config loads the settings of the blog server from, in increasing order of precedence,
+// the defaults, a YAML file, environment variables and command-line flags.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix prefixes the environment variable of every flag, e.g. BLOG_DB_HOST for -db-host.
+const envPrefix = "BLOG_"
+
+// Config holds the settings of the blog server.
+type Config struct {
+	Store     Store     `yaml:"store"`
+	Database  Database  `yaml:"database"`
+	Server    Server    `yaml:"server"`
+	Auth      Auth      `yaml:"auth"`
+	Scheduler Scheduler `yaml:"scheduler"`
+	Comments  Comments  `yaml:"comments"`
+	Feed      Feed      `yaml:"feed"`
+}
+
+// Stores of the blog content: a postgres or sqlite database, or memory for local development.
+const (
+	StorePostgres = "postgres"
+	StoreSQLite   = "sqlite"
+	StoreMemory   = "memory"
+)
+
+// Store holds the settings of the storage of the blog content.
+type Store struct {
+	// Driver is the store, empty for the database given by the DSN, see Config.Driver.
+	Driver string `yaml:"driver"`
+	// Snapshot is the JSON file the memory store is loaded from on start and saved to on shutdown, none if empty.
+	Snapshot string `yaml:"snapshot"`
+}
+
+// Database holds the settings of the database connection. The DSN, if any, selects the database
+// by its scheme, "sqlite:path" for sqlite and postgres otherwise, and overrides the other settings.
+type Database struct {
+	DSN      string `yaml:"dsn"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	Schema   string `yaml:"schema"`
+	SSLMode  string `yaml:"sslmode"`
+	// QueryTimeout bounds the queries of every repository call, 0 means no bound.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+}
+
+// Server holds the settings of the http server.
+type Server struct {
+	Addr         string        `yaml:"addr"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout is how long the in-flight requests are given to finish on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// ReadyTimeout is how long the readiness check waits for the database.
+	ReadyTimeout time.Duration `yaml:"ready_timeout"`
+}
+
+// Auth holds the settings of the authentication. Without secret, a random one is used
+// and the issued JWTs do not survive a restart. The author of AdminEmail is made admin
+// on start, or when registering if it has no account yet, so that a new blog has an admin.
+type Auth struct {
+	Secret     string        `yaml:"secret"`
+	TokenTTL   time.Duration `yaml:"token_ttl"`
+	AdminEmail string        `yaml:"admin_email"`
+}
+
+// Scheduler holds the settings of the publication scheduler.
+type Scheduler struct {
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Comments holds the settings of the comments.
+type Comments struct {
+	MaxDepth int `yaml:"max_depth"`
+}
+
+// Feed holds the settings of the RSS and Atom feeds.
+type Feed struct {
+	Title string `yaml:"title"`
+	// URL is the public url of the blog the links of the feeds point to, by default the url of the request.
+	URL string `yaml:"url"`
+	// Size is the number of most recent articles in a feed.
+	Size int `yaml:"size"`
+}
+
+// Default returns the default configuration, for a local database and server.
+func Default() Config {
+	return Config{
+		Database: Database{
+			Host:         "localhost",
+			Port:         5432,
+			User:         "blog",
+			Name:         "blog",
+			Schema:       "blog",
+			SSLMode:      "disable",
+			QueryTimeout: 10 * time.Second,
+		},
+		Server: Server{
+			Addr:            "127.0.0.1:8000",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			ReadyTimeout:    2 * time.Second,
+		},
+		Auth: Auth{
+			TokenTTL: 24 * time.Hour,
+		},
+		Scheduler: Scheduler{
+			Interval: time.Minute,
+		},
+		Comments: Comments{
+			MaxDepth: 5,
+		},
+		Feed: Feed{
+			Title: "Blog",
+			Size:  20,
+		},
+	}
+}
+
+// Load returns the configuration of the program called with the given arguments.
+// The YAML file is given by the -config flag or the BLOG_CONFIG environment variable.
+func Load(name string, args []string, getenv func(string) string) (Config, error) {
+
+	c := Default()
+	fs := c.flagSet(name)
+	path := fs.String("config", getenv(envPrefix+"CONFIG"), "path of the YAML configuration file")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return Config{}, err
+	}
+
+	// the flags take precedence, they are set again once the other sources are loaded
+	given := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+
+	c = Default()
+	if *path != "" {
+		err = c.loadFile(*path)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		v := getenv(envName(f.Name))
+		if err != nil || f.Name == "config" || v == "" {
+			return
+		}
+		if e := f.Value.Set(v); e != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", v, envName(f.Name), e)
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	for f, v := range given {
+		err = fs.Set(f, v)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	err = c.Validate()
+	if err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
+
+// flagSet returns the command-line flags of the settings, bound to the fields of the configuration.
+func (c *Config) flagSet(name string) *flag.FlagSet {
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	fs.StringVar(&c.Store.Driver, "store", c.Store.Driver, "store of the blog content, postgres, sqlite or memory, by default the database of -db-dsn")
+	fs.StringVar(&c.Store.Snapshot, "store-snapshot", c.Store.Snapshot, "JSON snapshot file of the memory store, loaded on start and saved on shutdown")
+	fs.StringVar(&c.Database.DSN, "db-dsn", c.Database.DSN, "database connection string, postgres://... or sqlite:path, overrides the other database flags")
+	fs.StringVar(&c.Database.Host, "db-host", c.Database.Host, "database host")
+	fs.IntVar(&c.Database.Port, "db-port", c.Database.Port, "database port")
+	fs.StringVar(&c.Database.User, "db-user", c.Database.User, "database user")
+	fs.StringVar(&c.Database.Password, "db-password", c.Database.Password, "database password")
+	fs.StringVar(&c.Database.Name, "db-name", c.Database.Name, "database name")
+	fs.StringVar(&c.Database.Schema, "db-schema", c.Database.Schema, "database schema")
+	fs.StringVar(&c.Database.SSLMode, "db-sslmode", c.Database.SSLMode, "database ssl mode")
+	fs.DurationVar(&c.Database.QueryTimeout, "db-query-timeout", c.Database.QueryTimeout, "timeout of the queries of a request, 0 for none")
+	fs.StringVar(&c.Server.Addr, "addr", c.Server.Addr, "address the server listens on, as host:port")
+	fs.DurationVar(&c.Server.ReadTimeout, "read-timeout", c.Server.ReadTimeout, "timeout for reading requests")
+	fs.DurationVar(&c.Server.WriteTimeout, "write-timeout", c.Server.WriteTimeout, "timeout for writing responses")
+	fs.DurationVar(&c.Server.IdleTimeout, "idle-timeout", c.Server.IdleTimeout, "timeout of idle keep-alive connections")
+	fs.DurationVar(&c.Server.ShutdownTimeout, "shutdown-timeout", c.Server.ShutdownTimeout, "time given to the in-flight requests to finish on shutdown")
+	fs.DurationVar(&c.Server.ReadyTimeout, "ready-timeout", c.Server.ReadyTimeout, "timeout of the database ping of the readiness check")
+	fs.StringVar(&c.Auth.Secret, "auth-secret", c.Auth.Secret, "secret used to sign JWTs")
+	fs.DurationVar(&c.Auth.TokenTTL, "auth-token-ttl", c.Auth.TokenTTL, "validity of the JWTs issued on login")
+	fs.StringVar(&c.Auth.AdminEmail, "auth-admin-email", c.Auth.AdminEmail, "email of the author granted the admin role, on start or when registering")
+	fs.DurationVar(&c.Scheduler.Interval, "scheduler-interval", c.Scheduler.Interval, "interval between publications of the scheduled articles")
+	fs.IntVar(&c.Comments.MaxDepth, "max-comment-depth", c.Comments.MaxDepth, "maximum depth of comment replies")
+	fs.StringVar(&c.Feed.Title, "feed-title", c.Feed.Title, "title of the feeds")
+	fs.StringVar(&c.Feed.URL, "feed-url", c.Feed.URL, "public url of the blog linked from the feeds, by default the url of the request")
+	fs.IntVar(&c.Feed.Size, "feed-size", c.Feed.Size, "number of most recent articles in the feeds")
+
+	return fs
+}
+
+// envName returns the environment variable of a flag.
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// loadFile loads the settings found in a YAML file, unknown settings are rejected.
+func (c *Config) loadFile(path string) error {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err = dec.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate returns an error describing the first invalid setting, if any.
+func (c Config) Validate() error {
+
+	switch c.Store.Driver {
+	case "", StoreMemory:
+	case StorePostgres, StoreSQLite:
+		if c.Database.DSN != "" && c.Database.Driver() != c.Store.Driver {
+			return fmt.Errorf("database dsn is not a %s dsn", c.Store.Driver)
+		}
+	default:
+		return errors.New("store must be postgres, sqlite or memory")
+	}
+
+	switch c.Driver() {
+	case StorePostgres:
+		if err := c.Database.Validate(); err != nil {
+			return err
+		}
+	case StoreSQLite:
+		if c.Database.SQLitePath() == "" {
+			return errors.New("sqlite store requires a database dsn of the form sqlite:path")
+		}
+		if c.Database.QueryTimeout < 0 {
+			return errors.New("database query timeout cannot be negative")
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		return fmt.Errorf("server address must be host:port: %w", err)
+	}
+
+	switch {
+	case c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0:
+		return errors.New("server timeouts must be positive")
+	case c.Server.ShutdownTimeout <= 0 || c.Server.ReadyTimeout <= 0:
+		return errors.New("server shutdown and ready timeouts must be positive")
+	case c.Auth.TokenTTL <= 0:
+		return errors.New("auth token ttl must be positive")
+	case c.Scheduler.Interval <= 0:
+		return errors.New("scheduler interval must be positive")
+	case c.Comments.MaxDepth <= 0:
+		return errors.New("max comment depth must be positive")
+	case c.Feed.Size <= 0:
+		return errors.New("feed size must be positive")
+	}
+
+	if c.Feed.URL != "" {
+		u, err := url.Parse(c.Feed.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("feed url must be an absolute http(s) url")
+		}
+	}
+
+	return nil
+}
+
+// Driver returns the store of the blog content, given by the store setting or else by the database DSN.
+func (c Config) Driver() string {
+	if c.Store.Driver != "" {
+		return c.Store.Driver
+	}
+	return c.Database.Driver()
+}
+
+// Driver returns the database of the DSN: sqlite for "sqlite:" DSNs, postgres otherwise.
+func (d Database) Driver() string {
+	if strings.HasPrefix(d.DSN, "sqlite:") {
+		return StoreSQLite
+	}
+	return StorePostgres
+}
+
+// SQLitePath returns the database file of a "sqlite:path" or "sqlite://path" DSN.
+func (d Database) SQLitePath() string {
+	if !strings.HasPrefix(d.DSN, "sqlite:") {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(d.DSN, "sqlite:"), "//")
+}
+
+// Validate returns an error describing the first invalid postgres setting, if any.
+// The settings replaced by the DSN are not checked.
+func (d Database) Validate() error {
+
+	if d.QueryTimeout < 0 {
+		return errors.New("database query timeout cannot be negative")
+	}
+	if d.DSN != "" {
+		return nil
+	}
+
+	switch {
+	case d.Host == "":
+		return errors.New("database host is required")
+	case d.Port <= 0 || d.Port > 65535:
+		return errors.New("database port must be between 1 and 65535")
+	case d.User == "":
+		return errors.New("database user is required")
+	case d.Name == "":
+		return errors.New("database name is required")
+	case d.Schema == "":
+		return errors.New("database schema is required")
+	}
+
+	switch d.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		return errors.New("database sslmode must be one of disable, allow, prefer, require, verify-ca or verify-full")
+	}
+
+	return nil
+}
+
+// DataSourceName returns the postgres connection string of the database, the DSN if any.
+func (d Database) DataSourceName() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s search_path=%s",
+		quote(d.Host), d.Port, quote(d.User), quote(d.Password), quote(d.Name), quote(d.SSLMode), quote(d.Schema))
+}
+
+// quote quotes a value of a connection string, so that it can be empty or contain spaces.
+func quote(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// env returns a getenv function reading from the given variables.
+func env(vars map[string]string) func(string) string {
+	return func(key string) string {
+		return vars[key]
+	}
+}
+
+// writeFile writes a config file in a temporary directory and returns its path.
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+
+	file := `
+database:
+  host: db.staging
+  port: 6432
+  password: from file
+server:
+  addr: 0.0.0.0:8080
+  read_timeout: 5s
+comments:
+  max_depth: 3
+`
+
+	t.Run("defaults", func(t *testing.T) {
+		c, err := Load("blog", nil, env(nil))
+		require.NoError(t, err)
+		require.Equal(t, c, Default())
+	})
+
+	t.Run("file overrides defaults", func(t *testing.T) {
+		c, err := Load("blog", []string{"-config", writeFile(t, file)}, env(nil))
+		require.NoError(t, err)
+		require.Equal(t, c.Database.Host, "db.staging")
+		require.Equal(t, c.Database.Port, 6432)
+		require.Equal(t, c.Database.User, "blog")
+		require.Equal(t, c.Server.Addr, "0.0.0.0:8080")
+		require.Equal(t, c.Server.ReadTimeout, 5*time.Second)
+		require.Equal(t, c.Server.WriteTimeout, 15*time.Second)
+		require.Equal(t, c.Comments.MaxDepth, 3)
+	})
+
+	t.Run("environment overrides file", func(t *testing.T) {
+		c, err := Load("blog", nil, env(map[string]string{
+			"BLOG_CONFIG":           writeFile(t, file),
+			"BLOG_DB_HOST":          "db.production",
+			"BLOG_READ_TIMEOUT":     "10s",
+			"BLOG_AUTH_SECRET":      "secret",
+			"BLOG_AUTH_TOKEN_TTL":   "1h",
+			"BLOG_AUTH_ADMIN_EMAIL": "admin@email.com",
+		}))
+		require.NoError(t, err)
+		require.Equal(t, c.Database.Host, "db.production")
+		require.Equal(t, c.Database.Port, 6432)
+		require.Equal(t, c.Server.ReadTimeout, 10*time.Second)
+		require.Equal(t, c.Auth.Secret, "secret")
+		require.Equal(t, c.Auth.TokenTTL, time.Hour)
+		require.Equal(t, c.Auth.AdminEmail, "admin@email.com")
+	})
+
+	t.Run("flags override environment", func(t *testing.T) {
+		args := []string{"-config", writeFile(t, file), "-db-host", "localhost", "-max-comment-depth", "1"}
+		c, err := Load("blog", args, env(map[string]string{
+			"BLOG_DB_HOST":           "db.production",
+			"BLOG_MAX_COMMENT_DEPTH": "2",
+		}))
+		require.NoError(t, err)
+		require.Equal(t, c.Database.Host, "localhost")
+		require.Equal(t, c.Database.Port, 6432)
+		require.Equal(t, c.Comments.MaxDepth, 1)
+	})
+
+	t.Run("return error for invalid sources", func(t *testing.T) {
+		_, err := Load("blog", []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, env(nil))
+		require.Error(t, err)
+
+		_, err = Load("blog", []string{"-config", writeFile(t, "database:\n  hostname: db\n")}, env(nil))
+		require.Error(t, err)
+
+		_, err = Load("blog", nil, env(map[string]string{"BLOG_DB_PORT": "port"}))
+		require.Error(t, err)
+
+		_, err = Load("blog", []string{"-unknown"}, env(nil))
+		require.Error(t, err)
+	})
+
+	t.Run("return error for invalid settings", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"-db-host", ""},
+			{"-db-port", "70000"},
+			{"-db-sslmode", "sometimes"},
+			{"-db-query-timeout", "-1s"},
+			{"-addr", "8000"},
+			{"-write-timeout", "0s"},
+			{"-shutdown-timeout", "0s"},
+			{"-auth-token-ttl", "-1h"},
+			{"-scheduler-interval", "0s"},
+			{"-max-comment-depth", "0"},
+			{"-feed-size", "0"},
+			{"-feed-url", "blog.example.com"},
+			{"-store", "files"},
+			{"-store", "sqlite"},
+			{"-store", "postgres", "-db-dsn", "sqlite:blog.db"},
+		} {
+			_, err := Load("blog", args, env(nil))
+			require.Error(t, err, args)
+		}
+	})
+
+	t.Run("memory store ignores the database settings", func(t *testing.T) {
+		c, err := Load("blog", []string{"-store", "memory", "-store-snapshot", "blog.json", "-db-host", ""}, env(nil))
+		require.NoError(t, err)
+		require.Equal(t, c.Store, Store{Driver: StoreMemory, Snapshot: "blog.json"})
+	})
+}
+
+func TestDriver(t *testing.T) {
+
+	for _, test := range []struct {
+		args   []string
+		driver string
+	}{
+		{nil, StorePostgres},
+		{[]string{"-db-dsn", "postgres://blog@localhost/blog"}, StorePostgres},
+		{[]string{"-db-dsn", "sqlite:blog.db"}, StoreSQLite},
+		{[]string{"-store", "sqlite", "-db-dsn", "sqlite://var/blog.db"}, StoreSQLite},
+		{[]string{"-store", "memory", "-db-dsn", "sqlite:blog.db"}, StoreMemory},
+	} {
+		c, err := Load("blog", test.args, env(nil))
+		require.NoError(t, err, test.args)
+		require.Equal(t, c.Driver(), test.driver, test.args)
+	}
+
+	d := Database{DSN: "sqlite://var/blog.db"}
+	require.Equal(t, d.SQLitePath(), "var/blog.db")
+}
+
+func TestDataSourceName(t *testing.T) {
+	d := Default().Database
+	d.Password = `it's a \secret`
+	require.Equal(t, d.DataSourceName(),
+		`host='localhost' port=5432 user='blog' password='it\'s a \\secret' dbname='blog' sslmode='disable' search_path='blog'`)
+
+	d.DSN = "postgres://blog@localhost/blog"
+	require.Equal(t, d.DataSourceName(), d.DSN)
+}
@@ -12,18 +12,27 @@ require (
 require (
 	github.com/DATA-DOG/go-sqlmock v1.5.0
 	github.com/davecgh/go-spew v1.1.1
+	github.com/golang-jwt/jwt/v4 v4.4.1
+	github.com/mattn/go-sqlite3 v1.14.17
+	github.com/microcosm-cc/bluemonday v1.0.21
 	github.com/pmezard/go-difflib v1.0.0
 	github.com/stretchr/testify v1.7.0
+	github.com/yuin/goldmark v1.4.13
+	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
+	golang.org/x/text v0.3.7
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
 
 require (
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/gorilla/css v1.0.0 // indirect
 	github.com/joho/godotenv v1.4.0 // indirect
+	golang.org/x/net v0.0.0-20221002022538-bcab6841153b // indirect
 	gopkg.in/gorp.v1 v1.7.2 // indirect
 )
 
 require (
 	github.com/rubenv/sql-migrate v1.0.0
-	golang.org/x/sys v0.0.0-20211019181941-9d821ace8654 // indirect
+	golang.org/x/sys v0.0.0-20220728004956-3c1f35247d10 // indirect
 	golang.org/x/tools v0.1.8 // indirect
 )
@@ -0,0 +1,111 @@
+// Package logging writes structured logs as JSON lines, with a logger per request carrying its id.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Logger writes log entries as JSON lines, with the fields it was created with such as the id
+// of the request being served. Loggers derived from one another share its output.
+type Logger struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	fields []interface{}
+	// now returns the time of the entries, replaced in tests.
+	now func() time.Time
+}
+
+// New returns a logger writing to the given output.
+func New(out io.Writer) *Logger {
+	return &Logger{mu: &sync.Mutex{}, out: out, now: time.Now}
+}
+
+// std is the logger of the contexts without logger.
+var std = New(os.Stderr)
+
+// With returns a logger adding the given key value pairs to the fields of its entries.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(fields, l.fields...)
+	fields = append(fields, kv...)
+	return &Logger{mu: l.mu, out: l.out, fields: fields, now: l.now}
+}
+
+// Info writes an entry of level info with the message and the key value pairs.
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log("info", msg, kv)
+}
+
+// Error writes an entry of level error with the message and the key value pairs.
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log("error", msg, kv)
+}
+
+// log writes an entry, its keys in order: time, level, msg, the fields of the logger and the pairs.
+// Errors are written as their message, the values which cannot be marshalled as their string.
+func (l *Logger) log(level string, msg string, kv []interface{}) {
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	writeField(&b, "time", l.now().UTC().Format(time.RFC3339Nano))
+	b.WriteByte(',')
+	writeField(&b, "level", level)
+	b.WriteByte(',')
+	writeField(&b, "msg", msg)
+
+	pairs := append(append([]interface{}{}, l.fields...), kv...)
+	for i := 0; i < len(pairs); i += 2 {
+		key := fmt.Sprint(pairs[i])
+		var value interface{} = "(missing)"
+		if i+1 < len(pairs) {
+			value = pairs[i+1]
+		}
+		b.WriteByte(',')
+		writeField(&b, key, value)
+	}
+	b.WriteString("}\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(b.Bytes())
+}
+
+// writeField writes a key and its value as a member of a JSON object.
+func writeField(b *bytes.Buffer, key string, value interface{}) {
+
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+
+	b.Write(k)
+	b.WriteByte(':')
+	b.Write(v)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the given logger.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger of the context, or a logger writing to stderr if it has none.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return std
+}
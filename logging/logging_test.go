@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+
+	now := time.Date(2022, 3, 1, 10, 30, 15, 0, time.UTC)
+
+	newLogger := func() (*Logger, *bytes.Buffer) {
+		var out bytes.Buffer
+		l := New(&out)
+		l.now = func() time.Time { return now }
+		return l, &out
+	}
+
+	t.Run("writes entries as json lines", func(t *testing.T) {
+		l, out := newLogger()
+		l.Info("request", "status", 200, "route", "/articles/{id}")
+
+		require.Equal(t, out.String(), `{"time":"2022-03-01T10:30:15Z","level":"info","msg":"request","status":200,"route":"/articles/{id}"}`+"\n")
+	})
+
+	t.Run("entries have the fields of the logger", func(t *testing.T) {
+		l, out := newLogger()
+		l = l.With("request_id", "abc")
+		l.Error("repository error", "error", errors.New("cannot execute query"))
+		l.Info("request")
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 2)
+
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+		require.Equal(t, entry["level"], "error")
+		require.Equal(t, entry["request_id"], "abc")
+		require.Equal(t, entry["error"], "cannot execute query")
+
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+		require.Equal(t, entry["request_id"], "abc")
+	})
+
+	t.Run("odd pairs and unmarshallable values are still written", func(t *testing.T) {
+		l, out := newLogger()
+		l.Info("request", "f", func() {}, "status")
+
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+		require.Equal(t, entry["status"], "(missing)")
+		require.NotEmpty(t, entry["f"])
+	})
+
+	t.Run("context carries the logger", func(t *testing.T) {
+		l, _ := newLogger()
+		require.Equal(t, FromContext(NewContext(context.Background(), l)), l)
+		require.Equal(t, FromContext(context.Background()), std)
+	})
+}
@@ -1,4 +1,5 @@
- CREATE TABLE authors (
+-- +migrate Up
+CREATE TABLE authors (
 	id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
 	name TEXT NOT NULL,
 	email TEXT NOT NULL
@@ -14,3 +15,7 @@ CREATE TABLE articles (
 		REFERENCES authors(id)
 		ON DELETE CASCADE
 );
+
+-- +migrate Down
+DROP TABLE articles;
+DROP TABLE authors;
@@ -0,0 +1,6 @@
+-- +migrate Up
+ALTER TABLE articles ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+UPDATE articles SET updated_at = posted_at;
+
+-- +migrate Down
+ALTER TABLE articles DROP COLUMN updated_at;
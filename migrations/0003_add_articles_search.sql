@@ -0,0 +1,10 @@
+-- +migrate Up
+ALTER TABLE articles ADD COLUMN search tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', body), 'B')
+) STORED;
+
+CREATE INDEX articles_search_idx ON articles USING GIN (search);
+
+-- +migrate Down
+DROP INDEX articles_search_idx;
+ALTER TABLE articles DROP COLUMN search;
@@ -0,0 +1,21 @@
+-- +migrate Up
+CREATE TABLE tags (
+	id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE article_tags (
+	article_id uuid NOT NULL,
+	tag_id uuid NOT NULL,
+	PRIMARY KEY (article_id, tag_id),
+	FOREIGN KEY (article_id)
+		REFERENCES articles(id)
+		ON DELETE CASCADE,
+	FOREIGN KEY (tag_id)
+		REFERENCES tags(id)
+		ON DELETE CASCADE
+);
+
+-- +migrate Down
+DROP TABLE article_tags;
+DROP TABLE tags;
@@ -0,0 +1,22 @@
+-- +migrate Up
+CREATE TABLE comments (
+	id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+	article_id uuid NOT NULL,
+	parent_id uuid,
+	author_name TEXT NOT NULL,
+	author_email TEXT NOT NULL,
+	body TEXT NOT NULL,
+	depth INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	FOREIGN KEY (article_id)
+		REFERENCES articles(id)
+		ON DELETE CASCADE,
+	FOREIGN KEY (parent_id)
+		REFERENCES comments(id)
+		ON DELETE CASCADE
+);
+
+CREATE INDEX comments_article_idx ON comments (article_id);
+
+-- +migrate Down
+DROP TABLE comments;
@@ -0,0 +1,10 @@
+-- +migrate Up
+ALTER TABLE articles
+	ADD COLUMN status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'scheduled', 'published', 'archived')),
+	ADD COLUMN publish_at TIMESTAMP;
+
+CREATE INDEX articles_scheduled_idx ON articles (publish_at) WHERE status = 'scheduled';
+
+-- +migrate Down
+DROP INDEX articles_scheduled_idx;
+ALTER TABLE articles DROP COLUMN publish_at, DROP COLUMN status;
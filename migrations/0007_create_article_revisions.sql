@@ -0,0 +1,19 @@
+-- +migrate Up
+CREATE TABLE article_revisions (
+	article_id uuid NOT NULL,
+	revision INT NOT NULL,
+	title TEXT NOT NULL,
+	body TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (article_id, revision),
+	FOREIGN KEY (article_id)
+		REFERENCES articles(id)
+		ON DELETE CASCADE
+);
+
+-- every article has at least the revision of its current title and body
+INSERT INTO article_revisions(article_id, revision, title, body, created_at)
+	SELECT id, 1, title, body, updated_at FROM articles;
+
+-- +migrate Down
+DROP TABLE article_revisions;
@@ -0,0 +1,18 @@
+-- +migrate Up
+ALTER TABLE authors ADD COLUMN password_hash TEXT;
+
+CREATE TABLE api_tokens (
+	id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+	author_id uuid NOT NULL,
+	name TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	revoked_at TIMESTAMP,
+	FOREIGN KEY (author_id)
+		REFERENCES authors(id)
+		ON DELETE CASCADE
+);
+
+-- +migrate Down
+DROP TABLE api_tokens;
+ALTER TABLE authors DROP COLUMN password_hash;
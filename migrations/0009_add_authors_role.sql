@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE authors ADD COLUMN role TEXT NOT NULL DEFAULT 'author' CHECK (role IN ('admin', 'editor', 'author', 'reader'));
+
+-- +migrate Down
+ALTER TABLE authors DROP COLUMN role;
@@ -0,0 +1,15 @@
+-- +migrate Up
+-- authors sharing an email are merged into the one having credentials, or else the first one
+CREATE TEMPORARY TABLE author_duplicates ON COMMIT DROP AS
+	SELECT id, keep_id FROM (
+		SELECT id, FIRST_VALUE(id) OVER (PARTITION BY email ORDER BY password_hash IS NULL, id) AS keep_id FROM authors
+	) a WHERE id <> keep_id;
+
+UPDATE articles SET author_id = d.keep_id FROM author_duplicates d WHERE articles.author_id = d.id;
+UPDATE api_tokens SET author_id = d.keep_id FROM author_duplicates d WHERE api_tokens.author_id = d.id;
+DELETE FROM authors WHERE id IN (SELECT id FROM author_duplicates);
+
+ALTER TABLE authors ADD CONSTRAINT authors_email_key UNIQUE (email);
+
+-- +migrate Down
+ALTER TABLE authors DROP CONSTRAINT authors_email_key;
@@ -0,0 +1,15 @@
+-- +migrate Up
+ALTER TABLE authors
+	ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+	ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+	ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '',
+	ADD COLUMN website TEXT NOT NULL DEFAULT '',
+	ADD COLUMN social JSONB NOT NULL DEFAULT '{}';
+
+-- +migrate Down
+ALTER TABLE authors
+	DROP COLUMN social,
+	DROP COLUMN website,
+	DROP COLUMN avatar_url,
+	DROP COLUMN bio,
+	DROP COLUMN display_name;
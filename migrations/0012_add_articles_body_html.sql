@@ -0,0 +1,6 @@
+-- +migrate Up
+-- bodies written before are rendered when read, until the next write
+ALTER TABLE articles ADD COLUMN body_html TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE articles DROP COLUMN body_html;
@@ -0,0 +1,27 @@
+-- +migrate Up
+-- the existing articles are given their id as slug, and the slugs of their titles
+-- by postgres.BackfillSlugs once the migrations are applied, as the new articles are
+ALTER TABLE articles ADD COLUMN slug TEXT;
+
+UPDATE articles SET slug = id::text;
+
+ALTER TABLE articles ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE articles ADD CONSTRAINT articles_slug_key UNIQUE (slug);
+
+-- every slug an article had, including the current one, so that old links still resolve
+CREATE TABLE article_slugs (
+	slug TEXT PRIMARY KEY,
+	article_id uuid NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	FOREIGN KEY (article_id)
+		REFERENCES articles(id)
+		ON DELETE CASCADE
+);
+
+CREATE INDEX article_slugs_article_idx ON article_slugs (article_id);
+
+INSERT INTO article_slugs(slug, article_id) SELECT slug, id FROM articles;
+
+-- +migrate Down
+DROP TABLE article_slugs;
+ALTER TABLE articles DROP COLUMN slug;
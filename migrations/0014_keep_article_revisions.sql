@@ -0,0 +1,10 @@
+-- +migrate Up
+-- the revisions of an article are kept when it is deleted, as the history of what it was
+ALTER TABLE article_revisions DROP CONSTRAINT article_revisions_article_id_fkey;
+
+-- +migrate Down
+DELETE FROM article_revisions r WHERE NOT EXISTS (SELECT 1 FROM articles a WHERE a.id = r.article_id);
+ALTER TABLE article_revisions ADD CONSTRAINT article_revisions_article_id_fkey
+	FOREIGN KEY (article_id)
+	REFERENCES articles(id)
+	ON DELETE CASCADE;
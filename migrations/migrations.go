@@ -0,0 +1,126 @@
+// Package migrations holds the numbered migrations of the blog database schema, embedded in the binary.
+// Every file has an up and a down section, in the format of github.com/rubenv/sql-migrate.
+// The postgres migrations are at the root of the package, the sqlite ones in the sqlite folder.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// Set is the migrations of the schema for a database dialect.
+type Set struct {
+	dialect string
+	source  migrate.MigrationSource
+}
+
+// Migrations of the supported databases.
+var (
+	Postgres = Set{dialect: "postgres", source: migrate.EmbedFileSystemMigrationSource{FileSystem: files, Root: "."}}
+	SQLite   = Set{dialect: "sqlite3", source: migrate.EmbedFileSystemMigrationSource{FileSystem: sqliteFiles, Root: "sqlite"}}
+)
+
+var ErrNoMigration = errors.New("no migration to redo")
+
+// Status is the state of a migration, AppliedAt is nil if it has not been applied.
+type Status struct {
+	Id        string
+	AppliedAt *time.Time
+}
+
+// Source returns the embedded migrations.
+func (s Set) Source() migrate.MigrationSource {
+	return s.source
+}
+
+// CreateSchema creates the given postgres schema if it does not exist, the migrations are applied
+// in the first schema of the search path of the connection.
+func CreateSchema(db *sql.DB, schema string) error {
+	_, err := db.Exec(`CREATE SCHEMA IF NOT EXISTS ` + pq.QuoteIdentifier(schema))
+	if err != nil {
+		return fmt.Errorf("cannot create schema: %w", err)
+	}
+	return nil
+}
+
+// Up applies all the pending migrations and returns how many were applied.
+func (s Set) Up(db *sql.DB) (int, error) {
+	n, err := migrate.Exec(db, s.dialect, s.source, migrate.Up)
+	if err != nil {
+		return n, fmt.Errorf("cannot apply migrations: %w", err)
+	}
+	return n, nil
+}
+
+// Down rolls back the given number of migrations, starting from the last applied one,
+// and returns how many were rolled back.
+func (s Set) Down(db *sql.DB, max int) (int, error) {
+	n, err := migrate.ExecMax(db, s.dialect, s.source, migrate.Down, max)
+	if err != nil {
+		return n, fmt.Errorf("cannot roll back migrations: %w", err)
+	}
+	return n, nil
+}
+
+// Redo rolls back the last applied migration and applies it again.
+func (s Set) Redo(db *sql.DB) error {
+
+	planned, _, err := migrate.PlanMigration(db, s.dialect, s.source, migrate.Down, 1)
+	if err != nil {
+		return fmt.Errorf("cannot plan migration: %w", err)
+	}
+	if len(planned) == 0 {
+		return ErrNoMigration
+	}
+
+	if _, err = s.Down(db, 1); err != nil {
+		return err
+	}
+	_, err = migrate.ExecMax(db, s.dialect, s.source, migrate.Up, 1)
+	if err != nil {
+		return fmt.Errorf("cannot apply migration: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the status of every migration, in order.
+func (s Set) List(db *sql.DB) ([]Status, error) {
+
+	migrations, err := s.source.FindMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("cannot find migrations: %w", err)
+	}
+
+	records, err := migrate.GetMigrationRecords(db, s.dialect)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get applied migrations: %w", err)
+	}
+	applied := make(map[string]time.Time)
+	for _, r := range records {
+		applied[r.Id] = r.AppliedAt
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		s := Status{Id: m.Id}
+		if t, ok := applied[m.Id]; ok {
+			s.AppliedAt = &t
+		}
+		statuses = append(statuses, s)
+	}
+
+	return statuses, nil
+}
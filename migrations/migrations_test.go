@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource(t *testing.T) {
+
+	for _, set := range []Set{Postgres, SQLite} {
+		migrations, err := set.Source().FindMigrations()
+		require.NoError(t, err)
+		require.NotEmpty(t, migrations)
+
+		for i, m := range migrations {
+			require.Equal(t, m.VersionInt(), int64(i+1), m.Id)
+			require.NotEmpty(t, m.Up, m.Id)
+			require.NotEmpty(t, m.Down, m.Id)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+-- +migrate Up
+-- sqlite version of the postgres schema: ids are uuids generated by the repository
+-- and timestamps are UTC texts, with the precision of the times given by the repository.
+CREATE TABLE authors (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL,
+	password_hash TEXT,
+	role TEXT NOT NULL DEFAULT 'author' CHECK (role IN ('admin', 'editor', 'author', 'reader'))
+);
+
+CREATE TABLE articles (
+	id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	body TEXT NOT NULL,
+	posted_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+	updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+	status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'scheduled', 'published', 'archived')),
+	publish_at TIMESTAMP,
+	author_id TEXT NOT NULL,
+	FOREIGN KEY (author_id)
+		REFERENCES authors(id)
+		ON DELETE CASCADE
+);
+
+CREATE INDEX articles_author_idx ON articles (author_id);
+CREATE INDEX articles_scheduled_idx ON articles (publish_at) WHERE status = 'scheduled';
+
+CREATE TABLE tags (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE article_tags (
+	article_id TEXT NOT NULL,
+	tag_id INTEGER NOT NULL,
+	PRIMARY KEY (article_id, tag_id),
+	FOREIGN KEY (article_id)
+		REFERENCES articles(id)
+		ON DELETE CASCADE,
+	FOREIGN KEY (tag_id)
+		REFERENCES tags(id)
+		ON DELETE CASCADE
+);
+
+CREATE TABLE article_revisions (
+	article_id TEXT NOT NULL,
+	revision INT NOT NULL,
+	title TEXT NOT NULL,
+	body TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+	PRIMARY KEY (article_id, revision),
+	FOREIGN KEY (article_id)
+		REFERENCES articles(id)
+		ON DELETE CASCADE
+);
+
+CREATE TABLE comments (
+	id TEXT PRIMARY KEY,
+	article_id TEXT NOT NULL,
+	parent_id TEXT,
+	author_name TEXT NOT NULL,
+	author_email TEXT NOT NULL,
+	body TEXT NOT NULL,
+	depth INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+	FOREIGN KEY (article_id)
+		REFERENCES articles(id)
+		ON DELETE CASCADE,
+	FOREIGN KEY (parent_id)
+		REFERENCES comments(id)
+		ON DELETE CASCADE
+);
+
+CREATE INDEX comments_article_idx ON comments (article_id);
+
+CREATE TABLE api_tokens (
+	id TEXT PRIMARY KEY,
+	author_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+	revoked_at TIMESTAMP,
+	FOREIGN KEY (author_id)
+		REFERENCES authors(id)
+		ON DELETE CASCADE
+);
+
+-- +migrate Down
+DROP TABLE api_tokens;
+DROP TABLE comments;
+DROP TABLE article_revisions;
+DROP TABLE article_tags;
+DROP TABLE tags;
+DROP TABLE articles;
+DROP TABLE authors;
@@ -0,0 +1,18 @@
+-- +migrate Up
+-- authors sharing an email are merged into the one having credentials, or else the first one
+CREATE TEMPORARY TABLE author_duplicates AS
+	SELECT id, keep_id FROM (
+		SELECT id, FIRST_VALUE(id) OVER (PARTITION BY email ORDER BY password_hash IS NULL, id) AS keep_id FROM authors
+	) WHERE id <> keep_id;
+
+UPDATE articles SET author_id = (SELECT keep_id FROM author_duplicates d WHERE d.id = articles.author_id)
+	WHERE author_id IN (SELECT id FROM author_duplicates);
+UPDATE api_tokens SET author_id = (SELECT keep_id FROM author_duplicates d WHERE d.id = api_tokens.author_id)
+	WHERE author_id IN (SELECT id FROM author_duplicates);
+DELETE FROM authors WHERE id IN (SELECT id FROM author_duplicates);
+DROP TABLE author_duplicates;
+
+CREATE UNIQUE INDEX authors_email_key ON authors (email);
+
+-- +migrate Down
+DROP INDEX authors_email_key;
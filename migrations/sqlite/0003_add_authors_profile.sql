@@ -0,0 +1,13 @@
+-- +migrate Up
+ALTER TABLE authors ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE authors ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE authors ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE authors ADD COLUMN website TEXT NOT NULL DEFAULT '';
+ALTER TABLE authors ADD COLUMN social TEXT NOT NULL DEFAULT '{}' CHECK (json_valid(social));
+
+-- +migrate Down
+ALTER TABLE authors DROP COLUMN social;
+ALTER TABLE authors DROP COLUMN website;
+ALTER TABLE authors DROP COLUMN avatar_url;
+ALTER TABLE authors DROP COLUMN bio;
+ALTER TABLE authors DROP COLUMN display_name;
@@ -0,0 +1,25 @@
+-- +migrate Up
+-- sqlite cannot slugify the existing titles, their articles are given their id as slug
+-- and the slugs of their titles by sqlite.BackfillSlugs once the migrations are applied
+ALTER TABLE articles ADD COLUMN slug TEXT NOT NULL DEFAULT '';
+UPDATE articles SET slug = id;
+CREATE UNIQUE INDEX articles_slug_idx ON articles (slug);
+
+-- every slug an article had, including the current one, so that old links still resolve
+CREATE TABLE article_slugs (
+	slug TEXT PRIMARY KEY,
+	article_id TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+	FOREIGN KEY (article_id)
+		REFERENCES articles(id)
+		ON DELETE CASCADE
+);
+
+CREATE INDEX article_slugs_article_idx ON article_slugs (article_id);
+
+INSERT INTO article_slugs(slug, article_id) SELECT slug, id FROM articles;
+
+-- +migrate Down
+DROP TABLE article_slugs;
+DROP INDEX articles_slug_idx;
+ALTER TABLE articles DROP COLUMN slug;
@@ -0,0 +1,35 @@
+-- +migrate Up
+-- the revisions of an article are kept when it is deleted, as the history of what it was,
+-- sqlite cannot drop a foreign key so the table is rebuilt without it
+CREATE TABLE article_revisions_kept (
+	article_id TEXT NOT NULL,
+	revision INT NOT NULL,
+	title TEXT NOT NULL,
+	body TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+	PRIMARY KEY (article_id, revision)
+);
+
+INSERT INTO article_revisions_kept(article_id, revision, title, body, created_at)
+	SELECT article_id, revision, title, body, created_at FROM article_revisions;
+DROP TABLE article_revisions;
+ALTER TABLE article_revisions_kept RENAME TO article_revisions;
+
+-- +migrate Down
+CREATE TABLE article_revisions_cascade (
+	article_id TEXT NOT NULL,
+	revision INT NOT NULL,
+	title TEXT NOT NULL,
+	body TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+	PRIMARY KEY (article_id, revision),
+	FOREIGN KEY (article_id)
+		REFERENCES articles(id)
+		ON DELETE CASCADE
+);
+
+INSERT INTO article_revisions_cascade(article_id, revision, title, body, created_at)
+	SELECT article_id, revision, title, body, created_at FROM article_revisions
+	WHERE article_id IN (SELECT id FROM articles);
+DROP TABLE article_revisions;
+ALTER TABLE article_revisions_cascade RENAME TO article_revisions;
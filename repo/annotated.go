@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// OperationError is an unexpected error of a BlogService, Operation is the name of the method which failed.
+type OperationError struct {
+	Operation string
+	Err       error
+}
+
+func (e *OperationError) Error() string { return e.Err.Error() }
+
+func (e *OperationError) Unwrap() error { return e.Err }
+
+// OperationOf returns the name of the BlogService method an error comes from, or the empty string if unknown.
+func OperationOf(err error) string {
+	var e *OperationError
+	if errors.As(err, &e) {
+		return e.Operation
+	}
+	return ""
+}
+
+// annotatedService is a BlogService giving the internal and unavailable errors of another one
+// the name of the method which failed, so that they are logged with it where they are answered.
+type annotatedService struct {
+	s BlogService
+}
+
+// Annotated returns a BlogService annotating the unexpected errors of the given one, the errors
+// for entities which do not exist, conflicting or invalid are returned as they are.
+func Annotated(s BlogService) BlogService {
+	return annotatedService{s: s}
+}
+
+// fail annotates an unexpected error of the named method with its name and returns it.
+func (as annotatedService) fail(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if kind := KindOf(err); kind == KindInternal || kind == KindUnavailable {
+		return &OperationError{Operation: method, Err: err}
+	}
+	return err
+}
+
+func (as annotatedService) Ping(ctx context.Context) error {
+	return as.fail("Ping", as.s.Ping(ctx))
+}
+
+func (as annotatedService) ListArticles(ctx context.Context, q ArticleQuery) (ArticlePage, error) {
+	v, err := as.s.ListArticles(ctx, q)
+	return v, as.fail("ListArticles", err)
+}
+
+func (as annotatedService) ListAuthors(ctx context.Context) ([]Author, error) {
+	v, err := as.s.ListAuthors(ctx)
+	return v, as.fail("ListAuthors", err)
+}
+
+func (as annotatedService) ListTags(ctx context.Context) ([]TagCount, error) {
+	v, err := as.s.ListTags(ctx)
+	return v, as.fail("ListTags", err)
+}
+
+func (as annotatedService) SearchArticles(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error) {
+	v, err := as.s.SearchArticles(ctx, query, opts)
+	return v, as.fail("SearchArticles", err)
+}
+
+func (as annotatedService) GetArticleById(ctx context.Context, id string) (Article, error) {
+	v, err := as.s.GetArticleById(ctx, id)
+	return v, as.fail("GetArticleById", err)
+}
+
+func (as annotatedService) GetArticleBySlug(ctx context.Context, slug string) (Article, error) {
+	v, err := as.s.GetArticleBySlug(ctx, slug)
+	return v, as.fail("GetArticleBySlug", err)
+}
+
+func (as annotatedService) GetAuthorById(ctx context.Context, id string) (Author, error) {
+	v, err := as.s.GetAuthorById(ctx, id)
+	return v, as.fail("GetAuthorById", err)
+}
+
+func (as annotatedService) GetAuthorsByIds(ctx context.Context, ids []string) ([]Author, error) {
+	v, err := as.s.GetAuthorsByIds(ctx, ids)
+	return v, as.fail("GetAuthorsByIds", err)
+}
+
+func (as annotatedService) GetAuthorByNameAndEmail(ctx context.Context, name string, email string) (Author, error) {
+	v, err := as.s.GetAuthorByNameAndEmail(ctx, name, email)
+	return v, as.fail("GetAuthorByNameAndEmail", err)
+}
+
+func (as annotatedService) GetAuthorByEmail(ctx context.Context, email string) (Author, error) {
+	v, err := as.s.GetAuthorByEmail(ctx, email)
+	return v, as.fail("GetAuthorByEmail", err)
+}
+
+func (as annotatedService) AddArticle(ctx context.Context, a Article) (string, error) {
+	v, err := as.s.AddArticle(ctx, a)
+	return v, as.fail("AddArticle", err)
+}
+
+func (as annotatedService) AddAuthor(ctx context.Context, a Author) (string, error) {
+	v, err := as.s.AddAuthor(ctx, a)
+	return v, as.fail("AddAuthor", err)
+}
+
+func (as annotatedService) UpdateAuthor(ctx context.Context, a Author) error {
+	return as.fail("UpdateAuthor", as.s.UpdateAuthor(ctx, a))
+}
+
+func (as annotatedService) SetAuthorProfile(ctx context.Context, id string, p Profile) error {
+	return as.fail("SetAuthorProfile", as.s.SetAuthorProfile(ctx, id, p))
+}
+
+func (as annotatedService) CreateArticleWithAuthor(ctx context.Context, a Article) (string, error) {
+	v, err := as.s.CreateArticleWithAuthor(ctx, a)
+	return v, as.fail("CreateArticleWithAuthor", err)
+}
+
+func (as annotatedService) UpdateArticle(ctx context.Context, a Article) error {
+	return as.fail("UpdateArticle", as.s.UpdateArticle(ctx, a))
+}
+
+func (as annotatedService) PatchArticle(ctx context.Context, id string, p ArticlePatch) error {
+	return as.fail("PatchArticle", as.s.PatchArticle(ctx, id, p))
+}
+
+func (as annotatedService) PublishArticle(ctx context.Context, id string) error {
+	return as.fail("PublishArticle", as.s.PublishArticle(ctx, id))
+}
+
+func (as annotatedService) UnpublishArticle(ctx context.Context, id string) error {
+	return as.fail("UnpublishArticle", as.s.UnpublishArticle(ctx, id))
+}
+
+func (as annotatedService) PublishScheduledArticles(ctx context.Context, now time.Time) (int, error) {
+	v, err := as.s.PublishScheduledArticles(ctx, now)
+	return v, as.fail("PublishScheduledArticles", err)
+}
+
+func (as annotatedService) DeleteArticleById(ctx context.Context, id string) error {
+	return as.fail("DeleteArticleById", as.s.DeleteArticleById(ctx, id))
+}
+
+func (as annotatedService) SetAuthorRole(ctx context.Context, id string, role string) error {
+	return as.fail("SetAuthorRole", as.s.SetAuthorRole(ctx, id, role))
+}
+
+func (as annotatedService) SetAuthorPassword(ctx context.Context, id string, hash string) error {
+	return as.fail("SetAuthorPassword", as.s.SetAuthorPassword(ctx, id, hash))
+}
+
+func (as annotatedService) DeleteAuthorById(ctx context.Context, id string) error {
+	return as.fail("DeleteAuthorById", as.s.DeleteAuthorById(ctx, id))
+}
+
+func (as annotatedService) DeleteAuthorByNameAndEmail(ctx context.Context, name string, email string) error {
+	return as.fail("DeleteAuthorByNameAndEmail", as.s.DeleteAuthorByNameAndEmail(ctx, name, email))
+}
+
+func (as annotatedService) ListRevisions(ctx context.Context, articleId string) ([]Revision, error) {
+	v, err := as.s.ListRevisions(ctx, articleId)
+	return v, as.fail("ListRevisions", err)
+}
+
+func (as annotatedService) GetRevision(ctx context.Context, articleId string, number int) (Revision, error) {
+	v, err := as.s.GetRevision(ctx, articleId, number)
+	return v, as.fail("GetRevision", err)
+}
+
+func (as annotatedService) RestoreRevision(ctx context.Context, articleId string, number int) error {
+	return as.fail("RestoreRevision", as.s.RestoreRevision(ctx, articleId, number))
+}
+
+func (as annotatedService) ListComments(ctx context.Context, articleId string) ([]Comment, error) {
+	v, err := as.s.ListComments(ctx, articleId)
+	return v, as.fail("ListComments", err)
+}
+
+func (as annotatedService) GetCommentById(ctx context.Context, id string) (Comment, error) {
+	v, err := as.s.GetCommentById(ctx, id)
+	return v, as.fail("GetCommentById", err)
+}
+
+func (as annotatedService) AddComment(ctx context.Context, c Comment) (string, error) {
+	v, err := as.s.AddComment(ctx, c)
+	return v, as.fail("AddComment", err)
+}
+
+func (as annotatedService) DeleteCommentById(ctx context.Context, id string) error {
+	return as.fail("DeleteCommentById", as.s.DeleteCommentById(ctx, id))
+}
+
+func (as annotatedService) ListTokens(ctx context.Context, authorId string) ([]Token, error) {
+	v, err := as.s.ListTokens(ctx, authorId)
+	return v, as.fail("ListTokens", err)
+}
+
+func (as annotatedService) GetTokenByHash(ctx context.Context, hash string) (Token, error) {
+	v, err := as.s.GetTokenByHash(ctx, hash)
+	return v, as.fail("GetTokenByHash", err)
+}
+
+func (as annotatedService) AddToken(ctx context.Context, t Token) (string, error) {
+	v, err := as.s.AddToken(ctx, t)
+	return v, as.fail("AddToken", err)
+}
+
+func (as annotatedService) RevokeToken(ctx context.Context, authorId string, id string) error {
+	return as.fail("RevokeToken", as.s.RevokeToken(ctx, authorId, id))
+}
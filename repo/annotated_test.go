@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// failingService is a BlogService whose GetArticleById fails with the given error.
+type failingService struct {
+	BlogService
+	err error
+}
+
+func (s failingService) GetArticleById(ctx context.Context, id string) (Article, error) {
+	return Article{}, s.err
+}
+
+func TestAnnotated(t *testing.T) {
+
+	get := func(err error) error {
+		_, err = Annotated(failingService{err: err}).GetArticleById(context.Background(), "id")
+		return err
+	}
+
+	t.Run("annotate unexpected errors with the operation", func(t *testing.T) {
+		failure := errors.New("cannot execute query")
+		err := get(failure)
+		require.ErrorIs(t, err, failure)
+		require.Equal(t, err.Error(), "cannot execute query")
+		require.Equal(t, OperationOf(err), "GetArticleById")
+		require.Equal(t, KindOf(err), KindInternal)
+	})
+
+	t.Run("keep the kind of unavailable errors", func(t *testing.T) {
+		err := get(Unavailable(errors.New("connection refused")))
+		require.Equal(t, OperationOf(err), "GetArticleById")
+		require.Equal(t, KindOf(err), KindUnavailable)
+	})
+
+	t.Run("do not annotate expected errors", func(t *testing.T) {
+		err := get(ErrArticleNotFound)
+		require.Equal(t, err, ErrArticleNotFound)
+		require.Empty(t, OperationOf(err))
+	})
+
+	t.Run("do not annotate successes", func(t *testing.T) {
+		require.NoError(t, get(nil))
+	})
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+var ErrInvalidCursor = NewError(KindValidation, "invalid_cursor", "invalid cursor")
+
+// Cursor marks the last article of a page, articles are ordered by (Key, Id).
+// SortBy and Order must match the query the cursor is used with.
+type Cursor struct {
+	SortBy string `json:"s"`
+	Order  string `json:"o"`
+	Key    string `json:"k"`
+	Id     string `json:"i"`
+}
+
+// NewCursor returns the cursor pointing after the given article for the given query.
+func NewCursor(q ArticleQuery, a Article) Cursor {
+	c := Cursor{SortBy: q.SortBy, Order: q.Order, Id: a.Id}
+	switch q.SortBy {
+	case SortByTitle:
+		c.Key = a.Title
+	default:
+		c.Key = a.PostedAt.Format(time.RFC3339Nano)
+	}
+	return c
+}
+
+// Encode returns the opaque string representation of the cursor.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c) // nolint: errcheck
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode and checks it matches the given query.
+func DecodeCursor(q ArticleQuery, s string) (Cursor, error) {
+	var c Cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if c.SortBy != q.SortBy || c.Order != q.Order || c.Id == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if c.SortBy == SortByPostedAt {
+		if _, err := time.Parse(time.RFC3339Nano, c.Key); err != nil {
+			return Cursor{}, ErrInvalidCursor
+		}
+	}
+	return c, nil
+}
+
+// PostedAt returns the cursor key as a time, for cursors sorted by posted_at.
+func (c Cursor) PostedAt() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, c.Key) // nolint: errcheck
+	return t
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+
+	a := Article{Id: "b4a4de9e-2f52-4cf1-8907-3d828d403126", Title: "Test title", PostedAt: time.Now()}
+
+	t.Run("round trip sorted by posted_at", func(t *testing.T) {
+		q := ArticleQuery{}.WithDefaults()
+		c, err := DecodeCursor(q, NewCursor(q, a).Encode())
+		require.NoError(t, err)
+		require.Equal(t, c.Id, a.Id)
+		require.True(t, c.PostedAt().Equal(a.PostedAt))
+	})
+
+	t.Run("round trip sorted by title", func(t *testing.T) {
+		q := ArticleQuery{SortBy: SortByTitle, Order: OrderAsc}.WithDefaults()
+		c, err := DecodeCursor(q, NewCursor(q, a).Encode())
+		require.NoError(t, err)
+		require.Equal(t, c.Key, a.Title)
+	})
+
+	t.Run("query mismatch", func(t *testing.T) {
+		q := ArticleQuery{}.WithDefaults()
+		_, err := DecodeCursor(ArticleQuery{SortBy: SortByTitle}.WithDefaults(), NewCursor(q, a).Encode())
+		require.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("malformed cursor", func(t *testing.T) {
+		_, err := DecodeCursor(ArticleQuery{}.WithDefaults(), "not a cursor")
+		require.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
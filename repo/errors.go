@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+)
+
+// Kind classifies the errors of the repositories by what the caller can do about them.
+type Kind int
+
+const (
+	// KindInternal is the kind of unexpected errors, such as invalid queries or corrupted rows.
+	KindInternal Kind = iota
+	// KindNotFound is the kind of errors for entities which do not exist.
+	KindNotFound
+	// KindConflict is the kind of errors for writes conflicting with the stored entities.
+	KindConflict
+	// KindValidation is the kind of errors for invalid input, with the invalid fields if any.
+	KindValidation
+	// KindUnavailable is the kind of errors for a database which cannot be reached or timed out.
+	KindUnavailable
+)
+
+// FieldError tells why a field of the input is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error of a given kind, with a stable code identifying it such as article_not_found.
+// Err is the underlying error, if any.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// NewError returns an error of the given kind, code and message.
+func NewError(kind Kind, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Invalid returns a validation error listing the invalid fields.
+func Invalid(fields ...FieldError) *Error {
+	messages := make([]string, 0, len(fields))
+	for _, f := range fields {
+		messages = append(messages, f.Field+" "+f.Message)
+	}
+	return &Error{Kind: KindValidation, Code: "validation_failed", Message: strings.Join(messages, ", "), Fields: fields}
+}
+
+// InvalidField returns a validation error for a single invalid field.
+func InvalidField(field string, message string) *Error {
+	return Invalid(FieldError{Field: field, Message: message})
+}
+
+// Unavailable returns the error of a database which cannot serve requests, wrapping its cause.
+func Unavailable(err error) *Error {
+	return &Error{Kind: KindUnavailable, Code: "service_unavailable", Message: "service unavailable", Err: err}
+}
+
+// KindOf returns the kind of an error. The unclassified errors are unavailable if they come
+// from a timeout or from the connection to the database, and internal otherwise.
+func KindOf(err error) Kind {
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return KindInternal
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return KindUnavailable
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		return KindUnavailable
+	case errors.As(err, &netErr):
+		return KindUnavailable
+	}
+	return KindInternal
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKindOf(t *testing.T) {
+
+	t.Run("kind of classified errors", func(t *testing.T) {
+		require.Equal(t, KindOf(ErrArticleNotFound), KindNotFound)
+		require.Equal(t, KindOf(fmt.Errorf("cannot get article: %w", ErrArticleNotFound)), KindNotFound)
+		require.Equal(t, KindOf(ErrAuthorExists), KindConflict)
+		require.Equal(t, KindOf(ErrInvalidCursor), KindValidation)
+		require.Equal(t, KindOf(Unavailable(errors.New("too many connections"))), KindUnavailable)
+	})
+
+	t.Run("timeouts and connection errors are unavailable", func(t *testing.T) {
+		require.Equal(t, KindOf(fmt.Errorf("cannot execute query: %w", context.DeadlineExceeded)), KindUnavailable)
+		require.Equal(t, KindOf(driver.ErrBadConn), KindUnavailable)
+		require.Equal(t, KindOf(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}), KindUnavailable)
+	})
+
+	t.Run("other errors are internal", func(t *testing.T) {
+		require.Equal(t, KindOf(errors.New("syntax error")), KindInternal)
+	})
+
+	t.Run("sentinels keep their message", func(t *testing.T) {
+		require.Equal(t, ErrArticleNotFound.Error(), "article not found")
+		require.ErrorIs(t, fmt.Errorf("cannot get article: %w", ErrArticleNotFound), ErrArticleNotFound)
+	})
+}
+
+func TestInvalid(t *testing.T) {
+
+	err := Invalid(FieldError{Field: "title", Message: "is required"}, FieldError{Field: "body", Message: "is required"})
+	require.Equal(t, err.Kind, KindValidation)
+	require.Equal(t, err.Code, "validation_failed")
+	require.Equal(t, err.Error(), "title is required, body is required")
+	require.Len(t, err.Fields, 2)
+}
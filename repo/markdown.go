@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// markdown renders CommonMark with tables, raw html is kept and left to the sanitizer.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.Table),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+// sanitizer keeps the html of user generated content, without scripts, event handlers
+// and javascript urls. The language of fenced code blocks is kept for syntax highlighting.
+var sanitizer = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	return p
+}()
+
+// RenderMarkdown renders the markdown source to sanitized html, safe to embed in a page.
+func RenderMarkdown(src string) string {
+
+	if src == "" {
+		return ""
+	}
+
+	// rendering only fails when the writer fails, a buffer does not
+	var buf bytes.Buffer
+	markdown.Convert([]byte(src), &buf) // nolint: errcheck
+
+	return sanitizer.Sanitize(buf.String())
+}
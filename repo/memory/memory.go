@@ -0,0 +1,1156 @@
+// Package memory implements the blog repository in memory, for local development and fast tests.
+// It follows the semantics of the postgres repository and is safe for concurrent use.
+package memory
+
+import (
+	repo "blog/repo"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Repository struct {
+	mu   sync.RWMutex
+	data snapshot
+}
+
+// snapshot is the content of the repository, each entity in insertion order.
+type snapshot struct {
+	Authors   []author        `json:"authors"`
+	Articles  []article       `json:"articles"`
+	Revisions []repo.Revision `json:"revisions"`
+	Slugs     []articleSlug   `json:"slugs"`
+	Comments  []comment       `json:"comments"`
+	Tokens    []token         `json:"tokens"`
+}
+
+type author struct {
+	Id           string `json:"id"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	Role         string `json:"role"`
+	repo.Profile
+}
+
+// article holds the id of its author and its sorted, normalized tags.
+type article struct {
+	Id        string     `json:"id"`
+	Title     string     `json:"title"`
+	Slug      string     `json:"slug"`
+	Body      string     `json:"body"`
+	BodyHTML  string     `json:"body_html"`
+	PostedAt  time.Time  `json:"posted_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	AuthorId  string     `json:"author_id"`
+	Tags      []string   `json:"tags"`
+}
+
+// articleSlug is the current or a previous slug of an article.
+type articleSlug struct {
+	Slug      string `json:"slug"`
+	ArticleId string `json:"article_id"`
+}
+
+type comment struct {
+	Id          string    `json:"id"`
+	ArticleId   string    `json:"article_id"`
+	ParentId    string    `json:"parent_id,omitempty"`
+	AuthorName  string    `json:"author_name"`
+	AuthorEmail string    `json:"author_email"`
+	Body        string    `json:"body"`
+	Depth       int       `json:"depth"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type token struct {
+	Id        string     `json:"id"`
+	AuthorId  string     `json:"author_id"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// New returns an empty repository.
+func New() *Repository {
+	return &Repository{}
+}
+
+// now returns the current time with the precision of the postgres timestamps.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (a author) toAuthor() repo.Author {
+	return repo.Author{Id: a.Id, Name: a.Name, Email: a.Email, Role: a.Role, Profile: copyProfile(a.Profile)}
+}
+
+// copyProfile returns a copy of the profile not sharing its social links, nil if empty as in the databases.
+func copyProfile(p repo.Profile) repo.Profile {
+	var social repo.SocialLinks
+	if len(p.Social) > 0 {
+		social = make(repo.SocialLinks, len(p.Social))
+		for k, v := range p.Social {
+			social[k] = v
+		}
+	}
+	p.Social = social
+	return p
+}
+
+func (a article) toArticle() repo.Article {
+	tags := make([]string, len(a.Tags))
+	copy(tags, a.Tags)
+	return repo.Article{
+		Id:        a.Id,
+		Title:     a.Title,
+		Slug:      a.Slug,
+		Body:      a.Body,
+		BodyHTML:  a.BodyHTML,
+		PostedAt:  a.PostedAt,
+		UpdatedAt: a.UpdatedAt,
+		Status:    a.Status,
+		PublishAt: copyTime(a.PublishAt),
+		Author:    repo.Author{Id: a.AuthorId},
+		Tags:      tags,
+	}
+}
+
+func (c comment) toComment() repo.Comment {
+	return repo.Comment{
+		Id:          c.Id,
+		ArticleId:   c.ArticleId,
+		ParentId:    c.ParentId,
+		AuthorName:  c.AuthorName,
+		AuthorEmail: c.AuthorEmail,
+		Body:        c.Body,
+		Depth:       c.Depth,
+		CreatedAt:   c.CreatedAt,
+	}
+}
+
+func (t token) toToken() repo.Token {
+	return repo.Token{Id: t.Id, AuthorId: t.AuthorId, Name: t.Name, Hash: t.Hash, CreatedAt: t.CreatedAt, RevokedAt: copyTime(t.RevokedAt)}
+}
+
+// copyTime returns a copy of the given time, so that callers do not share it with the repository.
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// sortedTags returns the normalized tag names, sorted.
+func sortedTags(tags []string) []string {
+	names := repo.NormalizeTags(tags)
+	sort.Strings(names)
+	return names
+}
+
+// checkStatus returns an error if the status is not a known article status.
+func checkStatus(status string) error {
+	if !repo.ValidStatus(status) {
+		return repo.InvalidField("status", fmt.Sprintf("%q is not a valid article status", status))
+	}
+	return nil
+}
+
+// findAuthor returns the index of the author with the given id, -1 if none.
+func (r *Repository) findAuthor(id string) int {
+	for i, a := range r.data.Authors {
+		if a.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// findAuthorByEmail returns the index of the author with the given email, -1 if none.
+func (r *Repository) findAuthorByEmail(email string) int {
+	for i, a := range r.data.Authors {
+		if a.Email == email {
+			return i
+		}
+	}
+	return -1
+}
+
+// findArticle returns the index of the article with the given id, -1 if none.
+func (r *Repository) findArticle(id string) int {
+	for i, a := range r.data.Articles {
+		if a.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// findComment returns the index of the comment with the given id, -1 if none.
+func (r *Repository) findComment(id string) int {
+	for i, c := range r.data.Comments {
+		if c.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Check that the repository is available, it always is.
+func (r *Repository) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Get a page of articles matching the query.
+func (r *Repository) ListArticles(ctx context.Context, q repo.ArticleQuery) (repo.ArticlePage, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	q = q.WithDefaults()
+	tag := repo.NormalizeTag(q.Tag)
+
+	emails := make(map[string]string, len(r.data.Authors))
+	for _, a := range r.data.Authors {
+		emails[a.Id] = a.Email
+	}
+
+	// apply the filters shared by the count and the page
+	matches := make([]article, 0)
+	for _, a := range r.data.Articles {
+		switch {
+		case q.AuthorId != "" && a.AuthorId != q.AuthorId:
+		case q.AuthorEmail != "" && emails[a.AuthorId] != q.AuthorEmail:
+		case q.Tag != "" && !hasTag(a, tag):
+		case q.Status != "" && a.Status != q.Status:
+		case !q.From.IsZero() && a.PostedAt.Before(q.From):
+		case !q.To.IsZero() && a.PostedAt.After(q.To):
+		default:
+			matches = append(matches, a)
+		}
+	}
+
+	page := repo.ArticlePage{Articles: make([]repo.Article, 0), Total: len(matches)}
+
+	// articles are ordered by (key, id), in the direction of the query
+	compare := func(a article, key string, posted time.Time, id string) int {
+		c := 0
+		if q.SortBy == repo.SortByTitle {
+			c = compareStrings(a.Title, key)
+		} else {
+			c = compareTimes(a.PostedAt, posted)
+		}
+		if c == 0 {
+			c = compareStrings(a.Id, id)
+		}
+		if q.Order != repo.OrderAsc {
+			c = -c
+		}
+		return c
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return compare(matches[i], matches[j].Title, matches[j].PostedAt, matches[j].Id) < 0
+	})
+
+	// continue after the last article of the previous page
+	if q.Cursor != "" {
+		c, err := repo.DecodeCursor(q, q.Cursor)
+		if err != nil {
+			return repo.ArticlePage{}, err
+		}
+		after := matches[:0:0]
+		for _, a := range matches {
+			if compare(a, c.Key, c.PostedAt(), c.Id) > 0 {
+				after = append(after, a)
+			}
+		}
+		matches = after
+	}
+
+	for _, a := range matches {
+		if len(page.Articles) == q.Limit {
+			page.NextCursor = repo.NewCursor(q, page.Articles[q.Limit-1]).Encode()
+			break
+		}
+		page.Articles = append(page.Articles, a.toArticle())
+	}
+
+	return page, nil
+}
+
+// hasTag returns whether the article has the given normalized tag.
+func hasTag(a article, tag string) bool {
+	for _, t := range a.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// Search articles by relevance over title and body, see repo.Search for the supported syntax.
+func (r *Repository) SearchArticles(ctx context.Context, query string, opts repo.SearchOptions) ([]repo.SearchResult, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	opts = opts.WithDefaults()
+	search := repo.ParseSearch(query)
+
+	results := make([]repo.SearchResult, 0)
+	for _, a := range r.data.Articles {
+		if opts.Status != "" && a.Status != opts.Status {
+			continue
+		}
+		if res, ok := search.Match(a.toArticle()); ok {
+			results = append(results, res)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if !a.PostedAt.Equal(b.PostedAt) {
+			return a.PostedAt.After(b.PostedAt)
+		}
+		return a.Id < b.Id
+	})
+
+	if opts.Offset >= len(results) {
+		return make([]repo.SearchResult, 0), nil
+	}
+	results = results[opts.Offset:]
+	if len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+
+	return results, nil
+}
+
+// Get all authors.
+func (r *Repository) ListAuthors(ctx context.Context) ([]repo.Author, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	authors := make([]repo.Author, 0, len(r.data.Authors))
+	for _, a := range r.data.Authors {
+		authors = append(authors, a.toAuthor())
+	}
+	return authors, nil
+}
+
+// Get all tags attached to at least one published article, with their published article counts.
+func (r *Repository) ListTags(ctx context.Context) ([]repo.TagCount, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, a := range r.data.Articles {
+		if a.Status != repo.StatusPublished {
+			continue
+		}
+		for _, t := range a.Tags {
+			counts[t]++
+		}
+	}
+
+	tags := make([]repo.TagCount, 0, len(counts))
+	for name, n := range counts {
+		tags = append(tags, repo.TagCount{Name: name, Articles: n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Articles != tags[j].Articles {
+			return tags[i].Articles > tags[j].Articles
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// Get article by id.
+func (r *Repository) GetArticleById(ctx context.Context, id string) (repo.Article, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.findArticle(id)
+	if i < 0 {
+		return repo.Article{}, repo.ErrArticleNotFound
+	}
+	return r.data.Articles[i].toArticle(), nil
+}
+
+// Get article by its current or one of its previous slugs.
+func (r *Repository) GetArticleBySlug(ctx context.Context, slug string) (repo.Article, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.data.Slugs {
+		if s.Slug == slug {
+			if i := r.findArticle(s.ArticleId); i >= 0 {
+				return r.data.Articles[i].toArticle(), nil
+			}
+		}
+	}
+	return repo.Article{}, repo.ErrArticleNotFound
+}
+
+// Get author by id.
+func (r *Repository) GetAuthorById(ctx context.Context, id string) (repo.Author, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.findAuthor(id)
+	if i < 0 {
+		return repo.Author{}, repo.ErrAuthorNotFound
+	}
+	return r.data.Authors[i].toAuthor(), nil
+}
+
+// Get authors by ids.
+func (r *Repository) GetAuthorsByIds(ctx context.Context, ids []string) ([]repo.Author, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// ids are uuids, as in postgres an invalid id is an error rather than no author
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			return []repo.Author{}, fmt.Errorf("invalid author id %q: %w", id, err)
+		}
+		wanted[id] = true
+	}
+
+	authors := make([]repo.Author, 0)
+	for _, a := range r.data.Authors {
+		if wanted[a.Id] {
+			authors = append(authors, a.toAuthor())
+		}
+	}
+	return authors, nil
+}
+
+// Get author by name and email.
+func (r *Repository) GetAuthorByNameAndEmail(ctx context.Context, name string, email string) (repo.Author, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, a := range r.data.Authors {
+		if a.Name == name && a.Email == email {
+			return a.toAuthor(), nil
+		}
+	}
+	return repo.Author{}, repo.ErrAuthorNotFound
+}
+
+// Get author by email, along with its password hash. Emails are unique.
+func (r *Repository) GetAuthorByEmail(ctx context.Context, email string) (repo.Author, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.findAuthorByEmail(email)
+	if i < 0 {
+		return repo.Author{}, repo.ErrAuthorNotFound
+	}
+
+	a := r.data.Authors[i].toAuthor()
+	a.PasswordHash = r.data.Authors[i].PasswordHash
+	return a, nil
+}
+
+// Add new author and return its id.
+func (r *Repository) AddAuthor(ctx context.Context, a repo.Author) (string, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.addAuthor(a)
+}
+
+// addAuthor adds the author, the email must not be used by another author.
+func (r *Repository) addAuthor(a repo.Author) (string, error) {
+
+	if r.findAuthorByEmail(a.Email) >= 0 {
+		return "", repo.ErrAuthorExists
+	}
+
+	role := a.Role
+	if role == "" {
+		role = repo.RoleAuthor
+	}
+	if !repo.ValidRole(role) {
+		return "", repo.InvalidField("role", fmt.Sprintf("%q is not a valid author role", role))
+	}
+
+	id := uuid.New().String()
+	r.data.Authors = append(r.data.Authors, author{Id: id, Name: a.Name, Email: a.Email, PasswordHash: a.PasswordHash, Role: role,
+		Profile: copyProfile(a.Profile)})
+	return id, nil
+}
+
+// Update author name and email by id.
+func (r *Repository) UpdateAuthor(ctx context.Context, a repo.Author) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.findAuthor(a.Id)
+	if i < 0 {
+		return repo.ErrAuthorNotFound
+	}
+	if j := r.findAuthorByEmail(a.Email); j >= 0 && j != i {
+		return repo.ErrAuthorExists
+	}
+
+	r.data.Authors[i].Name = a.Name
+	r.data.Authors[i].Email = a.Email
+	return nil
+}
+
+// Replace the profile of an author by id.
+func (r *Repository) SetAuthorProfile(ctx context.Context, id string, p repo.Profile) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.findAuthor(id)
+	if i < 0 {
+		return repo.ErrAuthorNotFound
+	}
+
+	r.data.Authors[i].Profile = copyProfile(p)
+	return nil
+}
+
+// Add new article with its tags and return its id.
+func (r *Repository) AddArticle(ctx context.Context, a repo.Article) (string, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.addArticle(a)
+}
+
+// Add new article with its author, added first if no author has the author's email, and return the article id.
+func (r *Repository) CreateArticleWithAuthor(ctx context.Context, a repo.Article) (string, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	authors := len(r.data.Authors)
+	if i := r.findAuthorByEmail(a.Author.Email); i >= 0 {
+		a.Author.Id = r.data.Authors[i].Id
+	} else {
+		id, err := r.addAuthor(repo.Author{Name: a.Author.Name, Email: a.Author.Email, Role: a.Author.Role})
+		if err != nil {
+			return "", err
+		}
+		a.Author.Id = id
+	}
+
+	id, err := r.addArticle(a)
+	if err != nil {
+		// remove the author added above, as a failed transaction would
+		r.data.Authors = r.data.Authors[:authors]
+		return "", err
+	}
+	return id, nil
+}
+
+// addArticle adds the article with its first revision, the author must exist.
+func (r *Repository) addArticle(a repo.Article) (string, error) {
+
+	// author id must exist in the authors
+	if r.findAuthor(a.Author.Id) < 0 {
+		return "", fmt.Errorf("cannot add article: %w", repo.ErrAuthorNotFound)
+	}
+
+	status := a.Status
+	if status == "" {
+		status = repo.StatusPublished
+	}
+	if err := checkStatus(status); err != nil {
+		return "", err
+	}
+
+	t := now()
+	art := article{
+		Id:        uuid.New().String(),
+		Title:     a.Title,
+		Body:      a.Body,
+		BodyHTML:  repo.RenderMarkdown(a.Body),
+		PostedAt:  t,
+		UpdatedAt: t,
+		Status:    status,
+		PublishAt: copyTime(a.PublishAt),
+		AuthorId:  a.Author.Id,
+		Tags:      sortedTags(a.Tags),
+	}
+	r.data.Articles = append(r.data.Articles, art)
+	r.setSlug(len(r.data.Articles) - 1)
+	r.addRevision(art)
+
+	return art.Id, nil
+}
+
+// Update article title, body and tags by id.
+func (r *Repository) UpdateArticle(ctx context.Context, a repo.Article) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.findArticle(a.Id)
+	if i < 0 {
+		return repo.ErrArticleNotFound
+	}
+
+	art := &r.data.Articles[i]
+	art.Title, art.Body, art.Tags, art.UpdatedAt = a.Title, a.Body, sortedTags(a.Tags), now()
+	art.BodyHTML = repo.RenderMarkdown(a.Body)
+	r.setSlug(i)
+	r.addRevision(*art)
+
+	return nil
+}
+
+// Update the non-nil fields of the patch on the article with the given id.
+func (r *Repository) PatchArticle(ctx context.Context, id string, p repo.ArticlePatch) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.findArticle(id)
+	if i < 0 {
+		return repo.ErrArticleNotFound
+	}
+	if p.Status != nil {
+		if err := checkStatus(*p.Status); err != nil {
+			return err
+		}
+	}
+
+	art := &r.data.Articles[i]
+	if p.Title != nil {
+		art.Title = *p.Title
+	}
+	if p.Body != nil {
+		art.Body, art.BodyHTML = *p.Body, repo.RenderMarkdown(*p.Body)
+	}
+	if p.Tags != nil {
+		art.Tags = sortedTags(*p.Tags)
+	}
+	// the publication time is set along with the status
+	if p.Status != nil {
+		art.Status, art.PublishAt = *p.Status, copyTime(p.PublishAt)
+	}
+	art.UpdatedAt = now()
+
+	if p.Title != nil {
+		r.setSlug(i)
+	}
+
+	// only changes of the text are recorded as revisions
+	if p.Title != nil || p.Body != nil {
+		r.addRevision(*art)
+	}
+
+	return nil
+}
+
+// Publish the article with the given id now, an article already published keeps its publication time.
+func (r *Repository) PublishArticle(ctx context.Context, id string) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.findArticle(id)
+	if i < 0 {
+		return repo.ErrArticleNotFound
+	}
+
+	t := now()
+	art := &r.data.Articles[i]
+	if art.Status != repo.StatusPublished {
+		art.PostedAt = t
+	}
+	art.Status, art.PublishAt, art.UpdatedAt = repo.StatusPublished, nil, t
+
+	return nil
+}
+
+// Move the article with the given id back to draft.
+func (r *Repository) UnpublishArticle(ctx context.Context, id string) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.findArticle(id)
+	if i < 0 {
+		return repo.ErrArticleNotFound
+	}
+
+	art := &r.data.Articles[i]
+	art.Status, art.PublishAt, art.UpdatedAt = repo.StatusDraft, nil, now()
+
+	return nil
+}
+
+// Publish the scheduled articles whose publication time is before now and return how many.
+func (r *Repository) PublishScheduledArticles(ctx context.Context, t time.Time) (int, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for i := range r.data.Articles {
+		art := &r.data.Articles[i]
+		if art.Status != repo.StatusScheduled || art.PublishAt == nil || art.PublishAt.After(t) {
+			continue
+		}
+		art.Status, art.PostedAt, art.PublishAt, art.UpdatedAt = repo.StatusPublished, *art.PublishAt, nil, now()
+		count++
+	}
+
+	return count, nil
+}
+
+// Delete article by id (and all its comments), its revisions are kept.
+func (r *Repository) DeleteArticleById(ctx context.Context, id string) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.findArticle(id) < 0 {
+		return repo.ErrArticleNotFound
+	}
+	r.deleteArticles(map[string]bool{id: true})
+
+	return nil
+}
+
+// deleteArticles deletes the articles with the given ids, along with their slugs and comments.
+// Their revisions are kept, as the history of what they were.
+func (r *Repository) deleteArticles(ids map[string]bool) {
+
+	articles := r.data.Articles[:0]
+	for _, a := range r.data.Articles {
+		if !ids[a.Id] {
+			articles = append(articles, a)
+		}
+	}
+	r.data.Articles = articles
+
+	slugs := r.data.Slugs[:0]
+	for _, s := range r.data.Slugs {
+		if !ids[s.ArticleId] {
+			slugs = append(slugs, s)
+		}
+	}
+	r.data.Slugs = slugs
+
+	comments := r.data.Comments[:0]
+	for _, c := range r.data.Comments {
+		if !ids[c.ArticleId] {
+			comments = append(comments, c)
+		}
+	}
+	r.data.Comments = comments
+}
+
+// Set the role of an author.
+func (r *Repository) SetAuthorRole(ctx context.Context, id string, role string) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !repo.ValidRole(role) {
+		return repo.InvalidField("role", fmt.Sprintf("%q is not a valid author role", role))
+	}
+
+	i := r.findAuthor(id)
+	if i < 0 {
+		return repo.ErrAuthorNotFound
+	}
+	r.data.Authors[i].Role = role
+
+	return nil
+}
+
+// Set the password hash of an author.
+func (r *Repository) SetAuthorPassword(ctx context.Context, id string, hash string) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.findAuthor(id)
+	if i < 0 {
+		return repo.ErrAuthorNotFound
+	}
+	r.data.Authors[i].PasswordHash = hash
+
+	return nil
+}
+
+// Delete author by id (and all its articles and tokens).
+func (r *Repository) DeleteAuthorById(ctx context.Context, id string) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.findAuthor(id) < 0 {
+		return repo.ErrAuthorNotFound
+	}
+	r.deleteAuthors(map[string]bool{id: true})
+
+	return nil
+}
+
+// Delete author by name and email (and all its articles and tokens).
+func (r *Repository) DeleteAuthorByNameAndEmail(ctx context.Context, name string, email string) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make(map[string]bool)
+	for _, a := range r.data.Authors {
+		if a.Name == name && a.Email == email {
+			ids[a.Id] = true
+		}
+	}
+	if len(ids) == 0 {
+		return repo.ErrAuthorNotFound
+	}
+	r.deleteAuthors(ids)
+
+	return nil
+}
+
+// deleteAuthors deletes the authors with the given ids, along with their articles and tokens.
+func (r *Repository) deleteAuthors(ids map[string]bool) {
+
+	authors := r.data.Authors[:0]
+	for _, a := range r.data.Authors {
+		if !ids[a.Id] {
+			authors = append(authors, a)
+		}
+	}
+	r.data.Authors = authors
+
+	articles := make(map[string]bool)
+	for _, a := range r.data.Articles {
+		if ids[a.AuthorId] {
+			articles[a.Id] = true
+		}
+	}
+	r.deleteArticles(articles)
+
+	tokens := r.data.Tokens[:0]
+	for _, t := range r.data.Tokens {
+		if !ids[t.AuthorId] {
+			tokens = append(tokens, t)
+		}
+	}
+	r.data.Tokens = tokens
+}
+
+// Get all revisions of an article, oldest first.
+func (r *Repository) ListRevisions(ctx context.Context, articleId string) ([]repo.Revision, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	revisions := make([]repo.Revision, 0)
+	for _, rev := range r.data.Revisions {
+		if rev.ArticleId == articleId {
+			revisions = append(revisions, rev)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Number < revisions[j].Number
+	})
+	return revisions, nil
+}
+
+// Get revision of an article by number.
+func (r *Repository) GetRevision(ctx context.Context, articleId string, number int) (repo.Revision, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, rev := range r.data.Revisions {
+		if rev.ArticleId == articleId && rev.Number == number {
+			return rev, nil
+		}
+	}
+	return repo.Revision{}, repo.ErrRevisionNotFound
+}
+
+// Restore the title and body of an article from one of its revisions, recorded as a new revision.
+func (r *Repository) RestoreRevision(ctx context.Context, articleId string, number int) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.findArticle(articleId)
+	if i < 0 {
+		return repo.ErrRevisionNotFound
+	}
+
+	for _, rev := range r.data.Revisions {
+		if rev.ArticleId == articleId && rev.Number == number {
+			art := &r.data.Articles[i]
+			art.Title, art.Body, art.UpdatedAt = rev.Title, rev.Body, now()
+			art.BodyHTML = repo.RenderMarkdown(rev.Body)
+			r.setSlug(i)
+			r.addRevision(*art)
+			return nil
+		}
+	}
+	return repo.ErrRevisionNotFound
+}
+
+// setSlug gives the article at index i the slug of its title, unless its slug is already
+// a candidate for it. The new slug is added to the previous ones of the article.
+func (r *Repository) setSlug(i int) {
+
+	art := &r.data.Articles[i]
+	slug := repo.Slugify(art.Title)
+	if repo.IsSlugCandidate(art.Slug, slug) {
+		return
+	}
+
+	taken := make(map[string]bool)
+	had := make(map[string]bool)
+	for _, s := range r.data.Slugs {
+		if s.ArticleId == art.Id {
+			had[s.Slug] = true
+		} else {
+			taken[s.Slug] = true
+		}
+	}
+
+	art.Slug = repo.FreeSlug(slug, taken)
+	if !had[art.Slug] {
+		r.data.Slugs = append(r.data.Slugs, articleSlug{Slug: art.Slug, ArticleId: art.Id})
+	}
+}
+
+// addRevision records the current title and body of the article as its next revision.
+func (r *Repository) addRevision(a article) {
+
+	number := 0
+	for _, rev := range r.data.Revisions {
+		if rev.ArticleId == a.Id && rev.Number > number {
+			number = rev.Number
+		}
+	}
+
+	r.data.Revisions = append(r.data.Revisions, repo.Revision{
+		ArticleId: a.Id,
+		Number:    number + 1,
+		Title:     a.Title,
+		Body:      a.Body,
+		CreatedAt: now(),
+	})
+}
+
+// Get all comments of an article, oldest first.
+func (r *Repository) ListComments(ctx context.Context, articleId string) ([]repo.Comment, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	comments := make([]repo.Comment, 0)
+	for _, c := range r.data.Comments {
+		if c.ArticleId == articleId {
+			comments = append(comments, c.toComment())
+		}
+	}
+	sort.SliceStable(comments, func(i, j int) bool {
+		if !comments[i].CreatedAt.Equal(comments[j].CreatedAt) {
+			return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+		}
+		return comments[i].Id < comments[j].Id
+	})
+	return comments, nil
+}
+
+// Get comment by id.
+func (r *Repository) GetCommentById(ctx context.Context, id string) (repo.Comment, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.findComment(id)
+	if i < 0 {
+		return repo.Comment{}, repo.ErrCommentNotFound
+	}
+	return r.data.Comments[i].toComment(), nil
+}
+
+// Add new comment and return its id.
+func (r *Repository) AddComment(ctx context.Context, c repo.Comment) (string, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// article id and parent id (if any) must exist
+	if r.findArticle(c.ArticleId) < 0 {
+		return "", fmt.Errorf("cannot add comment: %w", repo.ErrArticleNotFound)
+	}
+	if c.ParentId != "" && r.findComment(c.ParentId) < 0 {
+		return "", fmt.Errorf("cannot add comment: %w", repo.ErrCommentNotFound)
+	}
+
+	id := uuid.New().String()
+	r.data.Comments = append(r.data.Comments, comment{
+		Id:          id,
+		ArticleId:   c.ArticleId,
+		ParentId:    c.ParentId,
+		AuthorName:  c.AuthorName,
+		AuthorEmail: c.AuthorEmail,
+		Body:        c.Body,
+		Depth:       c.Depth,
+		CreatedAt:   now(),
+	})
+	return id, nil
+}
+
+// Delete comment by id (and all its replies).
+func (r *Repository) DeleteCommentById(ctx context.Context, id string) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.findComment(id) < 0 {
+		return repo.ErrCommentNotFound
+	}
+
+	// collect the replies, replies always come after their parent
+	ids := map[string]bool{id: true}
+	for _, c := range r.data.Comments {
+		if ids[c.ParentId] {
+			ids[c.Id] = true
+		}
+	}
+
+	comments := r.data.Comments[:0]
+	for _, c := range r.data.Comments {
+		if !ids[c.Id] {
+			comments = append(comments, c)
+		}
+	}
+	r.data.Comments = comments
+
+	return nil
+}
+
+// Get all API tokens of an author, newest first.
+func (r *Repository) ListTokens(ctx context.Context, authorId string) ([]repo.Token, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tokens := make([]repo.Token, 0)
+	for _, t := range r.data.Tokens {
+		if t.AuthorId == authorId {
+			tokens = append(tokens, t.toToken())
+		}
+	}
+	sort.SliceStable(tokens, func(i, j int) bool {
+		if !tokens[i].CreatedAt.Equal(tokens[j].CreatedAt) {
+			return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+		}
+		return tokens[i].Id < tokens[j].Id
+	})
+	return tokens, nil
+}
+
+// Get API token by hash, revoked tokens included.
+func (r *Repository) GetTokenByHash(ctx context.Context, hash string) (repo.Token, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.data.Tokens {
+		if t.Hash == hash {
+			return t.toToken(), nil
+		}
+	}
+	return repo.Token{}, repo.ErrTokenNotFound
+}
+
+// Add new API token and return its id.
+func (r *Repository) AddToken(ctx context.Context, t repo.Token) (string, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// author id must exist in the authors, the hash must be unique
+	if r.findAuthor(t.AuthorId) < 0 {
+		return "", fmt.Errorf("cannot add token: %w", repo.ErrAuthorNotFound)
+	}
+	for _, other := range r.data.Tokens {
+		if other.Hash == t.Hash {
+			return "", errors.New("cannot add token: duplicate token hash")
+		}
+	}
+
+	id := uuid.New().String()
+	r.data.Tokens = append(r.data.Tokens, token{Id: id, AuthorId: t.AuthorId, Name: t.Name, Hash: t.Hash, CreatedAt: now()})
+	return id, nil
+}
+
+// Revoke an active API token of an author.
+func (r *Repository) RevokeToken(ctx context.Context, authorId string, id string) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, t := range r.data.Tokens {
+		if t.Id == id && t.AuthorId == authorId && t.RevokedAt == nil {
+			revoked := now()
+			r.data.Tokens[i].RevokedAt = &revoked
+			return nil
+		}
+	}
+	return repo.ErrTokenNotFound
+}
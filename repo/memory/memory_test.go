@@ -0,0 +1,181 @@
+package memory
+
+import (
+	repo "blog/repo"
+	"blog/repo/repotest"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+// newTestRepository returns a repository with 2 authors having one article each.
+func newTestRepository(t *testing.T) (*Repository, []string) {
+	t.Helper()
+
+	r := New()
+	ids := make([]string, 0, 2)
+	for _, n := range []string{"1", "2"} {
+		authorId, err := r.AddAuthor(ctx, repo.Author{Name: "Test author " + n, Email: "test.author" + n + "@email.com"})
+		require.NoError(t, err)
+		id, err := r.AddArticle(ctx, repo.Article{Title: "Test title " + n, Body: "Test body " + n, Author: repo.Author{Id: authorId}})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	return r, ids
+}
+
+func TestConformance(t *testing.T) {
+	repotest.RunConformance(t, func(t *testing.T) repo.BlogService {
+		return New()
+	})
+}
+
+func TestSearchArticles(t *testing.T) {
+
+	r, _ := newTestRepository(t)
+	a, err := r.GetAuthorByNameAndEmail(ctx, "Test author 1", "test.author1@email.com")
+	require.NoError(t, err)
+
+	_, err = r.AddArticle(ctx, repo.Article{Title: "Go generics", Body: "Generics in go", Author: a})
+	require.NoError(t, err)
+	_, err = r.AddArticle(ctx, repo.Article{Title: "Other", Body: "A word about generics", Author: a})
+	require.NoError(t, err)
+
+	t.Run("excluded and quoted terms", func(t *testing.T) {
+		results, err := r.SearchArticles(ctx, "generics -word", repo.SearchOptions{})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+
+		results, err = r.SearchArticles(ctx, `"about generics"`, repo.SearchOptions{})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, results[0].Title, "Other")
+	})
+
+}
+
+func TestAddArticle(t *testing.T) {
+
+	r, ids := newTestRepository(t)
+
+	t.Run("defaults", func(t *testing.T) {
+		a, err := r.GetArticleById(ctx, ids[0])
+		require.NoError(t, err)
+		require.Equal(t, a.Status, repo.StatusPublished)
+		require.False(t, a.PostedAt.IsZero())
+		require.Equal(t, a.PostedAt, a.UpdatedAt)
+		require.Equal(t, a.Tags, []string{})
+	})
+
+	t.Run("non-existing author", func(t *testing.T) {
+		_, err := r.AddArticle(ctx, repo.Article{Title: "test", Body: "test", Author: repo.Author{Id: "b4a4de9e-2f52-4cf1-8907-3d828d403128"}})
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+	})
+
+	t.Run("non-existing article", func(t *testing.T) {
+		_, err := r.GetArticleById(ctx, "b4a4de9e-2f52-4cf1-8907-3d828d403128")
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+		err = r.UpdateArticle(ctx, repo.Article{Id: "b4a4de9e-2f52-4cf1-8907-3d828d403128"})
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+	})
+}
+
+func TestComments(t *testing.T) {
+
+	r, ids := newTestRepository(t)
+
+	t.Run("replies are deleted with their parent", func(t *testing.T) {
+		parent, err := r.AddComment(ctx, repo.Comment{ArticleId: ids[0], AuthorName: "reader", Body: "comment"})
+		require.NoError(t, err)
+		reply, err := r.AddComment(ctx, repo.Comment{ArticleId: ids[0], ParentId: parent, AuthorName: "reader", Body: "reply", Depth: 1})
+		require.NoError(t, err)
+		_, err = r.AddComment(ctx, repo.Comment{ArticleId: ids[0], ParentId: reply, AuthorName: "reader", Body: "reply", Depth: 2})
+		require.NoError(t, err)
+
+		err = r.DeleteCommentById(ctx, parent)
+		require.NoError(t, err)
+		comments, err := r.ListComments(ctx, ids[0])
+		require.NoError(t, err)
+		require.Empty(t, comments)
+	})
+
+	t.Run("non-existing parent", func(t *testing.T) {
+		_, err := r.AddComment(ctx, repo.Comment{ArticleId: ids[0], ParentId: "b4a4de9e-2f52-4cf1-8907-3d828d403128", Body: "reply"})
+		require.ErrorIs(t, err, repo.ErrCommentNotFound)
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+
+	r, ids := newTestRepository(t)
+	path := filepath.Join(t.TempDir(), "blog.json")
+
+	t.Run("missing file", func(t *testing.T) {
+		empty, err := Load(path)
+		require.NoError(t, err)
+		authors, err := empty.ListAuthors(ctx)
+		require.NoError(t, err)
+		require.Empty(t, authors)
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		_, err := r.AddAuthor(ctx, repo.Author{Name: "admin", Email: "admin@email.com", PasswordHash: "hash", Role: repo.RoleAdmin})
+		require.NoError(t, err)
+		require.NoError(t, r.Save(path))
+
+		loaded, err := Load(path)
+		require.NoError(t, err)
+
+		want, err := r.GetArticleById(ctx, ids[0])
+		require.NoError(t, err)
+		got, err := loaded.GetArticleById(ctx, ids[0])
+		require.NoError(t, err)
+		require.Equal(t, got, want)
+
+		admin, err := loaded.GetAuthorByEmail(ctx, "admin@email.com")
+		require.NoError(t, err)
+		require.Equal(t, admin.Role, repo.RoleAdmin)
+		require.Equal(t, admin.PasswordHash, "hash")
+	})
+
+	t.Run("load snapshot without slugs", func(t *testing.T) {
+		old := filepath.Join(t.TempDir(), "old.json")
+		data := `{"authors": [{"id": "a1", "name": "test", "email": "test@email.com", "role": "author"}],
+			"articles": [{"id": "b1", "title": "Old article", "body": "old", "status": "published", "author_id": "a1"}]}`
+		require.NoError(t, os.WriteFile(old, []byte(data), 0o600))
+
+		loaded, err := Load(old)
+		require.NoError(t, err)
+		a, err := loaded.GetArticleBySlug(ctx, "old-article")
+		require.NoError(t, err)
+		require.Equal(t, a.Id, "b1")
+	})
+}
+
+func TestConcurrentUse(t *testing.T) {
+
+	r, ids := newTestRepository(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.AddComment(ctx, repo.Comment{ArticleId: ids[0], AuthorName: "reader", Body: "comment"})
+			require.NoError(t, err)
+			_, err = r.ListArticles(ctx, repo.ArticleQuery{})
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	comments, err := r.ListComments(ctx, ids[0])
+	require.NoError(t, err)
+	require.Len(t, comments, 10)
+}
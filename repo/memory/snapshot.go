@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Load returns a repository holding the content of the given JSON snapshot file,
+// the repository is empty if the file does not exist yet.
+func Load(path string) (*Repository, error) {
+
+	r := New()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot: %w", err)
+	}
+
+	err = json.Unmarshal(data, &r.data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse snapshot %s: %w", path, err)
+	}
+
+	// the articles of the snapshots saved before slugs existed get one
+	for i := range r.data.Articles {
+		if r.data.Articles[i].Slug == "" {
+			r.setSlug(i)
+		}
+	}
+
+	return r, nil
+}
+
+// Save writes the content of the repository to the given JSON snapshot file.
+// The file is replaced at once, so that a failed save leaves the previous snapshot intact.
+func (r *Repository) Save(path string) error {
+
+	r.mu.RLock()
+	data, err := json.MarshalIndent(r.data, "", "  ")
+	r.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("cannot encode snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot write snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+
+	_, err = tmp.Write(data)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write snapshot: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("cannot write snapshot: %w", err)
+	}
+
+	return nil
+}
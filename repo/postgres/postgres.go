@@ -2,49 +2,229 @@ package postgres
 
 import (
 	repo "blog/repo"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
 
 type PSQLRepository struct {
 	DB *sql.DB
+	// QueryTimeout bounds the queries of every call, in addition to the deadline of its context.
+	QueryTimeout time.Duration
 }
 
-// Get all articles.
-func (r *PSQLRepository) ListArticles() ([]repo.Article, error) {
+// withTimeout returns the context of the queries of a call, bounded by the query timeout if any.
+func (r *PSQLRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.QueryTimeout)
+}
+
+// Check that the database is reachable.
+func (r *PSQLRepository) Ping(ctx context.Context) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	err := r.DB.PingContext(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot ping database: %w", err)
+	}
+
+	return nil
+}
+
+// Get a page of articles matching the query.
+func (r *PSQLRepository) ListArticles(ctx context.Context, q repo.ArticleQuery) (repo.ArticlePage, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	q = q.WithDefaults()
+
+	// build the filters shared by the count and the page queries
+	where := make([]string, 0)
+	args := make([]interface{}, 0)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.AuthorId != "" {
+		where = append(where, "a.author_id = "+arg(q.AuthorId))
+	}
+	if q.AuthorEmail != "" {
+		where = append(where, "au.email = "+arg(q.AuthorEmail))
+	}
+	if q.Tag != "" {
+		where = append(where, `EXISTS (SELECT 1 FROM article_tags at JOIN tags t ON t.id = at.tag_id
+			WHERE at.article_id = a.id AND t.name = `+arg(repo.NormalizeTag(q.Tag))+`)`)
+	}
+	if q.Status != "" {
+		where = append(where, "a.status = "+arg(q.Status))
+	}
+	if !q.From.IsZero() {
+		where = append(where, "a.posted_at >= "+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "a.posted_at <= "+arg(q.To))
+	}
+
+	page := repo.ArticlePage{Articles: make([]repo.Article, 0)}
+
+	query := `SELECT COUNT(*) FROM articles a JOIN authors au ON au.id = a.author_id` + whereClause(where) + `;`
+	err := r.DB.QueryRowContext(ctx, query, args...).Scan(&page.Total)
+	if err != nil {
+		return repo.ArticlePage{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	column, op, dir := "a.posted_at", "<", "DESC"
+	if q.SortBy == repo.SortByTitle {
+		column = "a.title"
+	}
+	if q.Order == repo.OrderAsc {
+		op, dir = ">", "ASC"
+	}
+
+	// continue after the last article of the previous page
+	if q.Cursor != "" {
+		c, err := repo.DecodeCursor(q, q.Cursor)
+		if err != nil {
+			return repo.ArticlePage{}, err
+		}
+		var key interface{} = c.Key
+		if q.SortBy == repo.SortByPostedAt {
+			key = c.PostedAt()
+		}
+		where = append(where, fmt.Sprintf("(%s, a.id) %s (%s, %s)", column, op, arg(key), arg(c.Id)))
+	}
 
-	articles := make([]repo.Article, 0)
-	query := `SELECT a.id, a.title, a.body, a.posted_at, a.author_id FROM articles a;`
+	// fetch one more article to know if there is a next page
+	query = fmt.Sprintf(`SELECT a.id, a.title, a.slug, a.body, a.body_html, a.posted_at, a.updated_at, a.status, a.publish_at, a.author_id
+		FROM articles a JOIN authors au ON au.id = a.author_id%s
+		ORDER BY %s %s, a.id %s LIMIT %s;`, whereClause(where), column, dir, dir, arg(q.Limit+1))
 
-	rows, err := r.DB.Query(query)
+	rows, err := r.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return []repo.Article{}, fmt.Errorf("cannot execute query: %w", err)
+		return repo.ArticlePage{}, fmt.Errorf("cannot execute query: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var art repo.Article
 		var auth repo.Author
-		err := rows.Scan(&art.Id, &art.Title, &art.Body, &art.PostedAt, &auth.Id)
+		err := rows.Scan(&art.Id, &art.Title, &art.Slug, &art.Body, &art.BodyHTML, &art.PostedAt, &art.UpdatedAt, &art.Status, &art.PublishAt, &auth.Id)
 		if err != nil {
-			return []repo.Article{}, fmt.Errorf("cannot scan article: %w", err)
+			return repo.ArticlePage{}, fmt.Errorf("cannot scan article: %w", err)
 		}
 		art.Author = auth
-		articles = append(articles, art)
+		page.Articles = append(page.Articles, art)
+	}
+
+	if len(page.Articles) > q.Limit {
+		page.Articles = page.Articles[:q.Limit]
+		page.NextCursor = repo.NewCursor(q, page.Articles[q.Limit-1]).Encode()
+	}
+
+	// get the tags of the articles in the page
+	ids := make([]string, 0, len(page.Articles))
+	for _, a := range page.Articles {
+		ids = append(ids, a.Id)
+	}
+	tags, err := r.getTagMap(ctx, ids)
+	if err != nil {
+		return repo.ArticlePage{}, err
+	}
+	for i := range page.Articles {
+		page.Articles[i].Tags = tags[page.Articles[i].Id]
+	}
+
+	return page, nil
+}
+
+// whereClause joins the given conditions into a WHERE clause.
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// Search articles by relevance over title and body, the query uses the web search syntax.
+func (r *PSQLRepository) SearchArticles(ctx context.Context, query string, opts repo.SearchOptions) ([]repo.SearchResult, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	opts = opts.WithDefaults()
+	results := make([]repo.SearchResult, 0)
+
+	sqlQuery := `SELECT a.id, a.title, a.slug, a.body, a.body_html, a.posted_at, a.updated_at, a.status, a.publish_at, a.author_id, ts_rank(a.search, q),
+			ts_headline('english', a.title, q, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline('english', a.body, q, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10')
+		FROM articles a, websearch_to_tsquery('english', $1) q
+		WHERE a.search @@ q AND ($4 = '' OR a.status = $4)
+		ORDER BY 11 DESC, a.posted_at DESC, a.id
+		LIMIT $2 OFFSET $3;`
+
+	rows, err := r.DB.QueryContext(ctx, sqlQuery, query, opts.Limit, opts.Offset, opts.Status)
+	if err != nil {
+		return []repo.SearchResult{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res repo.SearchResult
+		err := rows.Scan(&res.Id, &res.Title, &res.Slug, &res.Body, &res.BodyHTML, &res.PostedAt, &res.UpdatedAt, &res.Status, &res.PublishAt, &res.Author.Id,
+			&res.Rank, &res.TitleHighlight, &res.Snippet)
+		if err != nil {
+			return []repo.SearchResult{}, fmt.Errorf("cannot scan search result: %w", err)
+		}
+		res.TitleHighlight = repo.EscapeHighlight(res.TitleHighlight)
+		res.Snippet = repo.EscapeHighlight(res.Snippet)
+		results = append(results, res)
+	}
+
+	// get the tags of the matching articles
+	ids := make([]string, 0, len(results))
+	for _, res := range results {
+		ids = append(ids, res.Id)
+	}
+	tags, err := r.getTagMap(ctx, ids)
+	if err != nil {
+		return []repo.SearchResult{}, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].Id]
 	}
-	return articles, nil
+
+	return results, nil
+}
+
+// authorColumns are the columns of the authors table read into authorFields.
+const authorColumns = `a.id, a.name, a.email, a.role, a.display_name, a.bio, a.avatar_url, a.website, a.social`
+
+// authorFields returns the destinations of authorColumns in the given author.
+func authorFields(a *repo.Author) []interface{} {
+	return []interface{}{&a.Id, &a.Name, &a.Email, &a.Role, &a.DisplayName, &a.Bio, &a.AvatarURL, &a.Website, &a.Social}
 }
 
 // Get all authors.
-func (r *PSQLRepository) ListAuthors() ([]repo.Author, error) {
+func (r *PSQLRepository) ListAuthors(ctx context.Context) ([]repo.Author, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
 
 	authors := make([]repo.Author, 0)
-	query := `SELECT a.id, a.name, a.email FROM authors a;`
+	query := `SELECT ` + authorColumns + ` FROM authors a;`
 
-	rows, err := r.DB.Query(query)
+	rows, err := r.DB.QueryContext(ctx, query)
 	if err != nil {
 		return []repo.Author{}, fmt.Errorf("cannot execute query: %w", err)
 	}
@@ -52,7 +232,7 @@ func (r *PSQLRepository) ListAuthors() ([]repo.Author, error) {
 
 	for rows.Next() {
 		var a repo.Author
-		err := rows.Scan(&a.Id, &a.Name, &a.Email)
+		err := rows.Scan(authorFields(&a)...)
 		if err != nil {
 			return []repo.Author{}, fmt.Errorf("cannot scan author: %w", err)
 		}
@@ -61,39 +241,156 @@ func (r *PSQLRepository) ListAuthors() ([]repo.Author, error) {
 	return authors, nil
 }
 
-var ErrArticleNotFound = errors.New("article not found")
+// Get all tags attached to at least one published article, with their published article counts.
+func (r *PSQLRepository) ListTags(ctx context.Context) ([]repo.TagCount, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tags := make([]repo.TagCount, 0)
+	query := `SELECT t.name, COUNT(*) FROM tags t JOIN article_tags at ON at.tag_id = t.id
+		JOIN articles a ON a.id = at.article_id WHERE a.status = 'published'
+		GROUP BY t.name ORDER BY COUNT(*) DESC, t.name;`
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return []repo.TagCount{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t repo.TagCount
+		err := rows.Scan(&t.Name, &t.Articles)
+		if err != nil {
+			return []repo.TagCount{}, fmt.Errorf("cannot scan tag: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, nil
+}
+
+// getTagMap returns the sorted tag names of the given articles, keyed by article id.
+// Every article id has an entry, empty if the article has no tags.
+func (r *PSQLRepository) getTagMap(ctx context.Context, ids []string) (map[string][]string, error) {
+
+	tags := make(map[string][]string, len(ids))
+	for _, id := range ids {
+		tags[id] = make([]string, 0)
+	}
+	if len(ids) == 0 {
+		return tags, nil
+	}
+
+	query := `SELECT at.article_id, t.name FROM article_tags at JOIN tags t ON t.id = at.tag_id
+		WHERE at.article_id = any($1) ORDER BY t.name;`
+	rows, err := r.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, name string
+		err := rows.Scan(&id, &name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot scan tag: %w", err)
+		}
+		tags[id] = append(tags[id], name)
+	}
+	return tags, nil
+}
+
+// setTags replaces the tags of the article with the given id, creating the missing tags.
+func setTags(ctx context.Context, tx *sql.Tx, id string, tags []string) error {
+
+	names := pq.Array(repo.NormalizeTags(tags))
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM article_tags WHERE article_id = $1;`, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO tags(name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING;`, names)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	query := `INSERT INTO article_tags(article_id, tag_id) SELECT $1, t.id FROM tags t WHERE t.name = any($2);`
+	_, err = tx.ExecContext(ctx, query, id, names)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	return nil
+}
+
+var ErrArticleNotFound = repo.ErrArticleNotFound
 
 // Get article by id.
-func (r *PSQLRepository) GetArticleById(id string) (repo.Article, error) {
+func (r *PSQLRepository) GetArticleById(ctx context.Context, id string) (repo.Article, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
 
 	var art repo.Article
 	var auth repo.Author
 
-	query := `SELECT a.id, a.title, a.body, a.posted_at, a.author_id FROM articles a WHERE a.id = $1;`
-	row := r.DB.QueryRow(query, id)
+	query := `SELECT a.id, a.title, a.slug, a.body, a.body_html, a.posted_at, a.updated_at, a.status, a.publish_at, a.author_id FROM articles a WHERE a.id = $1;`
+	row := r.DB.QueryRowContext(ctx, query, id)
 
-	switch err := row.Scan(&art.Id, &art.Title, &art.Body, &art.PostedAt, &auth.Id); err {
+	switch err := row.Scan(&art.Id, &art.Title, &art.Slug, &art.Body, &art.BodyHTML, &art.PostedAt, &art.UpdatedAt, &art.Status, &art.PublishAt, &auth.Id); err {
 	case sql.ErrNoRows:
 		return repo.Article{}, ErrArticleNotFound
 	case nil:
 		art.Author = auth
-		return art, nil
 	default:
 		return repo.Article{}, fmt.Errorf("cannot scan article: %w", err)
 	}
+
+	tags, err := r.getTagMap(ctx, []string{art.Id})
+	if err != nil {
+		return repo.Article{}, err
+	}
+	art.Tags = tags[art.Id]
+
+	return art, nil
+}
+
+// Get article by its current or one of its previous slugs.
+func (r *PSQLRepository) GetArticleBySlug(ctx context.Context, slug string) (repo.Article, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var id string
+
+	query := `SELECT s.article_id FROM article_slugs s WHERE s.slug = $1;`
+	row := r.DB.QueryRowContext(ctx, query, slug)
+
+	switch err := row.Scan(&id); err {
+	case sql.ErrNoRows:
+		return repo.Article{}, ErrArticleNotFound
+	case nil:
+		return r.GetArticleById(ctx, id)
+	default:
+		return repo.Article{}, fmt.Errorf("cannot scan slug: %w", err)
+	}
 }
 
-var ErrAuthorNotFound = errors.New("author not found")
+var ErrAuthorNotFound = repo.ErrAuthorNotFound
 
 // Get author by id.
-func (r *PSQLRepository) GetAuthorById(id string) (repo.Author, error) {
+func (r *PSQLRepository) GetAuthorById(ctx context.Context, id string) (repo.Author, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
 
 	var a repo.Author
 
-	query := `SELECT a.id, a.name, a.email FROM authors a WHERE a.id = $1;`
-	row := r.DB.QueryRow(query, id)
+	query := `SELECT ` + authorColumns + ` FROM authors a WHERE a.id = $1;`
+	row := r.DB.QueryRowContext(ctx, query, id)
 
-	switch err := row.Scan(&a.Id, &a.Name, &a.Email); err {
+	switch err := row.Scan(authorFields(&a)...); err {
 	case sql.ErrNoRows:
 		return repo.Author{}, ErrAuthorNotFound
 	case nil:
@@ -104,12 +401,15 @@ func (r *PSQLRepository) GetAuthorById(id string) (repo.Author, error) {
 }
 
 // Get authors by ids.
-func (r *PSQLRepository) GetAuthorsByIds(ids []string) ([]repo.Author, error) {
+func (r *PSQLRepository) GetAuthorsByIds(ctx context.Context, ids []string) ([]repo.Author, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
 
 	authors := make([]repo.Author, 0)
 
-	query := `SELECT a.id, a.name, a.email FROM authors a WHERE a.id = any($1);`
-	rows, err := r.DB.Query(query, pq.Array(ids))
+	query := `SELECT ` + authorColumns + ` FROM authors a WHERE a.id = any($1);`
+	rows, err := r.DB.QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
 		return []repo.Author{}, fmt.Errorf("cannot execute query: %w", err)
 	}
@@ -117,7 +417,7 @@ func (r *PSQLRepository) GetAuthorsByIds(ids []string) ([]repo.Author, error) {
 
 	for rows.Next() {
 		var a repo.Author
-		err := rows.Scan(&a.Id, &a.Name, &a.Email)
+		err := rows.Scan(authorFields(&a)...)
 		if err != nil {
 			return []repo.Author{}, fmt.Errorf("cannot scan author: %w", err)
 		}
@@ -128,14 +428,39 @@ func (r *PSQLRepository) GetAuthorsByIds(ids []string) ([]repo.Author, error) {
 }
 
 // Get author by name and email.
-func (r *PSQLRepository) GetAuthorByNameAndEmail(name string, email string) (repo.Author, error) {
+func (r *PSQLRepository) GetAuthorByNameAndEmail(ctx context.Context, name string, email string) (repo.Author, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var a repo.Author
+
+	query := `SELECT ` + authorColumns + ` FROM authors a WHERE a.name = $1 AND a.email = $2;`
+	row := r.DB.QueryRowContext(ctx, query, name, email)
+
+	switch err := row.Scan(authorFields(&a)...); err {
+	case sql.ErrNoRows:
+		return repo.Author{}, ErrAuthorNotFound
+	case nil:
+		return a, nil
+	default:
+		return repo.Author{}, fmt.Errorf("cannot scan author: %w", err)
+	}
+}
+
+// Get author by email, along with its password hash. Emails are unique.
+func (r *PSQLRepository) GetAuthorByEmail(ctx context.Context, email string) (repo.Author, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
 
 	var a repo.Author
 
-	query := `SELECT a.id, a.name, a.email FROM authors a WHERE a.name = $1 AND a.email = $2;`
-	row := r.DB.QueryRow(query, name, email)
+	query := `SELECT ` + authorColumns + `, COALESCE(a.password_hash, '') FROM authors a
+		WHERE a.email = $1;`
+	row := r.DB.QueryRowContext(ctx, query, email)
 
-	switch err := row.Scan(&a.Id, &a.Name, &a.Email); err {
+	switch err := row.Scan(append(authorFields(&a), &a.PasswordHash)...); err {
 	case sql.ErrNoRows:
 		return repo.Author{}, ErrAuthorNotFound
 	case nil:
@@ -146,13 +471,20 @@ func (r *PSQLRepository) GetAuthorByNameAndEmail(name string, email string) (rep
 }
 
 // Add new author and return its id.
-func (r *PSQLRepository) AddAuthor(a repo.Author) (string, error) {
+func (r *PSQLRepository) AddAuthor(ctx context.Context, a repo.Author) (string, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
 
 	var id string
 
-	// TO DO: email should be unique, return error if exists
-	query := `INSERT INTO authors(name, email) values ($1, $2) RETURNING id;`
-	err := r.DB.QueryRow(query, a.Name, a.Email).Scan(&id)
+	query := `INSERT INTO authors(name, email, password_hash, role, display_name, bio, avatar_url, website, social)
+		values ($1, $2, NULLIF($3, ''), COALESCE(NULLIF($4, ''), 'author'), $5, $6, $7, $8, $9) RETURNING id;`
+	err := r.DB.QueryRowContext(ctx, query, a.Name, a.Email, a.PasswordHash, a.Role,
+		a.DisplayName, a.Bio, a.AvatarURL, a.Website, a.Social).Scan(&id)
+	if isUniqueViolation(err) {
+		return id, repo.ErrAuthorExists
+	}
 	if err != nil {
 		return id, fmt.Errorf("cannot execute query: %w", err)
 	}
@@ -160,26 +492,40 @@ func (r *PSQLRepository) AddAuthor(a repo.Author) (string, error) {
 	return id, nil
 }
 
-// Add new article and return its id.
-func (r *PSQLRepository) AddArticle(a repo.Article) (string, error) {
+// Update author name and email by id.
+func (r *PSQLRepository) UpdateAuthor(ctx context.Context, a repo.Author) error {
 
-	var id string
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
 
-	// author id must exist in the authors table
-	query := `INSERT INTO articles(title, body, author_id) values ($1, $2, $3) RETURNING id;`
-	err := r.DB.QueryRow(query, a.Title, a.Body, a.Author.Id).Scan(&id)
+	query := `UPDATE authors SET name = $2, email = $3 WHERE id = $1;`
+	res, err := r.DB.ExecContext(ctx, query, a.Id, a.Name, a.Email)
+	if isUniqueViolation(err) {
+		return repo.ErrAuthorExists
+	}
 	if err != nil {
-		return id, fmt.Errorf("cannot execute query: %w", err)
+		return fmt.Errorf("cannot execute query: %w", err)
 	}
 
-	return id, nil
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return ErrAuthorNotFound
+	}
+
+	return nil
 }
 
-// Delete article by id.
-func (r *PSQLRepository) DeleteArticleById(id string) error {
+// Replace the profile of an author by id.
+func (r *PSQLRepository) SetAuthorProfile(ctx context.Context, id string, p repo.Profile) error {
 
-	query := `DELETE FROM articles WHERE id = $1;`
-	res, err := r.DB.Exec(query, id)
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE authors SET display_name = $2, bio = $3, avatar_url = $4, website = $5, social = $6 WHERE id = $1;`
+	res, err := r.DB.ExecContext(ctx, query, id, p.DisplayName, p.Bio, p.AvatarURL, p.Website, p.Social)
 	if err != nil {
 		return fmt.Errorf("cannot execute query: %w", err)
 	}
@@ -189,17 +535,124 @@ func (r *PSQLRepository) DeleteArticleById(id string) error {
 		return fmt.Errorf("cannot retrieve rows affected: %w", err)
 	}
 	if count == 0 {
-		return ErrArticleNotFound
+		return ErrAuthorNotFound
 	}
 
 	return nil
 }
 
-// Delete author by id.
-func (r *PSQLRepository) DeleteAuthorById(id string) error {
+// isUniqueViolation reports whether the error is the violation of a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
 
-	query := `DELETE FROM authors WHERE id = $1;`
-	res, err := r.DB.Exec(query, id)
+// isSlugViolation reports whether the error is the violation of the unique slugs of the articles.
+func isSlugViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "articles_slug_key"
+}
+
+// Add new article with its tags and return its id.
+func (r *PSQLRepository) AddArticle(ctx context.Context, a repo.Article) (string, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	id, err := addArticle(ctx, tx, a)
+	if err != nil {
+		return id, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return id, fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	return id, nil
+}
+
+// Add new article with its author, added first if no author has the author's email, and return the article id.
+func (r *PSQLRepository) CreateArticleWithAuthor(ctx context.Context, a repo.Article) (string, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	// the no-op update locks the existing author and returns its id
+	query := `INSERT INTO authors(name, email, role) values ($1, $2, COALESCE(NULLIF($3, ''), 'author'))
+		ON CONFLICT (email) DO UPDATE SET email = EXCLUDED.email RETURNING id;`
+	err = tx.QueryRowContext(ctx, query, a.Author.Name, a.Author.Email, a.Author.Role).Scan(&a.Author.Id)
+	if err != nil {
+		return "", fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	id, err := addArticle(ctx, tx, a)
+	if err != nil {
+		return id, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return id, fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	return id, nil
+}
+
+// addArticle adds the article with its tags and first revision in the given transaction.
+func addArticle(ctx context.Context, tx *sql.Tx, a repo.Article) (string, error) {
+
+	var id string
+
+	// author id must exist in the authors table
+	query := `INSERT INTO articles(title, slug, body, body_html, status, publish_at, author_id)
+		values ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'published'), $6, $7) RETURNING id;`
+	slug, err := writeSlug(ctx, tx, "", repo.Slugify(a.Title), func(slug string) error {
+		return tx.QueryRowContext(ctx, query, a.Title, slug, a.Body, repo.RenderMarkdown(a.Body), a.Status, a.PublishAt, a.Author.Id).Scan(&id)
+	})
+	if err != nil {
+		return id, err
+	}
+
+	if err = addSlug(ctx, tx, id, slug); err != nil {
+		return id, err
+	}
+
+	if err = setTags(ctx, tx, id, a.Tags); err != nil {
+		return id, err
+	}
+
+	if err = addRevision(ctx, tx, id); err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+// Update article title, body and tags by id.
+func (r *PSQLRepository) UpdateArticle(ctx context.Context, a repo.Article) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	query := `UPDATE articles SET title = $2, body = $3, body_html = $4, updated_at = NOW() WHERE id = $1;`
+	res, err := tx.ExecContext(ctx, query, a.Id, a.Title, a.Body, repo.RenderMarkdown(a.Body))
 	if err != nil {
 		return fmt.Errorf("cannot execute query: %w", err)
 	}
@@ -209,17 +662,52 @@ func (r *PSQLRepository) DeleteAuthorById(id string) error {
 		return fmt.Errorf("cannot retrieve rows affected: %w", err)
 	}
 	if count == 0 {
-		return ErrAuthorNotFound
+		return ErrArticleNotFound
+	}
+
+	if err = setTags(ctx, tx, a.Id, a.Tags); err != nil {
+		return err
+	}
+
+	if err = setSlug(ctx, tx, a.Id); err != nil {
+		return err
+	}
+
+	if err = addRevision(ctx, tx, a.Id); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-// Delete author by name and email (and all its articles).
-func (r *PSQLRepository) DeleteAuthorByNameAndEmail(name string, email string) error {
+// Update the non-nil fields of the patch on the article with the given id.
+func (r *PSQLRepository) PatchArticle(ctx context.Context, id string, p repo.ArticlePatch) error {
 
-	query := `DELETE FROM authors WHERE name = $1 AND email = $2;`
-	res, err := r.DB.Exec(query, name, email)
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	var bodyHTML *string
+	if p.Body != nil {
+		html := repo.RenderMarkdown(*p.Body)
+		bodyHTML = &html
+	}
+
+	// the publication time is set along with the status
+	query := `UPDATE articles SET title = COALESCE($2, title), body = COALESCE($3, body), body_html = COALESCE($6, body_html),
+			status = COALESCE($4, status), publish_at = CASE WHEN $4::text IS NULL THEN publish_at ELSE $5 END,
+			updated_at = NOW()
+		WHERE id = $1;`
+	res, err := tx.ExecContext(ctx, query, id, p.Title, p.Body, p.Status, p.PublishAt, bodyHTML)
 	if err != nil {
 		return fmt.Errorf("cannot execute query: %w", err)
 	}
@@ -229,7 +717,671 @@ func (r *PSQLRepository) DeleteAuthorByNameAndEmail(name string, email string) e
 		return fmt.Errorf("cannot retrieve rows affected: %w", err)
 	}
 	if count == 0 {
-		return ErrAuthorNotFound
+		return ErrArticleNotFound
+	}
+
+	if p.Tags != nil {
+		if err = setTags(ctx, tx, id, *p.Tags); err != nil {
+			return err
+		}
+	}
+
+	if p.Title != nil {
+		if err = setSlug(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	// only changes of the text are recorded as revisions
+	if p.Title != nil || p.Body != nil {
+		if err = addRevision(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Publish the article with the given id now, an article already published keeps its publication time.
+func (r *PSQLRepository) PublishArticle(ctx context.Context, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE articles SET status = 'published', publish_at = NULL,
+		posted_at = CASE WHEN status = 'published' THEN posted_at ELSE NOW() END, updated_at = NOW() WHERE id = $1;`
+	res, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return ErrArticleNotFound
+	}
+
+	return nil
+}
+
+// Move the article with the given id back to draft.
+func (r *PSQLRepository) UnpublishArticle(ctx context.Context, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE articles SET status = 'draft', publish_at = NULL, updated_at = NOW() WHERE id = $1;`
+	res, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return ErrArticleNotFound
+	}
+
+	return nil
+}
+
+// Publish the scheduled articles whose publication time is before now and return how many.
+func (r *PSQLRepository) PublishScheduledArticles(ctx context.Context, now time.Time) (int, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE articles SET status = 'published', posted_at = publish_at, publish_at = NULL, updated_at = NOW()
+		WHERE status = 'scheduled' AND publish_at <= $1;`
+	res, err := r.DB.ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+
+	return int(count), nil
+}
+
+// Delete article by id.
+func (r *PSQLRepository) DeleteArticleById(ctx context.Context, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM articles WHERE id = $1;`
+	res, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return ErrArticleNotFound
+	}
+
+	return nil
+}
+
+// Set the role of an author.
+func (r *PSQLRepository) SetAuthorRole(ctx context.Context, id string, role string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE authors SET role = $2 WHERE id = $1;`
+	res, err := r.DB.ExecContext(ctx, query, id, role)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+// Set the password hash of an author.
+func (r *PSQLRepository) SetAuthorPassword(ctx context.Context, id string, hash string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE authors SET password_hash = $2 WHERE id = $1;`
+	res, err := r.DB.ExecContext(ctx, query, id, hash)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+// Delete author by id.
+func (r *PSQLRepository) DeleteAuthorById(ctx context.Context, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM authors WHERE id = $1;`
+	res, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+// Delete author by name and email (and all its articles).
+func (r *PSQLRepository) DeleteAuthorByNameAndEmail(ctx context.Context, name string, email string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM authors WHERE name = $1 AND email = $2;`
+	res, err := r.DB.ExecContext(ctx, query, name, email)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+var ErrRevisionNotFound = repo.ErrRevisionNotFound
+
+// Get all revisions of an article, oldest first.
+func (r *PSQLRepository) ListRevisions(ctx context.Context, articleId string) ([]repo.Revision, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	revisions := make([]repo.Revision, 0)
+	query := `SELECT r.article_id, r.revision, r.title, r.body, r.created_at
+		FROM article_revisions r WHERE r.article_id = $1 ORDER BY r.revision;`
+
+	rows, err := r.DB.QueryContext(ctx, query, articleId)
+	if err != nil {
+		return []repo.Revision{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rev repo.Revision
+		err := rows.Scan(&rev.ArticleId, &rev.Number, &rev.Title, &rev.Body, &rev.CreatedAt)
+		if err != nil {
+			return []repo.Revision{}, fmt.Errorf("cannot scan revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
+
+// Get revision of an article by number.
+func (r *PSQLRepository) GetRevision(ctx context.Context, articleId string, number int) (repo.Revision, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var rev repo.Revision
+
+	query := `SELECT r.article_id, r.revision, r.title, r.body, r.created_at
+		FROM article_revisions r WHERE r.article_id = $1 AND r.revision = $2;`
+	row := r.DB.QueryRowContext(ctx, query, articleId, number)
+
+	switch err := row.Scan(&rev.ArticleId, &rev.Number, &rev.Title, &rev.Body, &rev.CreatedAt); err {
+	case sql.ErrNoRows:
+		return repo.Revision{}, ErrRevisionNotFound
+	case nil:
+		return rev, nil
+	default:
+		return repo.Revision{}, fmt.Errorf("cannot scan revision: %w", err)
+	}
+}
+
+// Restore the title and body of an article from one of its revisions, recorded as a new revision.
+func (r *PSQLRepository) RestoreRevision(ctx context.Context, articleId string, number int) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	// the html of the body is not recorded in revisions, it is rendered again,
+	// the revisions kept of a deleted article cannot be restored
+	var body string
+	query := `SELECT r.body FROM article_revisions r JOIN articles a ON a.id = r.article_id
+		WHERE r.article_id = $1 AND r.revision = $2;`
+	switch err = tx.QueryRowContext(ctx, query, articleId, number).Scan(&body); err {
+	case sql.ErrNoRows:
+		return ErrRevisionNotFound
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan revision: %w", err)
+	}
+
+	query = `UPDATE articles a SET title = r.title, body = r.body, body_html = $3, updated_at = NOW()
+		FROM article_revisions r WHERE a.id = $1 AND r.article_id = a.id AND r.revision = $2;`
+	_, err = tx.ExecContext(ctx, query, articleId, number, repo.RenderMarkdown(body))
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	if err = setSlug(ctx, tx, articleId); err != nil {
+		return err
+	}
+
+	if err = addRevision(ctx, tx, articleId); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// setSlug gives the article with the given id the slug of its title, unless its slug is
+// already a candidate for it. The new slug is added to the previous ones of the article.
+func setSlug(ctx context.Context, tx *sql.Tx, id string) error {
+
+	var title, current string
+	query := `SELECT title, slug FROM articles WHERE id = $1;`
+	err := tx.QueryRowContext(ctx, query, id).Scan(&title, &current)
+	if err != nil {
+		return fmt.Errorf("cannot scan article: %w", err)
+	}
+
+	slug := repo.Slugify(title)
+	if repo.IsSlugCandidate(current, slug) {
+		return nil
+	}
+
+	query = `UPDATE articles SET slug = $2 WHERE id = $1;`
+	slug, err = writeSlug(ctx, tx, id, slug, func(slug string) error {
+		_, err := tx.ExecContext(ctx, query, id, slug)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return addSlug(ctx, tx, id, slug)
+}
+
+// maxSlugAttempts is how many free candidates for its slug an article is written with,
+// when concurrent transactions keep taking them first.
+const maxSlugAttempts = 5
+
+// writeSlug writes the article with the given id, empty for a new article, with the first free candidate
+// for the slug and returns it. The free candidates are only free until another transaction commits an
+// article with the same slug, the article is then written again with the next free candidate, in a
+// savepoint so that the violation of the unique slugs does not abort the transaction.
+func writeSlug(ctx context.Context, tx *sql.Tx, id string, slug string, write func(slug string) error) (string, error) {
+
+	for attempt := 1; attempt <= maxSlugAttempts; attempt++ {
+
+		free, err := freeSlug(ctx, tx, id, slug)
+		if err != nil {
+			return "", err
+		}
+
+		if _, err = tx.ExecContext(ctx, `SAVEPOINT article_slug;`); err != nil {
+			return "", fmt.Errorf("cannot execute query: %w", err)
+		}
+
+		err = write(free)
+		if err == nil {
+			if _, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT article_slug;`); err != nil {
+				return "", fmt.Errorf("cannot execute query: %w", err)
+			}
+			return free, nil
+		}
+		if !isSlugViolation(err) {
+			return "", fmt.Errorf("cannot execute query: %w", err)
+		}
+
+		if _, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT article_slug;`); err != nil {
+			return "", fmt.Errorf("cannot execute query: %w", err)
+		}
+	}
+
+	return "", repo.ErrSlugTaken
+}
+
+// BackfillSlugs gives the articles whose slug is not one of their title the slug of their title, and returns
+// how many were given one: those the migration adding the slugs gave their id as slug, and those it gave a slug
+// computed in sql, before the slugs were only computed by Slugify. The articles are taken in the order they were posted.
+func BackfillSlugs(ctx context.Context, db *sql.DB) (int, error) {
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	query := `SELECT id, title, slug FROM articles ORDER BY posted_at, id;`
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id, title, slug string
+		if err := rows.Scan(&id, &title, &slug); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("cannot scan article: %w", err)
+		}
+		if !repo.IsSlugCandidate(slug, repo.Slugify(title)) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("cannot scan article: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := setSlug(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+
+	// the ids were never published as slugs, they do not have to resolve
+	query = `DELETE FROM article_slugs WHERE slug = article_id::text;`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return 0, fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("cannot commit transaction: %w", err)
+	}
+	return len(ids), nil
+}
+
+// freeSlug returns the first candidate for the slug not taken, now or before, by another article than the given one.
+func freeSlug(ctx context.Context, tx *sql.Tx, id string, slug string) (string, error) {
+
+	// slugs have no wildcard characters
+	query := `SELECT s.slug FROM article_slugs s WHERE (s.slug = $1 OR s.slug LIKE $1 || '-%') AND s.article_id::text <> $2;`
+	rows, err := tx.QueryContext(ctx, query, slug, id)
+	if err != nil {
+		return "", fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return "", fmt.Errorf("cannot scan slug: %w", err)
+		}
+		taken[s] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("cannot scan slug: %w", err)
+	}
+
+	return repo.FreeSlug(slug, taken), nil
+}
+
+// addSlug adds the slug to the slugs of the article, which may have had it before.
+func addSlug(ctx context.Context, tx *sql.Tx, id string, slug string) error {
+
+	query := `INSERT INTO article_slugs(slug, article_id) VALUES ($1, $2) ON CONFLICT (slug) DO NOTHING;`
+	_, err := tx.ExecContext(ctx, query, slug, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	return nil
+}
+
+// addRevision records the current title and body of the article with the given id as its next revision.
+func addRevision(ctx context.Context, tx *sql.Tx, id string) error {
+
+	query := `INSERT INTO article_revisions(article_id, revision, title, body)
+		SELECT a.id, COALESCE((SELECT MAX(r.revision) FROM article_revisions r WHERE r.article_id = a.id), 0) + 1, a.title, a.body
+		FROM articles a WHERE a.id = $1;`
+	_, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	return nil
+}
+
+var ErrCommentNotFound = repo.ErrCommentNotFound
+
+// Get all comments of an article, oldest first.
+func (r *PSQLRepository) ListComments(ctx context.Context, articleId string) ([]repo.Comment, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	comments := make([]repo.Comment, 0)
+	query := `SELECT c.id, c.article_id, c.parent_id, c.author_name, c.author_email, c.body, c.depth, c.created_at
+		FROM comments c WHERE c.article_id = $1 ORDER BY c.created_at, c.id;`
+
+	rows, err := r.DB.QueryContext(ctx, query, articleId)
+	if err != nil {
+		return []repo.Comment{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c repo.Comment
+		var parentId sql.NullString
+		err := rows.Scan(&c.Id, &c.ArticleId, &parentId, &c.AuthorName, &c.AuthorEmail, &c.Body, &c.Depth, &c.CreatedAt)
+		if err != nil {
+			return []repo.Comment{}, fmt.Errorf("cannot scan comment: %w", err)
+		}
+		c.ParentId = parentId.String
+		comments = append(comments, c)
+	}
+	return comments, nil
+}
+
+// Get comment by id.
+func (r *PSQLRepository) GetCommentById(ctx context.Context, id string) (repo.Comment, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var c repo.Comment
+	var parentId sql.NullString
+
+	query := `SELECT c.id, c.article_id, c.parent_id, c.author_name, c.author_email, c.body, c.depth, c.created_at
+		FROM comments c WHERE c.id = $1;`
+	row := r.DB.QueryRowContext(ctx, query, id)
+
+	switch err := row.Scan(&c.Id, &c.ArticleId, &parentId, &c.AuthorName, &c.AuthorEmail, &c.Body, &c.Depth, &c.CreatedAt); err {
+	case sql.ErrNoRows:
+		return repo.Comment{}, ErrCommentNotFound
+	case nil:
+		c.ParentId = parentId.String
+		return c, nil
+	default:
+		return repo.Comment{}, fmt.Errorf("cannot scan comment: %w", err)
+	}
+}
+
+// Add new comment and return its id.
+func (r *PSQLRepository) AddComment(ctx context.Context, c repo.Comment) (string, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var id string
+
+	// article id and parent id (if any) must exist
+	query := `INSERT INTO comments(article_id, parent_id, author_name, author_email, body, depth)
+		values ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6) RETURNING id;`
+	err := r.DB.QueryRowContext(ctx, query, c.ArticleId, c.ParentId, c.AuthorName, c.AuthorEmail, c.Body, c.Depth).Scan(&id)
+	if err != nil {
+		return id, fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	return id, nil
+}
+
+// Delete comment by id (and all its replies).
+func (r *PSQLRepository) DeleteCommentById(ctx context.Context, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM comments WHERE id = $1;`
+	res, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return ErrCommentNotFound
+	}
+
+	return nil
+}
+
+var ErrTokenNotFound = repo.ErrTokenNotFound
+
+// Get all API tokens of an author, newest first.
+func (r *PSQLRepository) ListTokens(ctx context.Context, authorId string) ([]repo.Token, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tokens := make([]repo.Token, 0)
+	query := `SELECT t.id, t.author_id, t.name, t.token_hash, t.created_at, t.revoked_at
+		FROM api_tokens t WHERE t.author_id = $1 ORDER BY t.created_at DESC, t.id;`
+
+	rows, err := r.DB.QueryContext(ctx, query, authorId)
+	if err != nil {
+		return []repo.Token{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t repo.Token
+		err := rows.Scan(&t.Id, &t.AuthorId, &t.Name, &t.Hash, &t.CreatedAt, &t.RevokedAt)
+		if err != nil {
+			return []repo.Token{}, fmt.Errorf("cannot scan token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// Get API token by hash, revoked tokens included.
+func (r *PSQLRepository) GetTokenByHash(ctx context.Context, hash string) (repo.Token, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var t repo.Token
+
+	query := `SELECT t.id, t.author_id, t.name, t.token_hash, t.created_at, t.revoked_at
+		FROM api_tokens t WHERE t.token_hash = $1;`
+	row := r.DB.QueryRowContext(ctx, query, hash)
+
+	switch err := row.Scan(&t.Id, &t.AuthorId, &t.Name, &t.Hash, &t.CreatedAt, &t.RevokedAt); err {
+	case sql.ErrNoRows:
+		return repo.Token{}, ErrTokenNotFound
+	case nil:
+		return t, nil
+	default:
+		return repo.Token{}, fmt.Errorf("cannot scan token: %w", err)
+	}
+}
+
+// Add new API token and return its id.
+func (r *PSQLRepository) AddToken(ctx context.Context, t repo.Token) (string, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var id string
+
+	// author id must exist in the authors table
+	query := `INSERT INTO api_tokens(author_id, name, token_hash) values ($1, $2, $3) RETURNING id;`
+	err := r.DB.QueryRowContext(ctx, query, t.AuthorId, t.Name, t.Hash).Scan(&id)
+	if err != nil {
+		return id, fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	return id, nil
+}
+
+// Revoke an active API token of an author.
+func (r *PSQLRepository) RevokeToken(ctx context.Context, authorId string, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE api_tokens SET revoked_at = NOW() WHERE id = $1 AND author_id = $2 AND revoked_at IS NULL;`
+	res, err := r.DB.ExecContext(ctx, query, id, authorId)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return ErrTokenNotFound
 	}
 
 	return nil
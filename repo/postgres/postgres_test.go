@@ -1,10 +1,13 @@
 package postgres
 
 import (
+	"blog/migrations"
 	repo "blog/repo"
+	"blog/repo/repotest"
+	"context"
 	"fmt"
-
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -17,255 +20,192 @@ const (
 	dbname   = "blog"
 )
 
-var connection = fmt.Sprintf("postgres://%s:%d/%s?user=%s&password=%s&sslmode=disable", host, port, dbname, user, password)
-
-func TestListArticles(t *testing.T) {
-
-	db, _ := createTestDB(t, connection)
-	r := PSQLRepository{DB: db}
-
-	t.Run("table containing 2 entries", func(t *testing.T) {
-		dumpTestData(t, db)
-		articles, err := r.ListArticles()
-		require.NotEmpty(t, articles)
-		require.NoError(t, err)
-		require.Len(t, articles, 2)
-	})
+var ctx = context.Background()
 
-	t.Run("empty table", func(t *testing.T) {
-		truncateTables(t, db)
-		articles, err := r.ListArticles()
-		require.Empty(t, articles)
-		require.NoError(t, err)
-		require.Len(t, articles, 0)
-	})
+var connection = fmt.Sprintf("postgres://%s:%d/%s?user=%s&password=%s&sslmode=disable", host, port, dbname, user, password)
 
-	t.Run("closed connection", func(t *testing.T) {
-		db.Close()
-		_, err := r.ListArticles()
-		require.Error(t, err)
+func TestConformance(t *testing.T) {
+	repotest.RunConformance(t, func(t *testing.T) repo.BlogService {
+		db, _ := createTestDB(t, connection)
+		return &PSQLRepository{DB: db}
 	})
 }
 
-func TestListAuthors(t *testing.T) {
+func TestPing(t *testing.T) {
 
 	db, _ := createTestDB(t, connection)
 	r := PSQLRepository{DB: db}
 
-	t.Run("table containing 2 entries", func(t *testing.T) {
-		dumpTestData(t, db)
-		authors, err := r.ListAuthors()
-		require.NotEmpty(t, authors)
-		require.NoError(t, err)
-		require.Len(t, authors, 2)
-	})
-
-	t.Run("empty table", func(t *testing.T) {
-		truncateTables(t, db)
-		authors, err := r.ListAuthors()
-		require.Empty(t, authors)
-		require.NoError(t, err)
-		require.Len(t, authors, 0)
+	t.Run("open connection", func(t *testing.T) {
+		require.NoError(t, r.Ping(ctx))
 	})
 
 	t.Run("closed connection", func(t *testing.T) {
 		db.Close()
-		_, err := r.ListAuthors()
-		require.Error(t, err)
+		require.Error(t, r.Ping(ctx))
 	})
-
 }
 
-func TestGetArticleById(t *testing.T) {
+func TestClosedConnection(t *testing.T) {
 
 	db, _ := createTestDB(t, connection)
-	r := PSQLRepository{DB: db}
 	dumpTestData(t, db)
-
-	t.Run("existing article", func(t *testing.T) {
-		a, err := r.GetArticleById("b4a4de9e-2f52-4cf1-8907-3d828d403126")
-		require.NotEmpty(t, a)
-		require.NoError(t, err)
-		require.Equal(t, a.Title, "Test title 1")
-	})
-
-	t.Run("invalid uuid", func(t *testing.T) {
-		_, err := r.GetArticleById("invalid uuid")
-		require.Error(t, err)
-	})
-
-	t.Run("non-existing article", func(t *testing.T) {
-		_, err := r.GetArticleById("b4a4de9e-2f52-4cf1-8907-3d828d403128")
-		require.ErrorIs(t, err, ErrArticleNotFound)
-	})
-}
-
-func TestGetAuthorById(t *testing.T) {
-
-	db, _ := createTestDB(t, connection)
+	db.Close()
 	r := PSQLRepository{DB: db}
-	dumpTestData(t, db)
 
-	t.Run("existing author", func(t *testing.T) {
-		a, err := r.GetAuthorById("b4a4de9e-2f52-4cf1-8907-3d828d403124")
-		require.NotEmpty(t, a)
-		require.NoError(t, err)
-		require.Equal(t, a.Name, "Test Author1")
-	})
-
-	t.Run("invalid uuid", func(t *testing.T) {
-		_, err := r.GetAuthorById("invalid uuid")
-		require.Error(t, err)
-	})
-
-	t.Run("non-existing author", func(t *testing.T) {
-		_, err := r.GetAuthorById("b4a4de9e-2f52-4cf1-8907-3d828d403128")
-		require.ErrorIs(t, err, ErrAuthorNotFound)
-	})
+	_, err := r.ListArticles(ctx, repo.ArticleQuery{})
+	require.Error(t, err)
+	_, err = r.ListAuthors(ctx)
+	require.Error(t, err)
+	err = r.DeleteArticleById(ctx, "b4a4de9e-2f52-4cf1-8907-3d828d403126")
+	require.Error(t, err)
+	err = r.DeleteAuthorById(ctx, "b4a4de9e-2f52-4cf1-8907-3d828d403124")
+	require.Error(t, err)
+	err = r.DeleteAuthorByNameAndEmail(ctx, "Test Author1", "test.author1@email.com")
+	require.Error(t, err)
 }
 
-func TestGetAuthorsByIds(t *testing.T) {
+func TestQueryTimeout(t *testing.T) {
 
 	db, _ := createTestDB(t, connection)
-	r := PSQLRepository{DB: db}
 	dumpTestData(t, db)
 
-	t.Run("existing authors", func(t *testing.T) {
-		ids := []string{"b4a4de9e-2f52-4cf1-8907-3d828d403124", "b4a4de9e-2f52-4cf1-8907-3d828d403125"}
-		a, err := r.GetAuthorsByIds(ids)
-		require.NotEmpty(t, a)
-		require.NoError(t, err)
-		require.Len(t, a, 2)
+	t.Run("canceled context", func(t *testing.T) {
+		r := PSQLRepository{DB: db}
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := r.ListAuthors(canceled)
+		require.ErrorIs(t, err, context.Canceled)
 	})
 
-	t.Run("invalid uuid", func(t *testing.T) {
-		ids := []string{"b4a4de9e-2f52-4cf1-8907-3d828d403124", "invalid uuid"}
-		_, err := r.GetAuthorsByIds(ids)
-		require.Error(t, err)
+	t.Run("query timeout", func(t *testing.T) {
+		r := PSQLRepository{DB: db, QueryTimeout: time.Nanosecond}
+		_, err := r.ListAuthors(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
 	})
-
 }
 
-func TestGetAuthorByNameAndEmail(t *testing.T) {
+func TestConcurrentSlugs(t *testing.T) {
 
 	db, _ := createTestDB(t, connection)
-	r := PSQLRepository{DB: db}
 	dumpTestData(t, db)
-
-	t.Run("existing author", func(t *testing.T) {
-		a, err := r.GetAuthorByNameAndEmail("Test Author1", "test.author1@email.com")
-		require.NotEmpty(t, a)
+	r := &PSQLRepository{DB: db}
+
+	// the articles are written with the same title at once, each ends with its own slug
+	const n = 8
+	ids := make(chan string, n)
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			id, err := r.AddArticle(ctx, repo.Article{Title: "Same title", Body: "test", Author: articles[0].Author})
+			ids <- id
+			errs <- err
+		}()
+	}
+
+	slugs := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+		a, err := r.GetArticleById(ctx, <-ids)
 		require.NoError(t, err)
-		require.Equal(t, a.Id, "b4a4de9e-2f52-4cf1-8907-3d828d403124")
-	})
-
-	t.Run("non-existing author", func(t *testing.T) {
-		_, err := r.GetAuthorByNameAndEmail("John Doe", "john.doe@mail.com")
-		require.ErrorIs(t, err, ErrAuthorNotFound)
-	})
+		slugs[a.Slug] = true
+	}
+	require.Len(t, slugs, n)
 }
 
-func TestAddAuthor(t *testing.T) {
+func TestBackfillSlugs(t *testing.T) {
 
 	db, _ := createTestDB(t, connection)
-	r := PSQLRepository{DB: db}
-
-	t.Run("valid author", func(t *testing.T) {
-		id, err := r.AddAuthor(repo.Author{Name: "John Doe", Email: "john.doe@mail.com"})
+	r := &PSQLRepository{DB: db}
+
+	// the articles as the migration adding the slugs leaves them, with their id as slug,
+	// or with the slugs computed before the slugs of every store were given by Slugify
+	authorId, err := r.AddAuthor(ctx, repo.Author{Name: "Test Author", Email: "test.author@email.com"})
+	require.NoError(t, err)
+	articles := []struct {
+		id, title, postedAt, migrated, slug string
+	}{
+		{"00000000-0000-0000-0000-000000000001", "Crème brûlée", "2022-03-01 10:00:00", "00000000-0000-0000-0000-000000000001", "creme-brulee"},
+		{"00000000-0000-0000-0000-000000000002", "Crème brûlée", "2022-03-02 10:00:00", "00000000-0000-0000-0000-000000000002", "creme-brulee-2"},
+		{"00000000-0000-0000-0000-000000000003", "?", "2022-03-03 10:00:00", "00000000-0000-0000-0000-000000000003", repo.DefaultSlug},
+		{"00000000-0000-0000-0000-000000000004", "Crème brûlée", "2022-03-04 10:00:00", "creme-brulee-00000000", "creme-brulee-3"},
+		{"00000000-0000-0000-0000-000000000005", "Up to date", "2022-03-05 10:00:00", "up-to-date", "up-to-date"},
+	}
+	for _, a := range articles {
+		_, err := db.Exec(`INSERT INTO articles(id, title, slug, body, posted_at, author_id) VALUES ($1, $2, $3, 'body', $4, $5);`,
+			a.id, a.title, a.migrated, a.postedAt, authorId)
 		require.NoError(t, err)
-		require.Len(t, id, 36)
-		a, err := r.ListAuthors()
+		_, err = db.Exec(`INSERT INTO article_slugs(slug, article_id) VALUES ($1, $2);`, a.migrated, a.id)
 		require.NoError(t, err)
-		require.NotEmpty(t, a)
-	})
-}
-
-func TestAddArticle(t *testing.T) {
+	}
 
-	db, _ := createTestDB(t, connection)
-	r := PSQLRepository{DB: db}
-	dumpTestData(t, db)
+	n, err := BackfillSlugs(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, n, 4)
 
-	t.Run("author id already in the table", func(t *testing.T) {
-		id, err := r.AddArticle(repo.Article{Title: "test", Body: "test", Author: repo.Author{Id: "b4a4de9e-2f52-4cf1-8907-3d828d403124"}})
+	for _, a := range articles {
+		got, err := r.GetArticleBySlug(ctx, a.slug)
 		require.NoError(t, err)
-		require.Len(t, id, 36)
-		a, err := r.ListArticles()
-		require.NoError(t, err)
-		require.Len(t, a, 3)
-	})
-
-	t.Run("author id not in the table", func(t *testing.T) {
-		_, err := r.AddArticle(repo.Article{Title: "test", Body: "test", Author: repo.Author{Id: "b4a4de9e-2f52-4cf1-8907-3d828d403128"}})
-		require.Error(t, err)
-	})
+		require.Equal(t, got.Id, a.id)
+		require.Equal(t, got.Slug, a.slug)
+
+		// the ids do not resolve, the slugs computed before still do
+		got, err = r.GetArticleBySlug(ctx, a.migrated)
+		if a.migrated == a.id {
+			require.ErrorIs(t, err, repo.ErrArticleNotFound)
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, got.Id, a.id)
+		}
+	}
+
+	n, err = BackfillSlugs(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, n, 0)
 }
 
-func TestDeleteArticleById(t *testing.T) {
+func TestMigrations(t *testing.T) {
 
 	db, _ := createTestDB(t, connection)
-	r := PSQLRepository{DB: db}
-	dumpTestData(t, db)
-
-	t.Run("existing article", func(t *testing.T) {
-		err := r.DeleteArticleById("b4a4de9e-2f52-4cf1-8907-3d828d403126")
-		require.NoError(t, err)
-	})
 
-	t.Run("non-existing article", func(t *testing.T) {
-		err := r.DeleteArticleById("b4a4de9e-2f52-4cf1-8907-3d828d403128")
-		require.ErrorIs(t, err, ErrArticleNotFound)
-	})
-
-	t.Run("closed connection", func(t *testing.T) {
-		db.Close()
-		err := r.DeleteArticleById("b4a4de9e-2f52-4cf1-8907-3d828d403126")
-		require.Error(t, err)
-	})
-}
+	statuses, err := migrations.Postgres.List(db)
+	require.NoError(t, err)
+	require.NotEmpty(t, statuses)
+	for _, s := range statuses {
+		require.NotNil(t, s.AppliedAt, s.Id)
+	}
 
-func TestDeleteAuthorById(t *testing.T) {
-
-	db, _ := createTestDB(t, connection)
-	r := PSQLRepository{DB: db}
-	dumpTestData(t, db)
-
-	t.Run("existing author", func(t *testing.T) {
-		err := r.DeleteAuthorById("b4a4de9e-2f52-4cf1-8907-3d828d403124")
+	t.Run("down and up again", func(t *testing.T) {
+		n, err := migrations.Postgres.Down(db, len(statuses))
 		require.NoError(t, err)
-	})
+		require.Equal(t, n, len(statuses))
 
-	t.Run("non-existing author", func(t *testing.T) {
-		err := r.DeleteAuthorById("b4a4de9e-2f52-4cf1-8907-3d828d403128")
-		require.ErrorIs(t, err, ErrAuthorNotFound)
-	})
-
-	t.Run("closed connection", func(t *testing.T) {
-		db.Close()
-		err := r.DeleteAuthorById("b4a4de9e-2f52-4cf1-8907-3d828d403124")
-		require.Error(t, err)
+		n, err = migrations.Postgres.Up(db)
+		require.NoError(t, err)
+		require.Equal(t, n, len(statuses))
 	})
-}
 
-func TestDeleteAuthorByNameAndEmail(t *testing.T) {
+	t.Run("redo", func(t *testing.T) {
+		dumpTestData(t, db)
+		before, err := migrations.Postgres.List(db)
+		require.NoError(t, err)
+		last := before[len(before)-1]
+		// the assertions below are on the schema of the last migration, to update with it
+		require.Equal(t, last.Id, "0014_keep_article_revisions.sql")
 
-	db, _ := createTestDB(t, connection)
-	r := PSQLRepository{DB: db}
-	dumpTestData(t, db)
+		require.NoError(t, migrations.Postgres.Redo(db))
 
-	t.Run("existing article", func(t *testing.T) {
-		err := r.DeleteAuthorByNameAndEmail("Test Author1", "test.author1@email.com")
+		after, err := migrations.Postgres.List(db)
 		require.NoError(t, err)
-	})
+		require.Equal(t, after[len(after)-1].Id, last.Id)
+		require.True(t, after[len(after)-1].AppliedAt.After(*last.AppliedAt))
 
-	t.Run("non-existing article", func(t *testing.T) {
-		err := r.DeleteAuthorByNameAndEmail("Do not exist", "Do not exist")
-		require.ErrorIs(t, err, ErrAuthorNotFound)
-	})
-
-	t.Run("closed connection", func(t *testing.T) {
-		db.Close()
-		err := r.DeleteAuthorByNameAndEmail("Test Author1", "test.author1@email.com")
-		require.Error(t, err)
+		// the revisions are kept when their article is deleted again
+		r := &PSQLRepository{DB: db}
+		require.NoError(t, r.DeleteArticleById(ctx, articles[0].Id))
+		revisions, err := r.ListRevisions(ctx, articles[0].Id)
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+		require.Equal(t, revisions[0].Title, articles[0].Title)
 	})
 }
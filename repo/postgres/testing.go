@@ -1,9 +1,9 @@
 package postgres
 
 import (
+	"blog/migrations"
 	repo "blog/repo"
 	"blog/util/utildb"
-	"blog/util/utiltesting"
 	"database/sql"
 	"testing"
 	"time"
@@ -37,7 +37,7 @@ var articles = []repo.Article{
 	},
 }
 
-// createTestDB sets up a random schema in the given db.
+// createTestDB sets up a random schema in the given db, with all the migrations applied.
 // It returns the connection to the database and the schema name. Schema is
 // dropped and DB is closed when the test is finished.
 func createTestDB(tb testing.TB, connection string) (*sql.DB, string) {
@@ -52,18 +52,12 @@ func createTestDB(tb testing.TB, connection string) (*sql.DB, string) {
 		db.Close()                    // nolint: errcheck
 	})
 
-	err = utildb.ExecFile(db, utiltesting.AbsolutePath("/blog/sql/test.sql"))
+	_, err = migrations.Postgres.Up(db)
 	require.NoError(tb, err, "Could not create tables")
 
 	return db, schema
 }
 
-// truncateTables truncates tables in the given db.
-func truncateTables(t *testing.T, db *sql.DB) {
-	_, err := db.Exec("DELETE FROM authors; DELETE FROM articles;")
-	require.NoError(t, err, "Could not truncate tables")
-}
-
 // dumpTestData dumps the test data to the given db.
 func dumpTestData(t *testing.T, db *sql.DB) {
 	t.Helper()
@@ -72,8 +66,14 @@ func dumpTestData(t *testing.T, db *sql.DB) {
 		query := `INSERT INTO authors(id, name, email) values ($1, $2, $3)`
 		_, err := db.Exec(query, art.Author.Id, art.Author.Name, art.Author.Email)
 		require.NoError(t, err, "Could not add authors")
-		query = `INSERT INTO articles(id, title, body, author_id) values ($1, $2, $3, $4)`
-		_, err = db.Exec(query, art.Id, art.Title, art.Body, art.Author.Id)
+		query = `INSERT INTO articles(id, title, slug, body, author_id) values ($1, $2, $3, $4, $5)`
+		_, err = db.Exec(query, art.Id, art.Title, repo.Slugify(art.Title), art.Body, art.Author.Id)
 		require.NoError(t, err, "Could not add articles")
+		query = `INSERT INTO article_slugs(slug, article_id) values ($1, $2)`
+		_, err = db.Exec(query, repo.Slugify(art.Title), art.Id)
+		require.NoError(t, err, "Could not add slugs")
+		query = `INSERT INTO article_revisions(article_id, revision, title, body) values ($1, 1, $2, $3)`
+		_, err = db.Exec(query, art.Id, art.Title, art.Body)
+		require.NoError(t, err, "Could not add revisions")
 	}
 }
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Profile is the public profile of an author, shown with its articles. Bio is Markdown,
+// AvatarURL and Website are absolute http(s) urls.
+type Profile struct {
+	DisplayName string      `json:"display_name,omitempty"`
+	Bio         string      `json:"bio,omitempty"`
+	AvatarURL   string      `json:"avatar_url,omitempty"`
+	Website     string      `json:"website,omitempty"`
+	Social      SocialLinks `json:"social,omitempty"`
+}
+
+// SocialLinks maps social networks to the handle of the author on them, e.g. "github": "bemihai".
+// It is stored as a JSON object, an empty object being read as nil.
+type SocialLinks map[string]string
+
+// Scan implements sql.Scanner for the JSON columns of the social links.
+func (s *SocialLinks) Scan(src interface{}) error {
+
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into social links", src)
+	}
+
+	var links map[string]string
+	if err := json.Unmarshal(data, &links); err != nil {
+		return fmt.Errorf("cannot parse social links: %w", err)
+	}
+	if len(links) == 0 {
+		links = nil
+	}
+	*s = links
+	return nil
+}
+
+// Value implements driver.Valuer, nil links are stored as an empty object.
+func (s SocialLinks) Value() (driver.Value, error) {
+
+	if s == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(map[string]string(s))
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode social links: %w", err)
+	}
+	return string(data), nil
+}
@@ -1,36 +1,282 @@
 package repository
 
 import (
+	"context"
+	"strings"
 	"time"
 )
 
 // BlogService represents the blog repository.
 type BlogService interface {
-	ListArticles() ([]Article, error)
-	ListAuthors() ([]Author, error)
-	GetArticleById(id string) (Article, error)
-	GetAuthorById(id string) (Author, error)
-	GetAuthorsByIds(ids []string) ([]Author, error)
-	GetAuthorByNameAndEmail(name string, email string) (Author, error)
-	AddArticle(a Article) (string, error)
-	AddAuthor(a Author) (string, error)
-	DeleteArticleById(id string) error
-	DeleteAuthorById(id string) error
-	DeleteAuthorByNameAndEmail(name string, email string) error
-}
-
-// Article represents the article model.
+	Ping(ctx context.Context) error
+	ListArticles(ctx context.Context, q ArticleQuery) (ArticlePage, error)
+	ListAuthors(ctx context.Context) ([]Author, error)
+	ListTags(ctx context.Context) ([]TagCount, error)
+	SearchArticles(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error)
+	GetArticleById(ctx context.Context, id string) (Article, error)
+	GetArticleBySlug(ctx context.Context, slug string) (Article, error)
+	GetAuthorById(ctx context.Context, id string) (Author, error)
+	GetAuthorsByIds(ctx context.Context, ids []string) ([]Author, error)
+	GetAuthorByNameAndEmail(ctx context.Context, name string, email string) (Author, error)
+	GetAuthorByEmail(ctx context.Context, email string) (Author, error)
+	AddArticle(ctx context.Context, a Article) (string, error)
+	AddAuthor(ctx context.Context, a Author) (string, error)
+	UpdateAuthor(ctx context.Context, a Author) error
+	SetAuthorProfile(ctx context.Context, id string, p Profile) error
+	CreateArticleWithAuthor(ctx context.Context, a Article) (string, error)
+	UpdateArticle(ctx context.Context, a Article) error
+	PatchArticle(ctx context.Context, id string, p ArticlePatch) error
+	PublishArticle(ctx context.Context, id string) error
+	UnpublishArticle(ctx context.Context, id string) error
+	PublishScheduledArticles(ctx context.Context, now time.Time) (int, error)
+	DeleteArticleById(ctx context.Context, id string) error
+	SetAuthorRole(ctx context.Context, id string, role string) error
+	SetAuthorPassword(ctx context.Context, id string, hash string) error
+	DeleteAuthorById(ctx context.Context, id string) error
+	DeleteAuthorByNameAndEmail(ctx context.Context, name string, email string) error
+	ListRevisions(ctx context.Context, articleId string) ([]Revision, error)
+	GetRevision(ctx context.Context, articleId string, number int) (Revision, error)
+	RestoreRevision(ctx context.Context, articleId string, number int) error
+	ListComments(ctx context.Context, articleId string) ([]Comment, error)
+	GetCommentById(ctx context.Context, id string) (Comment, error)
+	AddComment(ctx context.Context, c Comment) (string, error)
+	DeleteCommentById(ctx context.Context, id string) error
+	ListTokens(ctx context.Context, authorId string) ([]Token, error)
+	GetTokenByHash(ctx context.Context, hash string) (Token, error)
+	AddToken(ctx context.Context, t Token) (string, error)
+	RevokeToken(ctx context.Context, authorId string, id string) error
+}
+
+// Errors returned by every BlogService implementation when the requested entity does not exist.
+var (
+	ErrArticleNotFound  = NewError(KindNotFound, "article_not_found", "article not found")
+	ErrAuthorNotFound   = NewError(KindNotFound, "author_not_found", "author not found")
+	ErrRevisionNotFound = NewError(KindNotFound, "revision_not_found", "revision not found")
+	ErrCommentNotFound  = NewError(KindNotFound, "comment_not_found", "comment not found")
+	ErrTokenNotFound    = NewError(KindNotFound, "token_not_found", "token not found")
+)
+
+// ErrAuthorExists is returned when adding an author, or changing the email of one, to an email another author has.
+var ErrAuthorExists = NewError(KindConflict, "author_exists", "author already exists")
+
+// ErrSlugTaken is returned when concurrent writes keep taking the slugs an article is given, the write can be retried.
+var ErrSlugTaken = NewError(KindConflict, "slug_taken", "slug taken by another article meanwhile")
+
+// Article represents the article model. An empty Status is stored as published,
+// PublishAt is the publication time of a scheduled article. The Slug is generated
+// from the title by the repository, the previous slugs still resolve to the article.
 type Article struct {
-	Id       string    `json:"-"`
-	Title    string    `json:"title"`
-	Body     string    `json:"body"`
-	PostedAt time.Time `json:"posted_at"`
-	Author   Author    `json:"author"`
+	Id        string     `json:"id"`
+	Title     string     `json:"title"`
+	Slug      string     `json:"slug"`
+	Body      string     `json:"body"`
+	BodyHTML  string     `json:"body_html"`
+	PostedAt  time.Time  `json:"posted_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	Author    Author     `json:"author"`
+	Tags      []string   `json:"tags"`
+}
+
+// Article statuses: drafts and scheduled articles are only visible to their editors,
+// scheduled articles are published when their PublishAt time comes.
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// ValidStatus returns whether the given status is a known article status.
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusDraft, StatusScheduled, StatusPublished, StatusArchived:
+		return true
+	}
+	return false
+}
+
+// ArticlePatch represents a partial update of an article, nil fields are left unchanged.
+// PublishAt is only taken into account along with Status.
+type ArticlePatch struct {
+	Title     *string    `json:"title"`
+	Body      *string    `json:"body"`
+	Tags      *[]string  `json:"tags"`
+	Status    *string    `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// Sort keys and orders accepted by ArticleQuery.
+const (
+	SortByPostedAt = "posted_at"
+	SortByTitle    = "title"
+	OrderAsc       = "asc"
+	OrderDesc      = "desc"
+)
+
+// Page size limits for listing articles.
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ArticleQuery represents the paging, sorting and filtering options used to list articles.
+// Zero values mean no filter, see WithDefaults for the default paging and sorting.
+type ArticleQuery struct {
+	Limit       int
+	Cursor      string
+	SortBy      string
+	Order       string
+	AuthorId    string
+	AuthorEmail string
+	Tag         string
+	Status      string
+	From        time.Time
+	To          time.Time
+}
+
+// WithDefaults returns a copy of the query with the paging and sorting defaults filled in.
+func (q ArticleQuery) WithDefaults() ArticleQuery {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	if q.SortBy == "" {
+		q.SortBy = SortByPostedAt
+	}
+	if q.Order == "" {
+		q.Order = OrderDesc
+	}
+	return q
+}
+
+// ArticlePage represents a page of articles, NextCursor is empty on the last page.
+type ArticlePage struct {
+	Articles   []Article `json:"articles"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	Total      int       `json:"total"`
 }
 
-// Author represents the author model.
+// SearchOptions represents the paging and filtering options used to search articles.
+// An empty Status matches articles of any status.
+type SearchOptions struct {
+	Limit  int
+	Offset int
+	Status string
+}
+
+// WithDefaults returns a copy of the options with the paging defaults filled in.
+func (o SearchOptions) WithDefaults() SearchOptions {
+	if o.Limit <= 0 {
+		o.Limit = DefaultLimit
+	}
+	if o.Limit > MaxLimit {
+		o.Limit = MaxLimit
+	}
+	if o.Offset < 0 {
+		o.Offset = 0
+	}
+	return o
+}
+
+// SearchResult represents an article matching a search, with its relevance
+// and the title and body fragments where the matching terms are highlighted.
+// The fragments are html, escaped but for the <mark> tags of the highlights.
+type SearchResult struct {
+	Article
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
+}
+
+// TagCount represents a tag and the number of published articles it is attached to.
+type TagCount struct {
+	Name     string `json:"name"`
+	Articles int    `json:"articles"`
+}
+
+// NormalizeTag returns the canonical form of a tag name: trimmed and lower case.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// NormalizeTags returns the canonical, deduplicated tag names, dropping empty ones.
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	names := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = NormalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		names = append(names, t)
+	}
+	return names
+}
+
+// Author represents the author model. PasswordHash is the bcrypt hash of the author's
+// password, only read by GetAuthorByEmail and empty for authors without credentials.
 type Author struct {
-	Id    string `json:"-"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Id           string `json:"id,omitempty"`
+	Name         string `json:"name"`
+	Email        string `json:"email,omitempty"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"-"`
+	Profile
+	BioHTML string `json:"bio_html,omitempty"`
+}
+
+// Author roles, an empty Role is stored as author.
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleAuthor = "author"
+	RoleReader = "reader"
+)
+
+// ValidRole returns whether the given role is a known author role.
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleEditor, RoleAuthor, RoleReader:
+		return true
+	}
+	return false
+}
+
+// Revision represents a snapshot of the title and body of an article, taken on every change.
+// Revisions of an article are numbered from 1, in chronological order.
+type Revision struct {
+	ArticleId string    `json:"article_id"`
+	Number    int       `json:"revision"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Comment represents the comment model. ParentId is empty for top-level comments,
+// Depth is the number of ancestors of the comment and Replies its nested replies.
+type Comment struct {
+	Id          string    `json:"id"`
+	ArticleId   string    `json:"article_id"`
+	ParentId    string    `json:"parent_id,omitempty"`
+	AuthorName  string    `json:"author_name"`
+	AuthorEmail string    `json:"author_email,omitempty"`
+	Body        string    `json:"body"`
+	Depth       int       `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	Replies     []Comment `json:"replies,omitempty"`
+}
+
+// Token represents a long-lived API token of an author, only the hash of the token is stored.
+type Token struct {
+	Id        string     `json:"id"`
+	AuthorId  string     `json:"author_id"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
 }
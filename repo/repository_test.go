@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	require.Equal(t, NormalizeTags([]string{" Go", "sql", "go", "", "SQL "}), []string{"go", "sql"})
+	require.Empty(t, NormalizeTags(nil))
+}
+
+func TestSocialLinks(t *testing.T) {
+
+	t.Run("round trip", func(t *testing.T) {
+		links := SocialLinks{"github": "bemihai"}
+		v, err := links.Value()
+		require.NoError(t, err)
+
+		var got SocialLinks
+		require.NoError(t, got.Scan([]byte(v.(string))))
+		require.Equal(t, got, links)
+	})
+
+	t.Run("empty links", func(t *testing.T) {
+		v, err := SocialLinks(nil).Value()
+		require.NoError(t, err)
+		require.Equal(t, v, "{}")
+
+		got := SocialLinks{"github": "bemihai"}
+		require.NoError(t, got.Scan("{}"))
+		require.Nil(t, got)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		var got SocialLinks
+		require.Error(t, got.Scan("not json"))
+	})
+}
+
+func TestRenderMarkdown(t *testing.T) {
+
+	t.Run("renders commonmark", func(t *testing.T) {
+		require.Equal(t, RenderMarkdown("# Title\n\nSome *text*."), "<h1>Title</h1>\n<p>Some <em>text</em>.</p>\n")
+		require.Equal(t, RenderMarkdown(""), "")
+	})
+
+	t.Run("renders tables", func(t *testing.T) {
+		out := RenderMarkdown("| a | b |\n|---|---|\n| 1 | 2 |\n")
+		require.Contains(t, out, "<table>")
+		require.Contains(t, out, "<td>1</td>")
+	})
+
+	t.Run("renders fenced code", func(t *testing.T) {
+		out := RenderMarkdown("```go\nfmt.Println(\"<b>\")\n```\n")
+		require.Equal(t, out, "<pre><code class=\"language-go\">fmt.Println(&#34;&lt;b&gt;&#34;)\n</code></pre>\n")
+	})
+
+	t.Run("strips dangerous html", func(t *testing.T) {
+		out := RenderMarkdown("<script>alert(1)</script>\n\n<img src=\"a.png\" onerror=\"alert(1)\">\n\n[link](javascript:alert(1)) <a href=\"javascript:alert(1)\">raw</a>")
+		require.NotContains(t, out, "<script")
+		require.NotContains(t, out, "onerror")
+		require.NotContains(t, out, "javascript:")
+		require.Contains(t, out, `<img src="a.png">`)
+	})
+
+	t.Run("keeps safe html", func(t *testing.T) {
+		out := RenderMarkdown("E = mc<sup>2</sup>, see [go](https://go.dev).")
+		require.Contains(t, out, "mc<sup>2</sup>")
+		require.Contains(t, out, `<a href="https://go.dev" rel="nofollow">go</a>`)
+	})
+}
+
+func TestSlugify(t *testing.T) {
+	for title, slug := range map[string]string{
+		"Hello, World!":                "hello-world",
+		"  Go 1.18 -- generics  ":      "go-1-18-generics",
+		"Crème brûlée à la française":  "creme-brulee-a-la-francaise",
+		"Straße über Ærø and Łódź":     "strasse-uber-aero-and-lodz",
+		"¿Qué?":                        "que",
+		"Привет, мир! Щука и ёж":       "privet-mir-shchuka-i-ezh",
+		"Καλημέρα κόσμε":               "kalimera-kosme",
+		"ΕΛΛΗΝΙΚΆ":                     "ellinika",
+		"Go 日本語":                       "go",
+		"日本語":                          DefaultSlug,
+		"":                             DefaultSlug,
+		strings.Repeat("word ", 30):    strings.TrimSuffix(strings.Repeat("word-", 16), "-"),
+		strings.Repeat("a", 100) + "b": strings.Repeat("a", MaxSlugLength),
+	} {
+		require.Equal(t, Slugify(title), slug, title)
+		require.LessOrEqual(t, len(Slugify(title)), MaxSlugLength)
+	}
+}
+
+func TestSlugCandidate(t *testing.T) {
+	require.Equal(t, SlugCandidate("go", 1), "go")
+	require.Equal(t, SlugCandidate("go", 3), "go-3")
+
+	require.Equal(t, FreeSlug("go", nil), "go")
+	require.Equal(t, FreeSlug("go", map[string]bool{"go": true, "go-2": true, "go-4": true}), "go-3")
+
+	require.True(t, IsSlugCandidate("go", "go"))
+	require.True(t, IsSlugCandidate("go-2", "go"))
+	require.False(t, IsSlugCandidate("go-1", "go"))
+	require.False(t, IsSlugCandidate("go-02", "go"))
+	require.False(t, IsSlugCandidate("go-sql", "go"))
+	require.False(t, IsSlugCandidate("golang", "go"))
+}
+
+func TestEscapeHighlight(t *testing.T) {
+	require.Equal(t, EscapeHighlight(`<b onclick="x">bold</b> & <mark>term</mark>`),
+		`&lt;b onclick=&#34;x&#34;&gt;bold&lt;/b&gt; &amp; <mark>term</mark>`)
+}
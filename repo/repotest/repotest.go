@@ -0,0 +1,1053 @@
+// Package repotest holds the conformance tests every implementation of the blog repository must pass,
+// so that the server behaves the same whichever store it runs on.
+package repotest
+
+import (
+	repo "blog/repo"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// missingId is a well-formed id that no entity of the tests has.
+const missingId = "b4a4de9e-2f52-4cf1-8907-3d828d403128"
+
+var ctx = context.Background()
+
+// Factory returns a new empty repository, released by the factory when the test is finished.
+type Factory func(t *testing.T) repo.BlogService
+
+// fixture is the test data every conformance test starts with: 2 authors having one article each.
+type fixture struct {
+	authors  []repo.Author
+	articles []repo.Article
+}
+
+// RunConformance runs the conformance tests against the repositories returned by the factory,
+// every test getting a new repository.
+func RunConformance(t *testing.T, factory Factory) {
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, r repo.BlogService, f fixture)
+	}{
+		{"ping", testPing},
+		{"list articles", testListArticles},
+		{"search articles", testSearchArticles},
+		{"tags", testTags},
+		{"lookups", testLookups},
+		{"not found", testNotFound},
+		{"add", testAdd},
+		{"create article with author", testCreateArticleWithAuthor},
+		{"update", testUpdate},
+		{"lifecycle", testLifecycle},
+		{"revisions", testRevisions},
+		{"markdown", testMarkdown},
+		{"slugs", testSlugs},
+		{"comments", testComments},
+		{"tokens", testTokens},
+		{"delete author", testDeleteAuthor},
+		{"id formats", testIdFormats},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := factory(t)
+			tt.test(t, r, seed(t, r))
+		})
+	}
+}
+
+// seed adds the test data to the repository through its public API.
+func seed(t *testing.T, r repo.BlogService) fixture {
+	t.Helper()
+
+	var f fixture
+	for _, n := range []string{"1", "2"} {
+		author := repo.Author{Name: "Test Author" + n, Email: "test.author" + n + "@email.com", Role: repo.RoleAuthor}
+		id, err := r.AddAuthor(ctx, author)
+		require.NoError(t, err, "Could not add authors")
+		author.Id = id
+
+		article := repo.Article{Title: "Test title " + n, Body: "Test body " + n, Author: author}
+		id, err = r.AddArticle(ctx, article)
+		require.NoError(t, err, "Could not add articles")
+		article.Id = id
+
+		f.authors = append(f.authors, author)
+		f.articles = append(f.articles, article)
+	}
+	return f
+}
+
+func testPing(t *testing.T, r repo.BlogService, f fixture) {
+	require.NoError(t, r.Ping(ctx))
+}
+
+func testListArticles(t *testing.T, r repo.BlogService, f fixture) {
+
+	t.Run("all articles", func(t *testing.T) {
+		page, err := r.ListArticles(ctx, repo.ArticleQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 2)
+		require.Equal(t, page.Total, 2)
+		require.Empty(t, page.NextCursor)
+	})
+
+	t.Run("paginate sorted by title", func(t *testing.T) {
+		q := repo.ArticleQuery{Limit: 1, SortBy: repo.SortByTitle, Order: repo.OrderAsc}
+		page, err := r.ListArticles(ctx, q)
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 1)
+		require.Equal(t, page.Total, 2)
+		require.Equal(t, page.Articles[0].Title, "Test title 1")
+		require.NotEmpty(t, page.NextCursor)
+
+		q.Cursor = page.NextCursor
+		page, err = r.ListArticles(ctx, q)
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 1)
+		require.Equal(t, page.Articles[0].Title, "Test title 2")
+		require.Empty(t, page.NextCursor)
+	})
+
+	t.Run("cursor of another sort order", func(t *testing.T) {
+		page, err := r.ListArticles(ctx, repo.ArticleQuery{Limit: 1})
+		require.NoError(t, err)
+		_, err = r.ListArticles(ctx, repo.ArticleQuery{Limit: 1, Cursor: page.NextCursor, SortBy: repo.SortByTitle})
+		require.ErrorIs(t, err, repo.ErrInvalidCursor)
+	})
+
+	t.Run("filter by author", func(t *testing.T) {
+		page, err := r.ListArticles(ctx, repo.ArticleQuery{AuthorId: f.authors[0].Id})
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 1)
+		require.Equal(t, page.Total, 1)
+		require.Equal(t, page.Articles[0].Id, f.articles[0].Id)
+
+		page, err = r.ListArticles(ctx, repo.ArticleQuery{AuthorEmail: f.authors[1].Email})
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 1)
+		require.Equal(t, page.Articles[0].Title, "Test title 2")
+	})
+
+	t.Run("filter by date range", func(t *testing.T) {
+		page, err := r.ListArticles(ctx, repo.ArticleQuery{To: time.Now().Add(-time.Hour)})
+		require.NoError(t, err)
+		require.Empty(t, page.Articles)
+		require.Equal(t, page.Total, 0)
+
+		page, err = r.ListArticles(ctx, repo.ArticleQuery{From: time.Now().Add(-time.Hour)})
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 2)
+	})
+
+	t.Run("list authors", func(t *testing.T) {
+		authors, err := r.ListAuthors(ctx)
+		require.NoError(t, err)
+		require.Len(t, authors, 2)
+	})
+}
+
+func testSearchArticles(t *testing.T, r repo.BlogService, f fixture) {
+
+	t.Run("matching articles ranked by relevance", func(t *testing.T) {
+		_, err := r.AddArticle(ctx, repo.Article{Title: "Go generics", Body: "Generics in go", Author: f.authors[0]})
+		require.NoError(t, err)
+		_, err = r.AddArticle(ctx, repo.Article{Title: "Other", Body: "A word about generics", Author: f.authors[0]})
+		require.NoError(t, err)
+
+		results, err := r.SearchArticles(ctx, "generics", repo.SearchOptions{})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.Equal(t, results[0].Title, "Go generics")
+		require.Contains(t, results[0].TitleHighlight, "<mark>generics</mark>")
+		require.Contains(t, results[1].Snippet, "<mark>generics</mark>")
+		require.GreaterOrEqual(t, results[0].Rank, results[1].Rank)
+	})
+
+	t.Run("paging", func(t *testing.T) {
+		results, err := r.SearchArticles(ctx, "generics", repo.SearchOptions{Limit: 1, Offset: 1})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, results[0].Title, "Other")
+	})
+
+	t.Run("highlights are escaped", func(t *testing.T) {
+		_, err := r.AddArticle(ctx, repo.Article{Title: "<script>alert(1)</script> escaping", Body: `<img src=x onerror="alert(1)"> escaping & more`, Author: f.authors[0]})
+		require.NoError(t, err)
+
+		results, err := r.SearchArticles(ctx, "escaping", repo.SearchOptions{})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Contains(t, results[0].TitleHighlight, "&lt;script&gt;")
+		require.Contains(t, results[0].TitleHighlight, "<mark>escaping</mark>")
+		require.NotContains(t, results[0].TitleHighlight, "<script>")
+		require.NotContains(t, results[0].Snippet, "<img")
+		require.Contains(t, results[0].Snippet, "<mark>escaping</mark>")
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		results, err := r.SearchArticles(ctx, "nothing -test", repo.SearchOptions{})
+		require.NoError(t, err)
+		require.Empty(t, results)
+	})
+}
+
+func testTags(t *testing.T, r repo.BlogService, f fixture) {
+
+	var id string
+
+	t.Run("add article with tags", func(t *testing.T) {
+		var err error
+		id, err = r.AddArticle(ctx, repo.Article{Title: "test", Body: "test", Tags: []string{"Go", "sql", "go "}, Author: f.authors[0]})
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Tags, []string{"go", "sql"})
+	})
+
+	t.Run("patch tags", func(t *testing.T) {
+		tags := []string{"go", "testing"}
+		err := r.PatchArticle(ctx, id, repo.ArticlePatch{Tags: &tags})
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Tags, []string{"go", "testing"})
+	})
+
+	t.Run("list tags with counts", func(t *testing.T) {
+		err := r.UpdateArticle(ctx, repo.Article{Id: f.articles[0].Id, Title: "t", Body: "b", Tags: []string{"go"}})
+		require.NoError(t, err)
+		tags, err := r.ListTags(ctx)
+		require.NoError(t, err)
+		require.Equal(t, tags, []repo.TagCount{{Name: "go", Articles: 2}, {Name: "testing", Articles: 1}})
+	})
+
+	t.Run("list tags of published articles only", func(t *testing.T) {
+		_, err := r.AddArticle(ctx, repo.Article{Title: "draft", Body: "draft", Status: repo.StatusDraft, Tags: []string{"go", "wip"}, Author: f.authors[0]})
+		require.NoError(t, err)
+		tags, err := r.ListTags(ctx)
+		require.NoError(t, err)
+		require.Equal(t, tags, []repo.TagCount{{Name: "go", Articles: 2}, {Name: "testing", Articles: 1}})
+	})
+
+	t.Run("list articles by tag", func(t *testing.T) {
+		page, err := r.ListArticles(ctx, repo.ArticleQuery{Tag: "testing"})
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 1)
+		require.Equal(t, page.Articles[0].Id, id)
+		require.Equal(t, page.Articles[0].Tags, []string{"go", "testing"})
+	})
+}
+
+func testLookups(t *testing.T, r repo.BlogService, f fixture) {
+
+	t.Run("article by id", func(t *testing.T) {
+		a, err := r.GetArticleById(ctx, f.articles[0].Id)
+		require.NoError(t, err)
+		require.Equal(t, a.Title, "Test title 1")
+		require.Equal(t, a.Body, "Test body 1")
+		// only the author id is set, the author is fetched separately
+		require.Equal(t, a.Author.Id, f.authors[0].Id)
+		require.Equal(t, a.Status, repo.StatusPublished)
+		require.Nil(t, a.PublishAt)
+		require.Empty(t, a.Tags)
+	})
+
+	t.Run("author by id", func(t *testing.T) {
+		a, err := r.GetAuthorById(ctx, f.authors[0].Id)
+		require.NoError(t, err)
+		require.Equal(t, a.Name, "Test Author1")
+		require.Equal(t, a.Role, repo.RoleAuthor)
+	})
+
+	t.Run("authors by ids", func(t *testing.T) {
+		authors, err := r.GetAuthorsByIds(ctx, []string{f.authors[0].Id, f.authors[1].Id, missingId})
+		require.NoError(t, err)
+		require.Len(t, authors, 2)
+
+		authors, err = r.GetAuthorsByIds(ctx, []string{})
+		require.NoError(t, err)
+		require.Empty(t, authors)
+	})
+
+	t.Run("author by name and email", func(t *testing.T) {
+		a, err := r.GetAuthorByNameAndEmail(ctx, "Test Author1", "test.author1@email.com")
+		require.NoError(t, err)
+		require.Equal(t, a.Id, f.authors[0].Id)
+	})
+
+	t.Run("author by email", func(t *testing.T) {
+		a, err := r.GetAuthorByEmail(ctx, "test.author1@email.com")
+		require.NoError(t, err)
+		require.Equal(t, a.Id, f.authors[0].Id)
+		require.Empty(t, a.PasswordHash)
+
+		id, err := r.AddAuthor(ctx, repo.Author{Name: "John Doe", Email: "john.doe@mail.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		a, err = r.GetAuthorByEmail(ctx, "john.doe@mail.com")
+		require.NoError(t, err)
+		require.Equal(t, a.Id, id)
+		require.Equal(t, a.PasswordHash, "hash")
+	})
+}
+
+func testNotFound(t *testing.T, r repo.BlogService, f fixture) {
+
+	t.Run("article", func(t *testing.T) {
+		_, err := r.GetArticleById(ctx, missingId)
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+		err = r.UpdateArticle(ctx, repo.Article{Id: missingId, Title: "new title", Body: "new body"})
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+		err = r.PatchArticle(ctx, missingId, repo.ArticlePatch{})
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+		err = r.PublishArticle(ctx, missingId)
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+		err = r.UnpublishArticle(ctx, missingId)
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+		err = r.DeleteArticleById(ctx, missingId)
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+	})
+
+	t.Run("author", func(t *testing.T) {
+		_, err := r.GetAuthorById(ctx, missingId)
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+		_, err = r.GetAuthorByNameAndEmail(ctx, "John Doe", "john.doe@mail.com")
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+		_, err = r.GetAuthorByEmail(ctx, "jane.doe@mail.com")
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+		err = r.SetAuthorRole(ctx, missingId, repo.RoleEditor)
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+		err = r.SetAuthorPassword(ctx, missingId, "hash")
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+		err = r.UpdateAuthor(ctx, repo.Author{Id: missingId, Name: "John Doe", Email: "john.doe@mail.com"})
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+		err = r.SetAuthorProfile(ctx, missingId, repo.Profile{Bio: "bio"})
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+		err = r.DeleteAuthorById(ctx, missingId)
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+		err = r.DeleteAuthorByNameAndEmail(ctx, "Do not exist", "Do not exist")
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+	})
+
+	t.Run("revision", func(t *testing.T) {
+		_, err := r.GetRevision(ctx, f.articles[0].Id, 10)
+		require.ErrorIs(t, err, repo.ErrRevisionNotFound)
+		err = r.RestoreRevision(ctx, f.articles[0].Id, 10)
+		require.ErrorIs(t, err, repo.ErrRevisionNotFound)
+		_, err = r.GetRevision(ctx, missingId, 1)
+		require.ErrorIs(t, err, repo.ErrRevisionNotFound)
+	})
+
+	t.Run("comment", func(t *testing.T) {
+		_, err := r.GetCommentById(ctx, missingId)
+		require.ErrorIs(t, err, repo.ErrCommentNotFound)
+		err = r.DeleteCommentById(ctx, missingId)
+		require.ErrorIs(t, err, repo.ErrCommentNotFound)
+	})
+
+	t.Run("token", func(t *testing.T) {
+		_, err := r.GetTokenByHash(ctx, "other")
+		require.ErrorIs(t, err, repo.ErrTokenNotFound)
+		err = r.RevokeToken(ctx, f.authors[0].Id, missingId)
+		require.ErrorIs(t, err, repo.ErrTokenNotFound)
+	})
+
+	t.Run("empty lists", func(t *testing.T) {
+		revisions, err := r.ListRevisions(ctx, missingId)
+		require.NoError(t, err)
+		require.Empty(t, revisions)
+		comments, err := r.ListComments(ctx, missingId)
+		require.NoError(t, err)
+		require.Empty(t, comments)
+		tokens, err := r.ListTokens(ctx, missingId)
+		require.NoError(t, err)
+		require.Empty(t, tokens)
+	})
+}
+
+func testAdd(t *testing.T, r repo.BlogService, f fixture) {
+
+	t.Run("author", func(t *testing.T) {
+		id, err := r.AddAuthor(ctx, repo.Author{Name: "John Doe", Email: "john.doe@mail.com"})
+		require.NoError(t, err)
+		a, err := r.GetAuthorById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Name, "John Doe")
+		require.Equal(t, a.Role, repo.RoleAuthor)
+	})
+
+	t.Run("author with an existing email", func(t *testing.T) {
+		_, err := r.AddAuthor(ctx, repo.Author{Name: "Other name", Email: f.authors[0].Email})
+		require.ErrorIs(t, err, repo.ErrAuthorExists)
+	})
+
+	t.Run("author with profile", func(t *testing.T) {
+		profile := repo.Profile{DisplayName: "Jane", Social: repo.SocialLinks{"mastodon": "@jane@example.com"}}
+		id, err := r.AddAuthor(ctx, repo.Author{Name: "Jane Roe", Email: "jane.roe@mail.com", Profile: profile})
+		require.NoError(t, err)
+		a, err := r.GetAuthorById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Profile, profile)
+	})
+
+	t.Run("author with invalid role", func(t *testing.T) {
+		_, err := r.AddAuthor(ctx, repo.Author{Name: "Jane Doe", Email: "jane.doe@mail.com", Role: "owner"})
+		require.Error(t, err)
+	})
+
+	t.Run("article of an existing author", func(t *testing.T) {
+		_, err := r.AddArticle(ctx, repo.Article{Title: "test", Body: "test", Author: repo.Author{Id: f.authors[0].Id}})
+		require.NoError(t, err)
+		page, err := r.ListArticles(ctx, repo.ArticleQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 3)
+	})
+
+	t.Run("article of a missing author", func(t *testing.T) {
+		_, err := r.AddArticle(ctx, repo.Article{Title: "test", Body: "test", Author: repo.Author{Id: missingId}})
+		require.Error(t, err)
+	})
+
+	t.Run("article with invalid status", func(t *testing.T) {
+		_, err := r.AddArticle(ctx, repo.Article{Title: "test", Body: "test", Status: "hidden", Author: f.authors[0]})
+		require.Error(t, err)
+	})
+}
+
+func testCreateArticleWithAuthor(t *testing.T, r repo.BlogService, f fixture) {
+
+	t.Run("new author", func(t *testing.T) {
+		id, err := r.CreateArticleWithAuthor(ctx, repo.Article{Title: "test", Body: "test", Tags: []string{"go"},
+			Author: repo.Author{Name: "John Doe", Email: "john.doe@mail.com"}})
+		require.NoError(t, err)
+
+		author, err := r.GetAuthorByEmail(ctx, "john.doe@mail.com")
+		require.NoError(t, err)
+		require.Equal(t, author.Name, "John Doe")
+		require.Equal(t, author.Role, repo.RoleAuthor)
+
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Author.Id, author.Id)
+		require.Equal(t, a.Tags, []string{"go"})
+		_, err = r.GetRevision(ctx, id, 1)
+		require.NoError(t, err)
+	})
+
+	t.Run("existing author is matched by email", func(t *testing.T) {
+		id, err := r.CreateArticleWithAuthor(ctx, repo.Article{Title: "test", Body: "test",
+			Author: repo.Author{Name: "Other name", Email: f.authors[0].Email}})
+		require.NoError(t, err)
+
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Author.Id, f.authors[0].Id)
+
+		author, err := r.GetAuthorById(ctx, f.authors[0].Id)
+		require.NoError(t, err)
+		require.Equal(t, author.Name, f.authors[0].Name)
+		authors, err := r.ListAuthors(ctx)
+		require.NoError(t, err)
+		require.Len(t, authors, 3)
+	})
+
+	t.Run("failed article does not add the author", func(t *testing.T) {
+		_, err := r.CreateArticleWithAuthor(ctx, repo.Article{Title: "test", Body: "test", Status: "hidden",
+			Author: repo.Author{Name: "Jane Doe", Email: "jane.doe@mail.com"}})
+		require.Error(t, err)
+		_, err = r.GetAuthorByEmail(ctx, "jane.doe@mail.com")
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+	})
+}
+
+func testUpdate(t *testing.T, r repo.BlogService, f fixture) {
+
+	id := f.articles[0].Id
+
+	t.Run("update article", func(t *testing.T) {
+		err := r.UpdateArticle(ctx, repo.Article{Id: id, Title: "new title", Body: "new body"})
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Title, "new title")
+		require.Equal(t, a.Body, "new body")
+		require.True(t, !a.UpdatedAt.Before(a.PostedAt))
+	})
+
+	t.Run("patch article", func(t *testing.T) {
+		title := "patched title"
+		err := r.PatchArticle(ctx, id, repo.ArticlePatch{Title: &title})
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Title, "patched title")
+		require.Equal(t, a.Body, "new body")
+	})
+
+	t.Run("update author", func(t *testing.T) {
+		err := r.UpdateAuthor(ctx, repo.Author{Id: f.authors[0].Id, Name: "New name", Email: "new.email@email.com"})
+		require.NoError(t, err)
+		a, err := r.GetAuthorByEmail(ctx, "new.email@email.com")
+		require.NoError(t, err)
+		require.Equal(t, a.Id, f.authors[0].Id)
+		require.Equal(t, a.Name, "New name")
+		require.Equal(t, a.Role, repo.RoleAuthor)
+
+		// keeping its own email is not a conflict
+		err = r.UpdateAuthor(ctx, repo.Author{Id: f.authors[0].Id, Name: "Other name", Email: "new.email@email.com"})
+		require.NoError(t, err)
+	})
+
+	t.Run("set author profile", func(t *testing.T) {
+		profile := repo.Profile{DisplayName: "Test", Bio: "Writes *tests*.", AvatarURL: "https://example.com/avatar.png",
+			Website: "https://example.com", Social: repo.SocialLinks{"github": "test"}}
+		err := r.SetAuthorProfile(ctx, f.authors[1].Id, profile)
+		require.NoError(t, err)
+
+		a, err := r.GetAuthorById(ctx, f.authors[1].Id)
+		require.NoError(t, err)
+		require.Equal(t, a.Profile, profile)
+		authors, err := r.GetAuthorsByIds(ctx, []string{f.authors[1].Id})
+		require.NoError(t, err)
+		require.Equal(t, authors[0].Profile, profile)
+		a, err = r.GetAuthorByEmail(ctx, f.authors[1].Email)
+		require.NoError(t, err)
+		require.Equal(t, a.Profile, profile)
+
+		err = r.SetAuthorProfile(ctx, f.authors[1].Id, repo.Profile{})
+		require.NoError(t, err)
+		a, err = r.GetAuthorById(ctx, f.authors[1].Id)
+		require.NoError(t, err)
+		require.Equal(t, a.Profile, repo.Profile{})
+	})
+
+	t.Run("update author with the email of another", func(t *testing.T) {
+		err := r.UpdateAuthor(ctx, repo.Author{Id: f.authors[0].Id, Name: "New name", Email: f.authors[1].Email})
+		require.ErrorIs(t, err, repo.ErrAuthorExists)
+	})
+
+	t.Run("set author role", func(t *testing.T) {
+		err := r.SetAuthorRole(ctx, f.authors[0].Id, repo.RoleEditor)
+		require.NoError(t, err)
+		a, err := r.GetAuthorById(ctx, f.authors[0].Id)
+		require.NoError(t, err)
+		require.Equal(t, a.Role, repo.RoleEditor)
+
+		err = r.SetAuthorRole(ctx, f.authors[0].Id, "owner")
+		require.Error(t, err)
+	})
+
+	t.Run("set author password", func(t *testing.T) {
+		err := r.SetAuthorPassword(ctx, f.authors[1].Id, "hash")
+		require.NoError(t, err)
+		a, err := r.GetAuthorByEmail(ctx, f.authors[1].Email)
+		require.NoError(t, err)
+		require.Equal(t, a.PasswordHash, "hash")
+	})
+
+	t.Run("delete article", func(t *testing.T) {
+		err := r.DeleteArticleById(ctx, id)
+		require.NoError(t, err)
+		_, err = r.GetArticleById(ctx, id)
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+		err = r.DeleteArticleById(ctx, id)
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+	})
+}
+
+func testLifecycle(t *testing.T, r repo.BlogService, f fixture) {
+
+	var id string
+	publishAt := time.Now().UTC().Add(time.Hour).Truncate(time.Microsecond)
+
+	t.Run("add scheduled article", func(t *testing.T) {
+		var err error
+		id, err = r.AddArticle(ctx, repo.Article{Title: "test", Body: "test", Status: repo.StatusScheduled, PublishAt: &publishAt, Author: f.authors[0]})
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Status, repo.StatusScheduled)
+		require.True(t, a.PublishAt.Equal(publishAt))
+	})
+
+	t.Run("list by status", func(t *testing.T) {
+		page, err := r.ListArticles(ctx, repo.ArticleQuery{Status: repo.StatusPublished})
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 2)
+		page, err = r.ListArticles(ctx, repo.ArticleQuery{Status: repo.StatusScheduled})
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 1)
+	})
+
+	t.Run("publish scheduled articles", func(t *testing.T) {
+		count, err := r.PublishScheduledArticles(ctx, time.Now().UTC())
+		require.NoError(t, err)
+		require.Equal(t, count, 0)
+
+		count, err = r.PublishScheduledArticles(ctx, publishAt)
+		require.NoError(t, err)
+		require.Equal(t, count, 1)
+
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Status, repo.StatusPublished)
+		require.True(t, a.PostedAt.Equal(publishAt))
+		require.Nil(t, a.PublishAt)
+	})
+
+	t.Run("unpublish and publish", func(t *testing.T) {
+		err := r.UnpublishArticle(ctx, id)
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Status, repo.StatusDraft)
+
+		err = r.PublishArticle(ctx, id)
+		require.NoError(t, err)
+		a, err = r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Status, repo.StatusPublished)
+	})
+
+	t.Run("publish twice keeps the publication time", func(t *testing.T) {
+		before, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+
+		time.Sleep(10 * time.Millisecond)
+		err = r.PublishArticle(ctx, id)
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Status, repo.StatusPublished)
+		require.True(t, a.PostedAt.Equal(before.PostedAt))
+	})
+
+	t.Run("archive through patch", func(t *testing.T) {
+		status := repo.StatusArchived
+		err := r.PatchArticle(ctx, id, repo.ArticlePatch{Status: &status})
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Status, repo.StatusArchived)
+	})
+}
+
+func testRevisions(t *testing.T, r repo.BlogService, f fixture) {
+
+	id := f.articles[0].Id
+
+	t.Run("every change is recorded", func(t *testing.T) {
+		err := r.UpdateArticle(ctx, repo.Article{Id: id, Title: "new title", Body: "new body"})
+		require.NoError(t, err)
+		body := "patched body"
+		err = r.PatchArticle(ctx, id, repo.ArticlePatch{Body: &body})
+		require.NoError(t, err)
+		status := repo.StatusArchived
+		err = r.PatchArticle(ctx, id, repo.ArticlePatch{Status: &status})
+		require.NoError(t, err)
+
+		revisions, err := r.ListRevisions(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, revisions, 3)
+		require.Equal(t, revisions[0].Number, 1)
+		require.Equal(t, revisions[0].Body, "Test body 1")
+		require.Equal(t, revisions[1].Title, "new title")
+		require.Equal(t, revisions[2].Number, 3)
+		require.Equal(t, revisions[2].Body, "patched body")
+	})
+
+	t.Run("restore revision", func(t *testing.T) {
+		err := r.RestoreRevision(ctx, id, 1)
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Title, "Test title 1")
+		require.Equal(t, a.Body, "Test body 1")
+		rev, err := r.GetRevision(ctx, id, 4)
+		require.NoError(t, err)
+		require.Equal(t, rev.Body, "Test body 1")
+	})
+
+	t.Run("revisions survive the article", func(t *testing.T) {
+		err := r.DeleteArticleById(ctx, id)
+		require.NoError(t, err)
+		revisions, err := r.ListRevisions(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, revisions, 4)
+		require.Equal(t, revisions[0].Title, "Test title 1")
+		rev, err := r.GetRevision(ctx, id, 2)
+		require.NoError(t, err)
+		require.Equal(t, rev.Title, "new title")
+		err = r.RestoreRevision(ctx, id, 1)
+		require.ErrorIs(t, err, repo.ErrRevisionNotFound)
+	})
+}
+
+func testMarkdown(t *testing.T, r repo.BlogService, f fixture) {
+
+	body := "# Title\n\n<script>alert(1)</script>*text*"
+	var id string
+
+	t.Run("body is rendered on add", func(t *testing.T) {
+		var err error
+		id, err = r.AddArticle(ctx, repo.Article{Title: "markdown", Body: body, Author: f.authors[0]})
+		require.NoError(t, err)
+
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Body, body)
+		require.Equal(t, a.BodyHTML, repo.RenderMarkdown(body))
+		require.NotContains(t, a.BodyHTML, "<script>")
+
+		page, err := r.ListArticles(ctx, repo.ArticleQuery{AuthorId: f.authors[0].Id})
+		require.NoError(t, err)
+		found := false
+		for _, a := range page.Articles {
+			if a.Id == id {
+				require.Equal(t, a.BodyHTML, repo.RenderMarkdown(body))
+				found = true
+			}
+		}
+		require.True(t, found)
+
+		results, err := r.SearchArticles(ctx, "markdown", repo.SearchOptions{})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, results[0].BodyHTML, repo.RenderMarkdown(body))
+	})
+
+	t.Run("body is rendered on change", func(t *testing.T) {
+		err := r.UpdateArticle(ctx, repo.Article{Id: id, Title: "markdown", Body: "*updated*"})
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.BodyHTML, "<p><em>updated</em></p>\n")
+
+		patched := "**patched**"
+		err = r.PatchArticle(ctx, id, repo.ArticlePatch{Body: &patched})
+		require.NoError(t, err)
+		a, err = r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.BodyHTML, "<p><strong>patched</strong></p>\n")
+
+		title := "new title"
+		err = r.PatchArticle(ctx, id, repo.ArticlePatch{Title: &title})
+		require.NoError(t, err)
+		a, err = r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.BodyHTML, "<p><strong>patched</strong></p>\n")
+
+		err = r.RestoreRevision(ctx, id, 1)
+		require.NoError(t, err)
+		a, err = r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.BodyHTML, repo.RenderMarkdown(body))
+	})
+}
+
+func testSlugs(t *testing.T, r repo.BlogService, f fixture) {
+
+	// getBySlug returns the id and current slug of the article with the given slug.
+	getBySlug := func(t *testing.T, slug string) (string, string) {
+		t.Helper()
+		a, err := r.GetArticleBySlug(ctx, slug)
+		require.NoError(t, err, slug)
+		return a.Id, a.Slug
+	}
+
+	t.Run("slug is generated from title", func(t *testing.T) {
+		a, err := r.GetArticleById(ctx, f.articles[0].Id)
+		require.NoError(t, err)
+		require.Equal(t, a.Slug, "test-title-1")
+
+		id, err := r.AddArticle(ctx, repo.Article{Title: "Crème brûlée", Body: "test", Author: f.authors[0]})
+		require.NoError(t, err)
+		got, slug := getBySlug(t, "creme-brulee")
+		require.Equal(t, got, id)
+		require.Equal(t, slug, "creme-brulee")
+	})
+
+	t.Run("slug is unique", func(t *testing.T) {
+		for _, want := range []string{"test-title-1-2", "test-title-1-3"} {
+			id, err := r.AddArticle(ctx, repo.Article{Title: "Test title 1", Body: "test", Author: f.authors[1]})
+			require.NoError(t, err)
+			got, _ := getBySlug(t, want)
+			require.Equal(t, got, id)
+		}
+	})
+
+	t.Run("old slugs resolve after title change", func(t *testing.T) {
+		id := f.articles[1].Id
+		err := r.UpdateArticle(ctx, repo.Article{Id: id, Title: "New title", Body: "test"})
+		require.NoError(t, err)
+
+		got, slug := getBySlug(t, "new-title")
+		require.Equal(t, got, id)
+		require.Equal(t, slug, "new-title")
+		got, slug = getBySlug(t, "test-title-2")
+		require.Equal(t, got, id)
+		require.Equal(t, slug, "new-title")
+
+		// the same title keeps the slug
+		err = r.UpdateArticle(ctx, repo.Article{Id: id, Title: "New title", Body: "changed"})
+		require.NoError(t, err)
+		_, slug = getBySlug(t, "new-title")
+		require.Equal(t, slug, "new-title")
+
+		title := "Newer title"
+		err = r.PatchArticle(ctx, id, repo.ArticlePatch{Title: &title})
+		require.NoError(t, err)
+		_, slug = getBySlug(t, "test-title-2")
+		require.Equal(t, slug, "newer-title")
+	})
+
+	t.Run("old slugs are not given to other articles", func(t *testing.T) {
+		id, err := r.AddArticle(ctx, repo.Article{Title: "Test title 2", Body: "test", Author: f.authors[0]})
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, a.Slug, "test-title-2-2")
+
+		got, _ := getBySlug(t, "test-title-2")
+		require.Equal(t, got, f.articles[1].Id)
+	})
+
+	t.Run("previous slug is given back", func(t *testing.T) {
+		err := r.RestoreRevision(ctx, f.articles[1].Id, 1)
+		require.NoError(t, err)
+		a, err := r.GetArticleById(ctx, f.articles[1].Id)
+		require.NoError(t, err)
+		require.Equal(t, a.Slug, "test-title-2")
+	})
+
+	t.Run("return error if slug not found", func(t *testing.T) {
+		_, err := r.GetArticleBySlug(ctx, "missing")
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+	})
+
+	t.Run("delete article deletes its slugs", func(t *testing.T) {
+		err := r.DeleteArticleById(ctx, f.articles[1].Id)
+		require.NoError(t, err)
+		for _, slug := range []string{"test-title-2", "new-title", "newer-title"} {
+			_, err = r.GetArticleBySlug(ctx, slug)
+			require.ErrorIs(t, err, repo.ErrArticleNotFound, slug)
+		}
+
+		id, err := r.AddArticle(ctx, repo.Article{Title: "New title", Body: "test", Author: f.authors[0]})
+		require.NoError(t, err)
+		got, _ := getBySlug(t, "new-title")
+		require.Equal(t, got, id)
+	})
+}
+
+func testComments(t *testing.T, r repo.BlogService, f fixture) {
+
+	articleId := f.articles[0].Id
+	var parentId string
+
+	t.Run("add comment and reply", func(t *testing.T) {
+		var err error
+		parentId, err = r.AddComment(ctx, repo.Comment{ArticleId: articleId, AuthorName: "reader", AuthorEmail: "reader@mail.com", Body: "first"})
+		require.NoError(t, err)
+
+		id, err := r.AddComment(ctx, repo.Comment{ArticleId: articleId, ParentId: parentId, AuthorName: "reader", AuthorEmail: "reader@mail.com", Body: "reply", Depth: 1})
+		require.NoError(t, err)
+
+		c, err := r.GetCommentById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, c.ArticleId, articleId)
+		require.Equal(t, c.ParentId, parentId)
+		require.Equal(t, c.Depth, 1)
+	})
+
+	t.Run("list comments", func(t *testing.T) {
+		comments, err := r.ListComments(ctx, articleId)
+		require.NoError(t, err)
+		require.Len(t, comments, 2)
+		require.Empty(t, comments[0].ParentId)
+		require.Equal(t, comments[1].ParentId, parentId)
+
+		comments, err = r.ListComments(ctx, f.articles[1].Id)
+		require.NoError(t, err)
+		require.Empty(t, comments)
+	})
+
+	t.Run("comment on a missing article", func(t *testing.T) {
+		_, err := r.AddComment(ctx, repo.Comment{ArticleId: missingId, AuthorName: "reader", AuthorEmail: "reader@mail.com", Body: "first"})
+		require.Error(t, err)
+	})
+
+	t.Run("delete comment deletes its replies", func(t *testing.T) {
+		err := r.DeleteCommentById(ctx, parentId)
+		require.NoError(t, err)
+		comments, err := r.ListComments(ctx, articleId)
+		require.NoError(t, err)
+		require.Empty(t, comments)
+		_, err = r.GetCommentById(ctx, parentId)
+		require.ErrorIs(t, err, repo.ErrCommentNotFound)
+	})
+
+	t.Run("delete article deletes its comments", func(t *testing.T) {
+		id, err := r.AddComment(ctx, repo.Comment{ArticleId: articleId, AuthorName: "reader", AuthorEmail: "reader@mail.com", Body: "second"})
+		require.NoError(t, err)
+		err = r.DeleteArticleById(ctx, articleId)
+		require.NoError(t, err)
+		_, err = r.GetCommentById(ctx, id)
+		require.ErrorIs(t, err, repo.ErrCommentNotFound)
+	})
+}
+
+func testTokens(t *testing.T, r repo.BlogService, f fixture) {
+
+	authorId := f.authors[0].Id
+	var id string
+
+	t.Run("add token", func(t *testing.T) {
+		var err error
+		id, err = r.AddToken(ctx, repo.Token{AuthorId: authorId, Name: "ci", Hash: "hash"})
+		require.NoError(t, err)
+
+		tok, err := r.GetTokenByHash(ctx, "hash")
+		require.NoError(t, err)
+		require.Equal(t, tok.Id, id)
+		require.Equal(t, tok.AuthorId, authorId)
+		require.Equal(t, tok.Name, "ci")
+		require.Nil(t, tok.RevokedAt)
+	})
+
+	t.Run("duplicate hash", func(t *testing.T) {
+		_, err := r.AddToken(ctx, repo.Token{AuthorId: authorId, Name: "other", Hash: "hash"})
+		require.Error(t, err)
+	})
+
+	t.Run("list tokens", func(t *testing.T) {
+		tokens, err := r.ListTokens(ctx, authorId)
+		require.NoError(t, err)
+		require.Len(t, tokens, 1)
+		tokens, err = r.ListTokens(ctx, f.authors[1].Id)
+		require.NoError(t, err)
+		require.Empty(t, tokens)
+	})
+
+	t.Run("revoke token", func(t *testing.T) {
+		err := r.RevokeToken(ctx, f.authors[1].Id, id)
+		require.ErrorIs(t, err, repo.ErrTokenNotFound)
+
+		err = r.RevokeToken(ctx, authorId, id)
+		require.NoError(t, err)
+		tok, err := r.GetTokenByHash(ctx, "hash")
+		require.NoError(t, err)
+		require.NotNil(t, tok.RevokedAt)
+
+		err = r.RevokeToken(ctx, authorId, id)
+		require.ErrorIs(t, err, repo.ErrTokenNotFound)
+	})
+}
+
+func testDeleteAuthor(t *testing.T, r repo.BlogService, f fixture) {
+
+	authorId, articleId := f.authors[0].Id, f.articles[0].Id
+
+	commentId, err := r.AddComment(ctx, repo.Comment{ArticleId: articleId, AuthorName: "reader", AuthorEmail: "reader@mail.com", Body: "first"})
+	require.NoError(t, err)
+	_, err = r.AddToken(ctx, repo.Token{AuthorId: authorId, Name: "ci", Hash: "hash"})
+	require.NoError(t, err)
+
+	t.Run("delete by id", func(t *testing.T) {
+		err := r.DeleteAuthorById(ctx, authorId)
+		require.NoError(t, err)
+		_, err = r.GetAuthorById(ctx, authorId)
+		require.ErrorIs(t, err, repo.ErrAuthorNotFound)
+	})
+
+	t.Run("articles of the author are deleted", func(t *testing.T) {
+		_, err := r.GetArticleById(ctx, articleId)
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+		revisions, err := r.ListRevisions(ctx, articleId)
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+		_, err = r.GetCommentById(ctx, commentId)
+		require.ErrorIs(t, err, repo.ErrCommentNotFound)
+	})
+
+	t.Run("tokens of the author are deleted", func(t *testing.T) {
+		_, err := r.GetTokenByHash(ctx, "hash")
+		require.ErrorIs(t, err, repo.ErrTokenNotFound)
+	})
+
+	t.Run("other authors are kept", func(t *testing.T) {
+		authors, err := r.ListAuthors(ctx)
+		require.NoError(t, err)
+		require.Len(t, authors, 1)
+		page, err := r.ListArticles(ctx, repo.ArticleQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 1)
+		require.Equal(t, page.Articles[0].Id, f.articles[1].Id)
+	})
+
+	t.Run("delete by name and email", func(t *testing.T) {
+		err := r.DeleteAuthorByNameAndEmail(ctx, f.authors[1].Name, f.authors[1].Email)
+		require.NoError(t, err)
+		authors, err := r.ListAuthors(ctx)
+		require.NoError(t, err)
+		require.Empty(t, authors)
+		_, err = r.GetArticleById(ctx, f.articles[1].Id)
+		require.ErrorIs(t, err, repo.ErrArticleNotFound)
+	})
+}
+
+func testIdFormats(t *testing.T, r repo.BlogService, f fixture) {
+
+	// requireUUID checks that the id is a uuid in its canonical lower case form
+	requireUUID := func(t *testing.T, id string) {
+		t.Helper()
+		u, err := uuid.Parse(id)
+		require.NoError(t, err)
+		require.Equal(t, id, u.String())
+	}
+
+	t.Run("generated ids are uuids", func(t *testing.T) {
+		requireUUID(t, f.authors[0].Id)
+		requireUUID(t, f.articles[0].Id)
+
+		id, err := r.AddComment(ctx, repo.Comment{ArticleId: f.articles[0].Id, AuthorName: "reader", AuthorEmail: "reader@mail.com", Body: "first"})
+		require.NoError(t, err)
+		requireUUID(t, id)
+
+		id, err = r.AddToken(ctx, repo.Token{AuthorId: f.authors[0].Id, Name: "ci", Hash: "hash"})
+		require.NoError(t, err)
+		requireUUID(t, id)
+	})
+
+	t.Run("ids are returned as given", func(t *testing.T) {
+		a, err := r.GetArticleById(ctx, f.articles[0].Id)
+		require.NoError(t, err)
+		require.Equal(t, a.Id, f.articles[0].Id)
+		require.Equal(t, a.Author.Id, f.authors[0].Id)
+
+		page, err := r.ListArticles(ctx, repo.ArticleQuery{AuthorId: f.authors[1].Id})
+		require.NoError(t, err)
+		require.Len(t, page.Articles, 1)
+		require.Equal(t, page.Articles[0].Id, f.articles[1].Id)
+		require.Equal(t, page.Articles[0].Author.Id, f.authors[1].Id)
+	})
+
+	t.Run("invalid ids are errors", func(t *testing.T) {
+		_, err := r.GetArticleById(ctx, "invalid uuid")
+		require.Error(t, err)
+		_, err = r.GetAuthorById(ctx, "invalid uuid")
+		require.Error(t, err)
+		_, err = r.GetAuthorsByIds(ctx, []string{f.authors[0].Id, "invalid uuid"})
+		require.Error(t, err)
+		_, err = r.GetCommentById(ctx, "invalid uuid")
+		require.Error(t, err)
+		err = r.DeleteArticleById(ctx, "invalid uuid")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// snippetWords is the number of words of the body kept in the snippet of a search result.
+const snippetWords = 30
+
+// Search is a parsed search query, for the stores without full-text search. It is a simplified
+// version of the postgres web search syntax: words and quoted phrases must all appear, case
+// insensitively, and words prefixed by "-" must not. Unlike postgres there is no stemming,
+// "or" is ignored and a word also matches within longer words.
+type Search struct {
+	include []string
+	exclude []string
+	marks   *regexp.Regexp
+}
+
+// ParseSearch parses a search query, a query without terms matches nothing.
+func ParseSearch(query string) Search {
+
+	var s Search
+	fields := strings.Split(query, `"`)
+	for i, f := range fields {
+		// odd fields are quoted phrases
+		if i%2 == 1 {
+			if phrase := strings.Join(strings.Fields(strings.ToLower(f)), " "); phrase != "" {
+				s.include = append(s.include, phrase)
+			}
+			continue
+		}
+		for _, w := range strings.Fields(strings.ToLower(f)) {
+			switch {
+			case w == "or":
+			case strings.HasPrefix(w, "-") && len(w) > 1:
+				s.exclude = append(s.exclude, w[1:])
+			case w != "-":
+				s.include = append(s.include, w)
+			}
+		}
+	}
+
+	if len(s.include) > 0 {
+		quoted := make([]string, 0, len(s.include))
+		for _, t := range s.include {
+			quoted = append(quoted, regexp.QuoteMeta(t))
+		}
+		s.marks = regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+	}
+
+	return s
+}
+
+// Match returns the search result of an article and whether the article matches the search.
+// Relevance is the number of occurrences of the terms, those in the title counting twice.
+func (s Search) Match(a Article) (SearchResult, bool) {
+
+	if len(s.include) == 0 {
+		return SearchResult{}, false
+	}
+
+	text := strings.ToLower(a.Title + "\n" + a.Body)
+	for _, t := range s.include {
+		if !strings.Contains(text, t) {
+			return SearchResult{}, false
+		}
+	}
+	for _, t := range s.exclude {
+		if strings.Contains(text, t) {
+			return SearchResult{}, false
+		}
+	}
+
+	title := len(s.marks.FindAllStringIndex(a.Title, -1))
+	body := len(s.marks.FindAllStringIndex(a.Body, -1))
+
+	return SearchResult{
+		Article:        a,
+		Rank:           float64(2*title + body),
+		TitleHighlight: s.highlight(a.Title),
+		Snippet:        s.highlight(s.snippet(a.Body)),
+	}, true
+}
+
+// snippet returns the words of the body around the first match, the beginning of the body if none.
+func (s Search) snippet(body string) string {
+
+	words := strings.Fields(body)
+	start := 0
+	for i, w := range words {
+		if s.marks.MatchString(w) {
+			start = i - snippetWords/3
+			break
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	end := start + snippetWords
+	if end > len(words) {
+		end = len(words)
+	}
+	return strings.Join(words[start:end], " ")
+}
+
+// highlight escapes the text as html and wraps the occurrences of the terms with <mark> tags.
+func (s Search) highlight(text string) string {
+
+	var b strings.Builder
+	last := 0
+	for _, m := range s.marks.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// markTags restores the <mark> tags of an escaped highlight.
+var markTags = strings.NewReplacer("&lt;mark&gt;", "<mark>", "&lt;/mark&gt;", "</mark>")
+
+// EscapeHighlight escapes as html a text highlighted with <mark> tags by a database which cannot
+// escape the text itself, but for the <mark> tags: the other html of the text is not markup.
+func EscapeHighlight(text string) string {
+	return markTags.Replace(html.EscapeString(text))
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxSlugLength is the maximum length of the slug of a title, before the suffix deduplicating it.
+const MaxSlugLength = 80
+
+// DefaultSlug is the slug of the titles without any letter or digit.
+const DefaultSlug = "article"
+
+// transliterations of the lower-case letters that do not decompose into a latin letter and accents:
+// the latin ligatures and special letters, and the cyrillic and greek alphabets.
+var transliterations = strings.NewReplacer(
+	// latin
+	"ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "ł", "l", "đ", "d", "ð", "d", "þ", "th", "ı", "i",
+	// cyrillic, russian then the letters of the other slavic alphabets
+	"а", "a", "б", "b", "в", "v", "г", "g", "д", "d", "е", "e", "ё", "e", "ж", "zh", "з", "z",
+	"и", "i", "й", "y", "к", "k", "л", "l", "м", "m", "н", "n", "о", "o", "п", "p", "р", "r",
+	"с", "s", "т", "t", "у", "u", "ф", "f", "х", "kh", "ц", "ts", "ч", "ch", "ш", "sh", "щ", "shch",
+	"ъ", "", "ы", "y", "ь", "", "э", "e", "ю", "yu", "я", "ya",
+	"є", "ye", "і", "i", "ї", "yi", "ґ", "g", "ў", "u", "ђ", "dj", "ј", "j", "љ", "lj", "њ", "nj",
+	"ћ", "c", "џ", "dz", "ѓ", "gj", "ќ", "kj", "ѕ", "dz",
+	// greek, with the accented vowels
+	"α", "a", "β", "v", "γ", "g", "δ", "d", "ε", "e", "ζ", "z", "η", "i", "θ", "th", "ι", "i",
+	"κ", "k", "λ", "l", "μ", "m", "ν", "n", "ξ", "x", "ο", "o", "π", "p", "ρ", "r", "σ", "s",
+	"ς", "s", "τ", "t", "υ", "y", "φ", "f", "χ", "ch", "ψ", "ps", "ω", "o",
+	"ά", "a", "έ", "e", "ή", "i", "ί", "i", "ό", "o", "ύ", "y", "ώ", "o", "ϊ", "i", "ϋ", "y", "ΐ", "i", "ΰ", "y",
+)
+
+// Slugify returns the slug of a title: its letters and digits transliterated to lower-case ascii,
+// with the runs of other characters replaced by a hyphen. The latin, cyrillic and greek letters are
+// transliterated, the letters of the other scripts such as CJK are dropped like punctuation, so that
+// the titles written only in them have the DefaultSlug.
+func Slugify(title string) string {
+
+	// accents are removed from the decomposed letters
+	s, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn))), transliterations.Replace(strings.ToLower(title)))
+	if err != nil {
+		s = title
+	}
+
+	var b strings.Builder
+	hyphen := false
+	for _, c := range strings.ToLower(s) {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			hyphen = b.Len() > 0
+			continue
+		}
+		if hyphen {
+			b.WriteByte('-')
+			hyphen = false
+		}
+		b.WriteRune(c)
+	}
+
+	slug := b.String()
+	if len(slug) > MaxSlugLength {
+		slug = slug[:MaxSlugLength]
+		if i := strings.LastIndexByte(slug, '-'); i > 0 {
+			slug = slug[:i]
+		}
+		slug = strings.TrimSuffix(slug, "-")
+	}
+	if slug == "" {
+		return DefaultSlug
+	}
+	return slug
+}
+
+// SlugCandidate returns the n-th candidate for the slug of a title, the slug itself and then
+// the slug suffixed with -2, -3, etc. until one is not taken by another article.
+func SlugCandidate(slug string, n int) string {
+	if n <= 1 {
+		return slug
+	}
+	return slug + "-" + strconv.Itoa(n)
+}
+
+// FreeSlug returns the first candidate for the slug that is not taken by another article.
+func FreeSlug(slug string, taken map[string]bool) string {
+	for n := 1; ; n++ {
+		if c := SlugCandidate(slug, n); !taken[c] {
+			return c
+		}
+	}
+}
+
+// IsSlugCandidate reports whether the slug is one of the candidates of the given slug,
+// in which case an article keeps its slug when its title changes.
+func IsSlugCandidate(slug string, of string) bool {
+	if slug == of {
+		return true
+	}
+	if !strings.HasPrefix(slug, of+"-") {
+		return false
+	}
+	n, err := strconv.Atoi(slug[len(of)+1:])
+	return err == nil && n > 1 && SlugCandidate(of, n) == slug
+}
@@ -0,0 +1,1425 @@
+// Package sqlite implements the blog repository on a sqlite database, for single-binary deployments.
+// Its schema is given by the sqlite migrations, the ids are uuids generated by the repository.
+package sqlite
+
+import (
+	repo "blog/repo"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+)
+
+type SQLiteRepository struct {
+	DB *sql.DB
+	// QueryTimeout bounds the queries of every call, in addition to the deadline of its context.
+	QueryTimeout time.Duration
+}
+
+// Open opens the sqlite database of the given file, created if it does not exist, with the foreign
+// keys enforced. The path ":memory:" opens a database living as long as the returned connection.
+func Open(path string) (*sql.DB, error) {
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+sep+"_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("cannot open database: %w", err)
+	}
+
+	// sqlite has a single writer, and every connection to ":memory:" opens a new database
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+// timestampFormat is the format of the stored times, in UTC: fixed width so that
+// the times compare as texts, with the precision of the postgres timestamps.
+const timestampFormat = "2006-01-02 15:04:05.000000"
+
+// timestamp returns the stored form of a time.
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timestampFormat)
+}
+
+// nullTimestamp returns the stored form of an optional time.
+func nullTimestamp(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return timestamp(*t)
+}
+
+// now returns the stored form of the current time.
+func now() string {
+	return timestamp(time.Now())
+}
+
+// placeholders returns n comma-separated query placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// withTimeout returns the context of the queries of a call, bounded by the query timeout if any.
+func (r *SQLiteRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.QueryTimeout)
+}
+
+// Check that the database is reachable.
+func (r *SQLiteRepository) Ping(ctx context.Context) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	err := r.DB.PingContext(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot ping database: %w", err)
+	}
+
+	return nil
+}
+
+// Get a page of articles matching the query.
+func (r *SQLiteRepository) ListArticles(ctx context.Context, q repo.ArticleQuery) (repo.ArticlePage, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	q = q.WithDefaults()
+
+	// build the filters shared by the count and the page queries
+	where := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if q.AuthorId != "" {
+		where = append(where, "a.author_id = ?")
+		args = append(args, q.AuthorId)
+	}
+	if q.AuthorEmail != "" {
+		where = append(where, "au.email = ?")
+		args = append(args, q.AuthorEmail)
+	}
+	if q.Tag != "" {
+		where = append(where, `EXISTS (SELECT 1 FROM article_tags at JOIN tags t ON t.id = at.tag_id
+			WHERE at.article_id = a.id AND t.name = ?)`)
+		args = append(args, repo.NormalizeTag(q.Tag))
+	}
+	if q.Status != "" {
+		where = append(where, "a.status = ?")
+		args = append(args, q.Status)
+	}
+	if !q.From.IsZero() {
+		where = append(where, "a.posted_at >= ?")
+		args = append(args, timestamp(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "a.posted_at <= ?")
+		args = append(args, timestamp(q.To))
+	}
+
+	page := repo.ArticlePage{Articles: make([]repo.Article, 0)}
+
+	query := `SELECT COUNT(*) FROM articles a JOIN authors au ON au.id = a.author_id` + whereClause(where) + `;`
+	err := r.DB.QueryRowContext(ctx, query, args...).Scan(&page.Total)
+	if err != nil {
+		return repo.ArticlePage{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	column, op, dir := "a.posted_at", "<", "DESC"
+	if q.SortBy == repo.SortByTitle {
+		column = "a.title"
+	}
+	if q.Order == repo.OrderAsc {
+		op, dir = ">", "ASC"
+	}
+
+	// continue after the last article of the previous page
+	if q.Cursor != "" {
+		c, err := repo.DecodeCursor(q, q.Cursor)
+		if err != nil {
+			return repo.ArticlePage{}, err
+		}
+		key := c.Key
+		if q.SortBy == repo.SortByPostedAt {
+			key = timestamp(c.PostedAt())
+		}
+		where = append(where, fmt.Sprintf("(%s, a.id) %s (?, ?)", column, op))
+		args = append(args, key, c.Id)
+	}
+
+	// fetch one more article to know if there is a next page
+	query = fmt.Sprintf(`SELECT a.id, a.title, a.slug, a.body, a.body_html, a.posted_at, a.updated_at, a.status, a.publish_at, a.author_id
+		FROM articles a JOIN authors au ON au.id = a.author_id%s
+		ORDER BY %s %s, a.id %s LIMIT ?;`, whereClause(where), column, dir, dir)
+	args = append(args, q.Limit+1)
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return repo.ArticlePage{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var art repo.Article
+		var auth repo.Author
+		err := rows.Scan(&art.Id, &art.Title, &art.Slug, &art.Body, &art.BodyHTML, &art.PostedAt, &art.UpdatedAt, &art.Status, &art.PublishAt, &auth.Id)
+		if err != nil {
+			return repo.ArticlePage{}, fmt.Errorf("cannot scan article: %w", err)
+		}
+		art.Author = auth
+		page.Articles = append(page.Articles, art)
+	}
+
+	if len(page.Articles) > q.Limit {
+		page.Articles = page.Articles[:q.Limit]
+		page.NextCursor = repo.NewCursor(q, page.Articles[q.Limit-1]).Encode()
+	}
+
+	// get the tags of the articles in the page
+	ids := make([]string, 0, len(page.Articles))
+	for _, a := range page.Articles {
+		ids = append(ids, a.Id)
+	}
+	tags, err := r.getTagMap(ctx, ids)
+	if err != nil {
+		return repo.ArticlePage{}, err
+	}
+	for i := range page.Articles {
+		page.Articles[i].Tags = tags[page.Articles[i].Id]
+	}
+
+	return page, nil
+}
+
+// whereClause joins the given conditions into a WHERE clause.
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// Search articles by relevance over title and body, see repo.Search for the supported syntax.
+// Without full-text search in sqlite, the articles are matched by the repository.
+func (r *SQLiteRepository) SearchArticles(ctx context.Context, query string, opts repo.SearchOptions) ([]repo.SearchResult, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	opts = opts.WithDefaults()
+	search := repo.ParseSearch(query)
+	results := make([]repo.SearchResult, 0)
+
+	sqlQuery := `SELECT a.id, a.title, a.slug, a.body, a.body_html, a.posted_at, a.updated_at, a.status, a.publish_at, a.author_id
+		FROM articles a WHERE ? = '' OR a.status = ?;`
+
+	rows, err := r.DB.QueryContext(ctx, sqlQuery, opts.Status, opts.Status)
+	if err != nil {
+		return []repo.SearchResult{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var art repo.Article
+		err := rows.Scan(&art.Id, &art.Title, &art.Slug, &art.Body, &art.BodyHTML, &art.PostedAt, &art.UpdatedAt, &art.Status, &art.PublishAt, &art.Author.Id)
+		if err != nil {
+			return []repo.SearchResult{}, fmt.Errorf("cannot scan article: %w", err)
+		}
+		if res, ok := search.Match(art); ok {
+			results = append(results, res)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if !a.PostedAt.Equal(b.PostedAt) {
+			return a.PostedAt.After(b.PostedAt)
+		}
+		return a.Id < b.Id
+	})
+
+	if opts.Offset >= len(results) {
+		return make([]repo.SearchResult, 0), nil
+	}
+	results = results[opts.Offset:]
+	if len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+
+	// get the tags of the matching articles
+	ids := make([]string, 0, len(results))
+	for _, res := range results {
+		ids = append(ids, res.Id)
+	}
+	tags, err := r.getTagMap(ctx, ids)
+	if err != nil {
+		return []repo.SearchResult{}, err
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].Id]
+	}
+
+	return results, nil
+}
+
+// authorColumns are the columns of the authors table read into authorFields.
+const authorColumns = `a.id, a.name, a.email, a.role, a.display_name, a.bio, a.avatar_url, a.website, a.social`
+
+// authorFields returns the destinations of authorColumns in the given author.
+func authorFields(a *repo.Author) []interface{} {
+	return []interface{}{&a.Id, &a.Name, &a.Email, &a.Role, &a.DisplayName, &a.Bio, &a.AvatarURL, &a.Website, &a.Social}
+}
+
+// Get all authors.
+func (r *SQLiteRepository) ListAuthors(ctx context.Context) ([]repo.Author, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	authors := make([]repo.Author, 0)
+	query := `SELECT ` + authorColumns + ` FROM authors a ORDER BY a.rowid;`
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return []repo.Author{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a repo.Author
+		err := rows.Scan(authorFields(&a)...)
+		if err != nil {
+			return []repo.Author{}, fmt.Errorf("cannot scan author: %w", err)
+		}
+		authors = append(authors, a)
+	}
+	return authors, nil
+}
+
+// Get all tags attached to at least one published article, with their published article counts.
+func (r *SQLiteRepository) ListTags(ctx context.Context) ([]repo.TagCount, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tags := make([]repo.TagCount, 0)
+	query := `SELECT t.name, COUNT(*) FROM tags t JOIN article_tags at ON at.tag_id = t.id
+		JOIN articles a ON a.id = at.article_id WHERE a.status = 'published'
+		GROUP BY t.name ORDER BY COUNT(*) DESC, t.name;`
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return []repo.TagCount{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t repo.TagCount
+		err := rows.Scan(&t.Name, &t.Articles)
+		if err != nil {
+			return []repo.TagCount{}, fmt.Errorf("cannot scan tag: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, nil
+}
+
+// getTagMap returns the sorted tag names of the given articles, keyed by article id.
+// Every article id has an entry, empty if the article has no tags.
+func (r *SQLiteRepository) getTagMap(ctx context.Context, ids []string) (map[string][]string, error) {
+
+	tags := make(map[string][]string, len(ids))
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		tags[id] = make([]string, 0)
+		args = append(args, id)
+	}
+	if len(ids) == 0 {
+		return tags, nil
+	}
+
+	query := `SELECT at.article_id, t.name FROM article_tags at JOIN tags t ON t.id = at.tag_id
+		WHERE at.article_id IN (` + placeholders(len(ids)) + `) ORDER BY t.name;`
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, name string
+		err := rows.Scan(&id, &name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot scan tag: %w", err)
+		}
+		tags[id] = append(tags[id], name)
+	}
+	return tags, nil
+}
+
+// setTags replaces the tags of the article with the given id, creating the missing tags.
+func setTags(ctx context.Context, tx *sql.Tx, id string, tags []string) error {
+
+	names := repo.NormalizeTags(tags)
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM article_tags WHERE article_id = ?;`, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	for _, name := range names {
+		_, err = tx.ExecContext(ctx, `INSERT INTO tags(name) VALUES (?) ON CONFLICT (name) DO NOTHING;`, name)
+		if err != nil {
+			return fmt.Errorf("cannot execute query: %w", err)
+		}
+
+		query := `INSERT INTO article_tags(article_id, tag_id) SELECT ?, t.id FROM tags t WHERE t.name = ?;`
+		_, err = tx.ExecContext(ctx, query, id, name)
+		if err != nil {
+			return fmt.Errorf("cannot execute query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Get article by id.
+func (r *SQLiteRepository) GetArticleById(ctx context.Context, id string) (repo.Article, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var art repo.Article
+	var auth repo.Author
+
+	query := `SELECT a.id, a.title, a.slug, a.body, a.body_html, a.posted_at, a.updated_at, a.status, a.publish_at, a.author_id FROM articles a WHERE a.id = ?;`
+	row := r.DB.QueryRowContext(ctx, query, id)
+
+	switch err := row.Scan(&art.Id, &art.Title, &art.Slug, &art.Body, &art.BodyHTML, &art.PostedAt, &art.UpdatedAt, &art.Status, &art.PublishAt, &auth.Id); err {
+	case sql.ErrNoRows:
+		return repo.Article{}, repo.ErrArticleNotFound
+	case nil:
+		art.Author = auth
+	default:
+		return repo.Article{}, fmt.Errorf("cannot scan article: %w", err)
+	}
+
+	tags, err := r.getTagMap(ctx, []string{art.Id})
+	if err != nil {
+		return repo.Article{}, err
+	}
+	art.Tags = tags[art.Id]
+
+	return art, nil
+}
+
+// Get article by its current or one of its previous slugs.
+func (r *SQLiteRepository) GetArticleBySlug(ctx context.Context, slug string) (repo.Article, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var id string
+
+	query := `SELECT s.article_id FROM article_slugs s WHERE s.slug = ?;`
+	row := r.DB.QueryRowContext(ctx, query, slug)
+
+	switch err := row.Scan(&id); err {
+	case sql.ErrNoRows:
+		return repo.Article{}, repo.ErrArticleNotFound
+	case nil:
+		return r.GetArticleById(ctx, id)
+	default:
+		return repo.Article{}, fmt.Errorf("cannot scan slug: %w", err)
+	}
+}
+
+// Get author by id.
+func (r *SQLiteRepository) GetAuthorById(ctx context.Context, id string) (repo.Author, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var a repo.Author
+
+	query := `SELECT ` + authorColumns + ` FROM authors a WHERE a.id = ?;`
+	row := r.DB.QueryRowContext(ctx, query, id)
+
+	switch err := row.Scan(authorFields(&a)...); err {
+	case sql.ErrNoRows:
+		return repo.Author{}, repo.ErrAuthorNotFound
+	case nil:
+		return a, nil
+	default:
+		return repo.Author{}, fmt.Errorf("cannot scan author: %w", err)
+	}
+}
+
+// Get authors by ids.
+func (r *SQLiteRepository) GetAuthorsByIds(ctx context.Context, ids []string) ([]repo.Author, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	authors := make([]repo.Author, 0)
+	if len(ids) == 0 {
+		return authors, nil
+	}
+
+	// ids are uuids, as in postgres an invalid id is an error rather than no author
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			return []repo.Author{}, fmt.Errorf("invalid author id %q: %w", id, err)
+		}
+		args = append(args, id)
+	}
+
+	query := `SELECT ` + authorColumns + ` FROM authors a WHERE a.id IN (` + placeholders(len(ids)) + `);`
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return []repo.Author{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a repo.Author
+		err := rows.Scan(authorFields(&a)...)
+		if err != nil {
+			return []repo.Author{}, fmt.Errorf("cannot scan author: %w", err)
+		}
+		authors = append(authors, a)
+	}
+
+	return authors, nil
+}
+
+// Get author by name and email.
+func (r *SQLiteRepository) GetAuthorByNameAndEmail(ctx context.Context, name string, email string) (repo.Author, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var a repo.Author
+
+	query := `SELECT ` + authorColumns + ` FROM authors a WHERE a.name = ? AND a.email = ?;`
+	row := r.DB.QueryRowContext(ctx, query, name, email)
+
+	switch err := row.Scan(authorFields(&a)...); err {
+	case sql.ErrNoRows:
+		return repo.Author{}, repo.ErrAuthorNotFound
+	case nil:
+		return a, nil
+	default:
+		return repo.Author{}, fmt.Errorf("cannot scan author: %w", err)
+	}
+}
+
+// Get author by email, along with its password hash. Emails are unique.
+func (r *SQLiteRepository) GetAuthorByEmail(ctx context.Context, email string) (repo.Author, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var a repo.Author
+
+	query := `SELECT ` + authorColumns + `, COALESCE(a.password_hash, '') FROM authors a
+		WHERE a.email = ?;`
+	row := r.DB.QueryRowContext(ctx, query, email)
+
+	switch err := row.Scan(append(authorFields(&a), &a.PasswordHash)...); err {
+	case sql.ErrNoRows:
+		return repo.Author{}, repo.ErrAuthorNotFound
+	case nil:
+		return a, nil
+	default:
+		return repo.Author{}, fmt.Errorf("cannot scan author: %w", err)
+	}
+}
+
+// Add new author and return its id.
+func (r *SQLiteRepository) AddAuthor(ctx context.Context, a repo.Author) (string, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	id := uuid.New().String()
+
+	query := `INSERT INTO authors(id, name, email, password_hash, role, display_name, bio, avatar_url, website, social)
+		values (?, ?, ?, NULLIF(?, ''), COALESCE(NULLIF(?, ''), 'author'), ?, ?, ?, ?, ?);`
+	_, err := r.DB.ExecContext(ctx, query, id, a.Name, a.Email, a.PasswordHash, a.Role,
+		a.DisplayName, a.Bio, a.AvatarURL, a.Website, a.Social)
+	if isUniqueViolation(err) {
+		return "", repo.ErrAuthorExists
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	return id, nil
+}
+
+// Update author name and email by id.
+func (r *SQLiteRepository) UpdateAuthor(ctx context.Context, a repo.Author) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE authors SET name = ?, email = ? WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, query, a.Name, a.Email, a.Id)
+	if isUniqueViolation(err) {
+		return repo.ErrAuthorExists
+	}
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+// Replace the profile of an author by id.
+func (r *SQLiteRepository) SetAuthorProfile(ctx context.Context, id string, p repo.Profile) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE authors SET display_name = ?, bio = ?, avatar_url = ?, website = ?, social = ? WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, query, p.DisplayName, p.Bio, p.AvatarURL, p.Website, p.Social, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+// isUniqueViolation reports whether the error is the violation of a unique constraint.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// Add new article with its tags and return its id.
+func (r *SQLiteRepository) AddArticle(ctx context.Context, a repo.Article) (string, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	id, err := addArticle(ctx, tx, a)
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	return id, nil
+}
+
+// Add new article with its author, added first if no author has the author's email, and return the article id.
+func (r *SQLiteRepository) CreateArticleWithAuthor(ctx context.Context, a repo.Article) (string, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	// the no-op update returns the id of the existing author
+	query := `INSERT INTO authors(id, name, email, role) values (?, ?, ?, COALESCE(NULLIF(?, ''), 'author'))
+		ON CONFLICT (email) DO UPDATE SET email = excluded.email RETURNING id;`
+	err = tx.QueryRowContext(ctx, query, uuid.New().String(), a.Author.Name, a.Author.Email, a.Author.Role).Scan(&a.Author.Id)
+	if err != nil {
+		return "", fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	id, err := addArticle(ctx, tx, a)
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	return id, nil
+}
+
+// addArticle adds the article with its tags and first revision in the given transaction.
+func addArticle(ctx context.Context, tx *sql.Tx, a repo.Article) (string, error) {
+
+	id := uuid.New().String()
+	t := now()
+
+	slug, err := freeSlug(ctx, tx, id, repo.Slugify(a.Title))
+	if err != nil {
+		return "", err
+	}
+
+	// author id must exist in the authors table
+	query := `INSERT INTO articles(id, title, slug, body, body_html, posted_at, updated_at, status, publish_at, author_id)
+		values (?, ?, ?, ?, ?, ?, ?, COALESCE(NULLIF(?, ''), 'published'), ?, ?);`
+	_, err = tx.ExecContext(ctx, query, id, a.Title, slug, a.Body, repo.RenderMarkdown(a.Body), t, t, a.Status, nullTimestamp(a.PublishAt), a.Author.Id)
+	if err != nil {
+		return "", fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	if err = addSlug(ctx, tx, id, slug); err != nil {
+		return "", err
+	}
+
+	if err = setTags(ctx, tx, id, a.Tags); err != nil {
+		return "", err
+	}
+
+	if err = addRevision(ctx, tx, id); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Update article title, body and tags by id.
+func (r *SQLiteRepository) UpdateArticle(ctx context.Context, a repo.Article) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	query := `UPDATE articles SET title = ?, body = ?, body_html = ?, updated_at = ? WHERE id = ?;`
+	res, err := tx.ExecContext(ctx, query, a.Title, a.Body, repo.RenderMarkdown(a.Body), now(), a.Id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrArticleNotFound
+	}
+
+	if err = setTags(ctx, tx, a.Id, a.Tags); err != nil {
+		return err
+	}
+
+	if err = setSlug(ctx, tx, a.Id); err != nil {
+		return err
+	}
+
+	if err = addRevision(ctx, tx, a.Id); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Update the non-nil fields of the patch on the article with the given id.
+func (r *SQLiteRepository) PatchArticle(ctx context.Context, id string, p repo.ArticlePatch) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	var bodyHTML *string
+	if p.Body != nil {
+		html := repo.RenderMarkdown(*p.Body)
+		bodyHTML = &html
+	}
+
+	// the publication time is set along with the status
+	query := `UPDATE articles SET title = COALESCE(?1, title), body = COALESCE(?2, body), body_html = COALESCE(?7, body_html),
+			status = COALESCE(?3, status), publish_at = CASE WHEN ?3 IS NULL THEN publish_at ELSE ?4 END,
+			updated_at = ?5
+		WHERE id = ?6;`
+	res, err := tx.ExecContext(ctx, query, p.Title, p.Body, p.Status, nullTimestamp(p.PublishAt), now(), id, bodyHTML)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrArticleNotFound
+	}
+
+	if p.Tags != nil {
+		if err = setTags(ctx, tx, id, *p.Tags); err != nil {
+			return err
+		}
+	}
+
+	if p.Title != nil {
+		if err = setSlug(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	// only changes of the text are recorded as revisions
+	if p.Title != nil || p.Body != nil {
+		if err = addRevision(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Publish the article with the given id now, an article already published keeps its publication time.
+func (r *SQLiteRepository) PublishArticle(ctx context.Context, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	t := now()
+	query := `UPDATE articles SET status = 'published', publish_at = NULL,
+		posted_at = CASE WHEN status = 'published' THEN posted_at ELSE ? END, updated_at = ? WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, query, t, t, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrArticleNotFound
+	}
+
+	return nil
+}
+
+// Move the article with the given id back to draft.
+func (r *SQLiteRepository) UnpublishArticle(ctx context.Context, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE articles SET status = 'draft', publish_at = NULL, updated_at = ? WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, query, now(), id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrArticleNotFound
+	}
+
+	return nil
+}
+
+// Publish the scheduled articles whose publication time is before now and return how many.
+func (r *SQLiteRepository) PublishScheduledArticles(ctx context.Context, t time.Time) (int, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE articles SET status = 'published', posted_at = publish_at, publish_at = NULL, updated_at = ?
+		WHERE status = 'scheduled' AND publish_at <= ?;`
+	res, err := r.DB.ExecContext(ctx, query, now(), timestamp(t))
+	if err != nil {
+		return 0, fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+
+	return int(count), nil
+}
+
+// Delete article by id.
+func (r *SQLiteRepository) DeleteArticleById(ctx context.Context, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM articles WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrArticleNotFound
+	}
+
+	return nil
+}
+
+// Set the role of an author.
+func (r *SQLiteRepository) SetAuthorRole(ctx context.Context, id string, role string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE authors SET role = ? WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, query, role, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+// Set the password hash of an author.
+func (r *SQLiteRepository) SetAuthorPassword(ctx context.Context, id string, hash string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE authors SET password_hash = ? WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, query, hash, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+// Delete author by id.
+func (r *SQLiteRepository) DeleteAuthorById(ctx context.Context, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM authors WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+// Delete author by name and email (and all its articles).
+func (r *SQLiteRepository) DeleteAuthorByNameAndEmail(ctx context.Context, name string, email string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM authors WHERE name = ? AND email = ?;`
+	res, err := r.DB.ExecContext(ctx, query, name, email)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+// Get all revisions of an article, oldest first.
+func (r *SQLiteRepository) ListRevisions(ctx context.Context, articleId string) ([]repo.Revision, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	revisions := make([]repo.Revision, 0)
+	query := `SELECT r.article_id, r.revision, r.title, r.body, r.created_at
+		FROM article_revisions r WHERE r.article_id = ? ORDER BY r.revision;`
+
+	rows, err := r.DB.QueryContext(ctx, query, articleId)
+	if err != nil {
+		return []repo.Revision{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rev repo.Revision
+		err := rows.Scan(&rev.ArticleId, &rev.Number, &rev.Title, &rev.Body, &rev.CreatedAt)
+		if err != nil {
+			return []repo.Revision{}, fmt.Errorf("cannot scan revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
+
+// Get revision of an article by number.
+func (r *SQLiteRepository) GetRevision(ctx context.Context, articleId string, number int) (repo.Revision, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var rev repo.Revision
+
+	query := `SELECT r.article_id, r.revision, r.title, r.body, r.created_at
+		FROM article_revisions r WHERE r.article_id = ? AND r.revision = ?;`
+	row := r.DB.QueryRowContext(ctx, query, articleId, number)
+
+	switch err := row.Scan(&rev.ArticleId, &rev.Number, &rev.Title, &rev.Body, &rev.CreatedAt); err {
+	case sql.ErrNoRows:
+		return repo.Revision{}, repo.ErrRevisionNotFound
+	case nil:
+		return rev, nil
+	default:
+		return repo.Revision{}, fmt.Errorf("cannot scan revision: %w", err)
+	}
+}
+
+// Restore the title and body of an article from one of its revisions, recorded as a new revision.
+func (r *SQLiteRepository) RestoreRevision(ctx context.Context, articleId string, number int) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	// the html of the body is not recorded in revisions, it is rendered again,
+	// the revisions kept of a deleted article cannot be restored
+	var body string
+	query := `SELECT r.body FROM article_revisions r JOIN articles a ON a.id = r.article_id
+		WHERE r.article_id = ? AND r.revision = ?;`
+	switch err = tx.QueryRowContext(ctx, query, articleId, number).Scan(&body); err {
+	case sql.ErrNoRows:
+		return repo.ErrRevisionNotFound
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan revision: %w", err)
+	}
+
+	query = `UPDATE articles AS a SET title = r.title, body = r.body, body_html = ?, updated_at = ?
+		FROM article_revisions r WHERE a.id = ? AND r.article_id = a.id AND r.revision = ?;`
+	_, err = tx.ExecContext(ctx, query, repo.RenderMarkdown(body), now(), articleId, number)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	if err = setSlug(ctx, tx, articleId); err != nil {
+		return err
+	}
+
+	if err = addRevision(ctx, tx, articleId); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// setSlug gives the article with the given id the slug of its title, unless its slug is
+// already a candidate for it. The new slug is added to the previous ones of the article.
+func setSlug(ctx context.Context, tx *sql.Tx, id string) error {
+
+	var title, current string
+	query := `SELECT title, slug FROM articles WHERE id = ?;`
+	err := tx.QueryRowContext(ctx, query, id).Scan(&title, &current)
+	if err != nil {
+		return fmt.Errorf("cannot scan article: %w", err)
+	}
+
+	slug := repo.Slugify(title)
+	if repo.IsSlugCandidate(current, slug) {
+		return nil
+	}
+
+	slug, err = freeSlug(ctx, tx, id, slug)
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE articles SET slug = ? WHERE id = ?;`
+	_, err = tx.ExecContext(ctx, query, slug, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	return addSlug(ctx, tx, id, slug)
+}
+
+// BackfillSlugs gives the articles whose slug is not one of their title the slug of their title, such as
+// those the migration adding the slugs gave their id as slug, and returns how many were given one.
+// The articles are taken in the order they were posted.
+func BackfillSlugs(ctx context.Context, db *sql.DB) (int, error) {
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	query := `SELECT id, title, slug FROM articles ORDER BY posted_at, id;`
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id, title, slug string
+		if err := rows.Scan(&id, &title, &slug); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("cannot scan article: %w", err)
+		}
+		if !repo.IsSlugCandidate(slug, repo.Slugify(title)) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("cannot scan article: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := setSlug(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+
+	// the ids were never published as slugs, they do not have to resolve
+	query = `DELETE FROM article_slugs WHERE slug = article_id;`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return 0, fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("cannot commit transaction: %w", err)
+	}
+	return len(ids), nil
+}
+
+// freeSlug returns the first candidate for the slug not taken, now or before, by another article than the given one.
+func freeSlug(ctx context.Context, tx *sql.Tx, id string, slug string) (string, error) {
+
+	// slugs have no wildcard characters
+	query := `SELECT s.slug FROM article_slugs s WHERE (s.slug = ?1 OR s.slug LIKE ?1 || '-%') AND s.article_id <> ?2;`
+	rows, err := tx.QueryContext(ctx, query, slug, id)
+	if err != nil {
+		return "", fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return "", fmt.Errorf("cannot scan slug: %w", err)
+		}
+		taken[s] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("cannot scan slug: %w", err)
+	}
+
+	return repo.FreeSlug(slug, taken), nil
+}
+
+// addSlug adds the slug to the slugs of the article, which may have had it before.
+func addSlug(ctx context.Context, tx *sql.Tx, id string, slug string) error {
+
+	query := `INSERT INTO article_slugs(slug, article_id, created_at) VALUES (?, ?, ?) ON CONFLICT (slug) DO NOTHING;`
+	_, err := tx.ExecContext(ctx, query, slug, id, now())
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	return nil
+}
+
+// addRevision records the current title and body of the article with the given id as its next revision.
+func addRevision(ctx context.Context, tx *sql.Tx, id string) error {
+
+	query := `INSERT INTO article_revisions(article_id, revision, title, body, created_at)
+		SELECT a.id, COALESCE((SELECT MAX(r.revision) FROM article_revisions r WHERE r.article_id = a.id), 0) + 1, a.title, a.body, ?
+		FROM articles a WHERE a.id = ?;`
+	_, err := tx.ExecContext(ctx, query, now(), id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	return nil
+}
+
+// Get all comments of an article, oldest first.
+func (r *SQLiteRepository) ListComments(ctx context.Context, articleId string) ([]repo.Comment, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	comments := make([]repo.Comment, 0)
+	query := `SELECT c.id, c.article_id, c.parent_id, c.author_name, c.author_email, c.body, c.depth, c.created_at
+		FROM comments c WHERE c.article_id = ? ORDER BY c.created_at, c.id;`
+
+	rows, err := r.DB.QueryContext(ctx, query, articleId)
+	if err != nil {
+		return []repo.Comment{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c repo.Comment
+		var parentId sql.NullString
+		err := rows.Scan(&c.Id, &c.ArticleId, &parentId, &c.AuthorName, &c.AuthorEmail, &c.Body, &c.Depth, &c.CreatedAt)
+		if err != nil {
+			return []repo.Comment{}, fmt.Errorf("cannot scan comment: %w", err)
+		}
+		c.ParentId = parentId.String
+		comments = append(comments, c)
+	}
+	return comments, nil
+}
+
+// Get comment by id.
+func (r *SQLiteRepository) GetCommentById(ctx context.Context, id string) (repo.Comment, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var c repo.Comment
+	var parentId sql.NullString
+
+	query := `SELECT c.id, c.article_id, c.parent_id, c.author_name, c.author_email, c.body, c.depth, c.created_at
+		FROM comments c WHERE c.id = ?;`
+	row := r.DB.QueryRowContext(ctx, query, id)
+
+	switch err := row.Scan(&c.Id, &c.ArticleId, &parentId, &c.AuthorName, &c.AuthorEmail, &c.Body, &c.Depth, &c.CreatedAt); err {
+	case sql.ErrNoRows:
+		return repo.Comment{}, repo.ErrCommentNotFound
+	case nil:
+		c.ParentId = parentId.String
+		return c, nil
+	default:
+		return repo.Comment{}, fmt.Errorf("cannot scan comment: %w", err)
+	}
+}
+
+// Add new comment and return its id.
+func (r *SQLiteRepository) AddComment(ctx context.Context, c repo.Comment) (string, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	id := uuid.New().String()
+
+	// article id and parent id (if any) must exist
+	query := `INSERT INTO comments(id, article_id, parent_id, author_name, author_email, body, depth, created_at)
+		values (?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?);`
+	_, err := r.DB.ExecContext(ctx, query, id, c.ArticleId, c.ParentId, c.AuthorName, c.AuthorEmail, c.Body, c.Depth, now())
+	if err != nil {
+		return "", fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	return id, nil
+}
+
+// Delete comment by id (and all its replies).
+func (r *SQLiteRepository) DeleteCommentById(ctx context.Context, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM comments WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrCommentNotFound
+	}
+
+	return nil
+}
+
+// Get all API tokens of an author, newest first.
+func (r *SQLiteRepository) ListTokens(ctx context.Context, authorId string) ([]repo.Token, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tokens := make([]repo.Token, 0)
+	query := `SELECT t.id, t.author_id, t.name, t.token_hash, t.created_at, t.revoked_at
+		FROM api_tokens t WHERE t.author_id = ? ORDER BY t.created_at DESC, t.id;`
+
+	rows, err := r.DB.QueryContext(ctx, query, authorId)
+	if err != nil {
+		return []repo.Token{}, fmt.Errorf("cannot execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t repo.Token
+		err := rows.Scan(&t.Id, &t.AuthorId, &t.Name, &t.Hash, &t.CreatedAt, &t.RevokedAt)
+		if err != nil {
+			return []repo.Token{}, fmt.Errorf("cannot scan token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// Get API token by hash, revoked tokens included.
+func (r *SQLiteRepository) GetTokenByHash(ctx context.Context, hash string) (repo.Token, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var t repo.Token
+
+	query := `SELECT t.id, t.author_id, t.name, t.token_hash, t.created_at, t.revoked_at
+		FROM api_tokens t WHERE t.token_hash = ?;`
+	row := r.DB.QueryRowContext(ctx, query, hash)
+
+	switch err := row.Scan(&t.Id, &t.AuthorId, &t.Name, &t.Hash, &t.CreatedAt, &t.RevokedAt); err {
+	case sql.ErrNoRows:
+		return repo.Token{}, repo.ErrTokenNotFound
+	case nil:
+		return t, nil
+	default:
+		return repo.Token{}, fmt.Errorf("cannot scan token: %w", err)
+	}
+}
+
+// Add new API token and return its id.
+func (r *SQLiteRepository) AddToken(ctx context.Context, t repo.Token) (string, error) {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	id := uuid.New().String()
+
+	// author id must exist in the authors table
+	query := `INSERT INTO api_tokens(id, author_id, name, token_hash, created_at) values (?, ?, ?, ?, ?);`
+	_, err := r.DB.ExecContext(ctx, query, id, t.AuthorId, t.Name, t.Hash, now())
+	if err != nil {
+		return "", fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	return id, nil
+}
+
+// Revoke an active API token of an author.
+func (r *SQLiteRepository) RevokeToken(ctx context.Context, authorId string, id string) error {
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND author_id = ? AND revoked_at IS NULL;`
+	res, err := r.DB.ExecContext(ctx, query, now(), id, authorId)
+	if err != nil {
+		return fmt.Errorf("cannot execute query: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rows affected: %w", err)
+	}
+	if count == 0 {
+		return repo.ErrTokenNotFound
+	}
+
+	return nil
+}
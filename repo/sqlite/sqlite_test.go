@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"blog/migrations"
+	repo "blog/repo"
+	"blog/repo/repotest"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.RunConformance(t, func(t *testing.T) repo.BlogService {
+		return &SQLiteRepository{DB: createTestDB(t)}
+	})
+}
+
+func TestBackfillSlugs(t *testing.T) {
+
+	ctx := context.Background()
+	db := createTestDB(t)
+	r := &SQLiteRepository{DB: db}
+
+	// the articles as the migration adding the slugs leaves them, with their id as slug,
+	// or with the slugs computed before the slugs of every store were given by Slugify
+	authorId, err := r.AddAuthor(ctx, repo.Author{Name: "Test Author", Email: "test.author@email.com"})
+	require.NoError(t, err)
+	articles := []struct {
+		id, title, postedAt, migrated, slug string
+	}{
+		{"00000000-0000-0000-0000-000000000001", "Crème brûlée", "2022-03-01 10:00:00.000000", "00000000-0000-0000-0000-000000000001", "creme-brulee"},
+		{"00000000-0000-0000-0000-000000000002", "Crème brûlée", "2022-03-02 10:00:00.000000", "00000000-0000-0000-0000-000000000002", "creme-brulee-2"},
+		{"00000000-0000-0000-0000-000000000003", "?", "2022-03-03 10:00:00.000000", "00000000-0000-0000-0000-000000000003", repo.DefaultSlug},
+		{"00000000-0000-0000-0000-000000000004", "Crème brûlée", "2022-03-04 10:00:00.000000", "creme-brulee-00000000", "creme-brulee-3"},
+		{"00000000-0000-0000-0000-000000000005", "Up to date", "2022-03-05 10:00:00.000000", "up-to-date", "up-to-date"},
+	}
+	for _, a := range articles {
+		_, err := db.Exec(`INSERT INTO articles(id, title, slug, body, posted_at, author_id) VALUES (?, ?, ?, 'body', ?, ?);`,
+			a.id, a.title, a.migrated, a.postedAt, authorId)
+		require.NoError(t, err)
+		_, err = db.Exec(`INSERT INTO article_slugs(slug, article_id) VALUES (?, ?);`, a.migrated, a.id)
+		require.NoError(t, err)
+	}
+
+	n, err := BackfillSlugs(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, n, 4)
+
+	for _, a := range articles {
+		got, err := r.GetArticleBySlug(ctx, a.slug)
+		require.NoError(t, err)
+		require.Equal(t, got.Id, a.id)
+		require.Equal(t, got.Slug, a.slug)
+
+		// the ids do not resolve, the slugs computed before still do
+		got, err = r.GetArticleBySlug(ctx, a.migrated)
+		if a.migrated == a.id {
+			require.ErrorIs(t, err, repo.ErrArticleNotFound)
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, got.Id, a.id)
+		}
+	}
+
+	n, err = BackfillSlugs(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, n, 0)
+}
+
+// createTestDB opens an in-memory sqlite database with all the migrations applied,
+// closed when the test is finished.
+func createTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := Open(":memory:")
+	require.NoError(t, err, "Could not open sqlite database")
+	t.Cleanup(func() {
+		db.Close() // nolint: errcheck
+	})
+
+	_, err = migrations.SQLite.Up(db)
+	require.NoError(t, err, "Could not create tables")
+
+	return db
+}